@@ -15,15 +15,25 @@ import (
 	"github.com/hashicorp/go-discover/provider/aliyun"
 	"github.com/hashicorp/go-discover/provider/aws"
 	"github.com/hashicorp/go-discover/provider/azure"
+	"github.com/hashicorp/go-discover/provider/consul"
 	"github.com/hashicorp/go-discover/provider/digitalocean"
+	"github.com/hashicorp/go-discover/provider/dns"
+	"github.com/hashicorp/go-discover/provider/dnssd"
+	"github.com/hashicorp/go-discover/provider/docker"
+	"github.com/hashicorp/go-discover/provider/etcd"
+	"github.com/hashicorp/go-discover/provider/exec"
+	"github.com/hashicorp/go-discover/provider/file"
 	"github.com/hashicorp/go-discover/provider/gce"
+	"github.com/hashicorp/go-discover/provider/http"
 	"github.com/hashicorp/go-discover/provider/linode"
 	"github.com/hashicorp/go-discover/provider/mdns"
+	"github.com/hashicorp/go-discover/provider/nomad"
 	"github.com/hashicorp/go-discover/provider/os"
 	"github.com/hashicorp/go-discover/provider/packet"
 	"github.com/hashicorp/go-discover/provider/scaleway"
 	"github.com/hashicorp/go-discover/provider/softlayer"
 	"github.com/hashicorp/go-discover/provider/srv"
+	"github.com/hashicorp/go-discover/provider/static"
 	"github.com/hashicorp/go-discover/provider/tencentcloud"
 	"github.com/hashicorp/go-discover/provider/triton"
 	"github.com/hashicorp/go-discover/provider/vsphere"
@@ -52,14 +62,24 @@ var Providers = map[string]Provider{
 	"aliyun":       &aliyun.Provider{},
 	"aws":          &aws.Provider{},
 	"azure":        &azure.Provider{},
+	"consul":       &consul.Provider{},
 	"digitalocean": &digitalocean.Provider{},
+	"dns":          &dns.Provider{},
+	"dnssd":        &dnssd.Provider{},
+	"docker":       &docker.Provider{},
+	"etcd":         &etcd.Provider{},
+	"exec":         &exec.Provider{},
+	"file":         &file.Provider{},
 	"gce":          &gce.Provider{},
+	"http":         &http.Provider{},
 	"linode":       &linode.Provider{},
 	"mdns":         &mdns.Provider{},
+	"nomad":        &nomad.Provider{},
 	"os":           &os.Provider{},
 	"scaleway":     &scaleway.Provider{},
 	"softlayer":    &softlayer.Provider{},
 	"srv":          &srv.Provider{},
+	"static":       &static.Provider{},
 	"tencentcloud": &tencentcloud.Provider{},
 	"triton":       &triton.Provider{},
 	"vsphere":      &vsphere.Provider{},
@@ -0,0 +1,15 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build k8s
+
+package discover
+
+import "github.com/hashicorp/go-discover/provider/k8s"
+
+// The Kubernetes provider pulls in the Kubernetes client libraries which
+// are a large dependency, so it is only registered by default when
+// building with the "k8s" build tag.
+func init() {
+	Providers["k8s"] = &k8s.Provider{}
+}
@@ -0,0 +1,40 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build k8s
+
+package discover
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+)
+
+func TestProvidersKubernetes(t *testing.T) {
+	if _, ok := Providers["k8s"]; !ok {
+		t.Fatal("k8s provider isn't registered with the k8s build tag")
+	}
+
+	d, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := false
+	for _, name := range d.Names() {
+		if name == "k8s" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("k8s not listed in %v", d.Names())
+	}
+
+	// The provider is reached through the default set.
+	_, err = d.Addrs("provider=k8s mode=invalid", log.New(io.Discard, "", 0))
+	if err == nil || !strings.Contains(err.Error(), "discover-k8s: invalid mode") {
+		t.Fatalf("got error %v", err)
+	}
+}
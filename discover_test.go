@@ -0,0 +1,38 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+//go:build !k8s
+
+package discover
+
+import (
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestProvidersDefault(t *testing.T) {
+	if _, ok := Providers["k8s"]; ok {
+		t.Fatal("k8s provider is registered without the k8s build tag")
+	}
+
+	d, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range d.Names() {
+		if name == "k8s" {
+			t.Fatalf("k8s listed in %v", d.Names())
+		}
+	}
+
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		t.Skip("no build info")
+	}
+	for _, dep := range bi.Deps {
+		if strings.HasPrefix(dep.Path, "k8s.io/") {
+			t.Fatalf("default providers link %s", dep.Path)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package discovertest provides a conformance test suite for discover
+// providers.
+//
+// Every provider, whether it lives in this repository or not, should pass
+// RunConformance so that callers can rely on the same behavior regardless
+// of the provider they configure:
+//
+//   - configurations for another provider are rejected with an error
+//   - a nil logger is accepted and never causes a panic
+//   - Help() follows the layout used by the command line tool
+//   - the same configuration yields the same addresses in the same order
+//   - a lookup that finds nothing returns a nil slice and no error
+//   - errors are prefixed with "discover-<name>:" and wrap their cause
+package discovertest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Provider has the methods of a discover provider which are exercised by
+// RunConformance.
+type Provider interface {
+	Addrs(args map[string]string, l *log.Logger) ([]string, error)
+	Help() string
+}
+
+// Fixtures describes the configurations used to exercise a provider.
+// Only Name is required. Checks which need a configuration that has not
+// been provided are skipped.
+type Fixtures struct {
+	// Name is the value of the "provider" key the provider accepts.
+	Name string
+
+	// Args is a configuration which can be resolved without credentials
+	// or network access outside of the test process, e.g. against a
+	// local test server. Every call with Args must return Want. A nil
+	// Want means that no addresses are expected.
+	Args map[string]string
+	Want []string
+
+	// Invalid is a configuration which must be rejected with an error.
+	Invalid map[string]string
+
+	// Timeout is a configuration with a short timeout against an
+	// endpoint which never answers. Addrs must return within
+	// TimeoutAfter. It may return either an error or no addresses.
+	Timeout      map[string]string
+	TimeoutAfter time.Duration
+
+	// Err is a configuration which must fail with an error for which
+	// errors.Is(err, ErrIs) holds.
+	Err   map[string]string
+	ErrIs error
+}
+
+// RunConformance runs the conformance test suite for p as subtests of t.
+func RunConformance(t *testing.T, p Provider, f Fixtures) {
+	t.Helper()
+
+	if f.Name == "" {
+		t.Fatal("discovertest: fixtures must set Name")
+	}
+	if f.Err != nil && f.ErrIs == nil {
+		t.Fatal("discovertest: fixtures with Err must set ErrIs")
+	}
+
+	t.Run("Help", func(t *testing.T) {
+		checkHelp(t, p.Help(), f.Name)
+	})
+
+	t.Run("RejectsOtherProvider", func(t *testing.T) {
+		for _, name := range []string{"", "not-" + f.Name} {
+			args := map[string]string{"provider": name}
+			addrs, err := lookup(t, p, args, testLogger(t))
+			if err == nil {
+				t.Fatalf("provider=%q: want error, got %v", name, addrs)
+			}
+			if addrs != nil {
+				t.Fatalf("provider=%q: want nil addrs on error, got %#v", name, addrs)
+			}
+			checkErr(t, err, f.Name)
+			if name != "" && !strings.Contains(err.Error(), name) {
+				t.Fatalf("provider=%q: error %q does not name the provider", name, err)
+			}
+		}
+	})
+
+	t.Run("NilLogger", func(t *testing.T) {
+		configs := []map[string]string{{"provider": "not-" + f.Name}}
+		for _, args := range []map[string]string{f.Args, f.Invalid, f.Err} {
+			if args != nil {
+				configs = append(configs, args)
+			}
+		}
+		for _, args := range configs {
+			// A panic fails the test through lookup.
+			lookup(t, p, args, nil)
+		}
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		if f.Invalid == nil {
+			t.Skip("no invalid configuration")
+		}
+		addrs, err := lookup(t, p, f.Invalid, testLogger(t))
+		if err == nil {
+			t.Fatalf("want error, got %v", addrs)
+		}
+		if addrs != nil {
+			t.Fatalf("want nil addrs on error, got %#v", addrs)
+		}
+		checkErr(t, err, f.Name)
+	})
+
+	t.Run("Deterministic", func(t *testing.T) {
+		if f.Args == nil {
+			t.Skip("no offline configuration")
+		}
+		for i := 0; i < 3; i++ {
+			got, err := lookup(t, p, f.Args, testLogger(t))
+			if err != nil {
+				t.Fatalf("call %d: %s", i, err)
+			}
+			if len(f.Want) == 0 {
+				if got != nil {
+					t.Fatalf("call %d: want nil addrs, got %#v", i, got)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(got, f.Want) {
+				t.Fatalf("call %d: got %v want %v", i, got, f.Want)
+			}
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		if f.Timeout == nil {
+			t.Skip("no timeout configuration")
+		}
+		if f.TimeoutAfter <= 0 {
+			t.Fatal("discovertest: fixtures with Timeout must set TimeoutAfter")
+		}
+		start := time.Now()
+		got, err := lookup(t, p, f.Timeout, testLogger(t))
+		if d := time.Since(start); d > f.TimeoutAfter {
+			t.Fatalf("returned after %s, want at most %s", d, f.TimeoutAfter)
+		}
+		if err != nil {
+			if got != nil {
+				t.Fatalf("want nil addrs on error, got %#v", got)
+			}
+			checkErr(t, err, f.Name)
+		}
+	})
+
+	t.Run("ErrorWrapping", func(t *testing.T) {
+		if f.Err == nil {
+			t.Skip("no error configuration")
+		}
+		_, err := lookup(t, p, f.Err, testLogger(t))
+		if err == nil {
+			t.Fatal("want error, got none")
+		}
+		checkErr(t, err, f.Name)
+		if !errors.Is(err, f.ErrIs) {
+			t.Fatalf("error %q does not wrap %q", err, f.ErrIs)
+		}
+	})
+}
+
+// lookup calls p.Addrs and turns a panic into a test failure.
+func lookup(t *testing.T, p Provider, args map[string]string, l *log.Logger) ([]string, error) {
+	t.Helper()
+	defer func() {
+		if r := recover(); r != nil {
+			t.Fatalf("Addrs(%v) panicked: %v", args, r)
+		}
+	}()
+	return p.Addrs(copyArgs(args), l)
+}
+
+// copyArgs protects the fixtures from providers which modify their args.
+func copyArgs(args map[string]string) map[string]string {
+	m := make(map[string]string, len(args))
+	for k, v := range args {
+		m[k] = v
+	}
+	return m
+}
+
+// checkErr verifies that err carries the "discover-<name>:" prefix.
+func checkErr(t *testing.T, err error, name string) {
+	t.Helper()
+	if prefix := "discover-" + name + ":"; !strings.HasPrefix(err.Error(), prefix) {
+		t.Fatalf("error %q does not start with %q", err, prefix)
+	}
+}
+
+// checkHelp verifies the layout of a provider's help text:
+//
+//	Title:
+//
+//	    provider:   "name"
+//	    ...
+func checkHelp(t *testing.T, help, name string) {
+	t.Helper()
+	if help == "" {
+		t.Fatal("help is empty")
+	}
+	if !strings.HasSuffix(help, "\n") {
+		t.Fatal("help must end with a newline")
+	}
+	lines := strings.Split(help, "\n")
+	if title := lines[0]; title == "" || title != strings.TrimSpace(title) || !strings.HasSuffix(title, ":") {
+		t.Fatalf("help must start with an unindented title ending in ':', got %q", title)
+	}
+	for i, line := range lines {
+		if strings.TrimRight(line, " \t") != line {
+			t.Fatalf("help line %d has trailing whitespace: %q", i+1, line)
+		}
+	}
+	want := fmt.Sprintf("%q", name)
+	for _, line := range lines[1:] {
+		f := strings.Fields(line)
+		if len(f) >= 2 && f[0] == "provider:" && f[1] == want {
+			return
+		}
+	}
+	t.Fatalf("help does not document the provider key as provider: %s", want)
+}
+
+// testLogger returns a logger which writes to stderr when tests run
+// verbose and discards the output otherwise.
+func testLogger(t *testing.T) *log.Logger {
+	if testing.Verbose() {
+		return log.New(os.Stderr, t.Name()+": ", log.LstdFlags)
+	}
+	return log.New(io.Discard, "", 0)
+}
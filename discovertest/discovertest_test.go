@@ -0,0 +1,93 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package discovertest_test
+
+import (
+	"testing"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/k8s"
+)
+
+// packageSuites are the providers which run the full suite against a
+// local stand-in for their API in their own packages.
+var packageSuites = map[string]bool{
+	"aws":       true,
+	"consul":    true,
+	"dns":       true,
+	"dnssd":     true,
+	"docker":    true,
+	"etcd":      true,
+	"exec":      true,
+	"file":      true,
+	"http":      true,
+	"k8s":       true,
+	"linode":    true,
+	"mdns":      true,
+	"nomad":     true,
+	"packet":    true,
+	"scaleway":  true,
+	"softlayer": true,
+	"srv":       true,
+	"static":    true,
+	"vsphere":   true,
+}
+
+// fixtures are the offline fixtures of the providers whose API has no
+// local stand-in. They are rejected before any request is made.
+var fixtures = map[string]discovertest.Fixtures{
+	"aliyun": {
+		Invalid: map[string]string{"provider": "aliyun", "tag_key": "consul", "tag_value": "server"},
+	},
+	"azure": {
+		Invalid: map[string]string{"provider": "azure", "tag_name": "consul", "tag_value": "server", "environment": "AzureGermanCloud"},
+	},
+	"digitalocean": {
+		Invalid: map[string]string{"provider": "digitalocean", "tag_name": "consul"},
+	},
+	"gce": {
+		Invalid: map[string]string{"provider": "gce", "label_key": "consul"},
+	},
+	"os": {
+		Invalid: map[string]string{"provider": "os", "tag_key": "consul", "tag_value": "server"},
+	},
+	"tencentcloud": {
+		Invalid: map[string]string{"provider": "tencentcloud", "tag_key": "consul", "tag_value": "server"},
+	},
+	"triton": {
+		Invalid: map[string]string{"provider": "triton", "account": "consul", "key_id": "00:00", "tag_key": "consul", "tag_value": "server"},
+	},
+}
+
+// TestProviders runs the suite against every in-tree provider, with the
+// offline fixtures above or in the provider's own package.
+func TestProviders(t *testing.T) {
+	// Make sure the fixtures aren't resolved with configuration from the
+	// environment.
+	for _, env := range []string{"OS_AUTH_URL", "ARM_ENVIRONMENT", "SSH_AUTH_SOCK"} {
+		t.Setenv(env, "")
+	}
+
+	providers := map[string]discover.Provider{
+		"k8s": &k8s.Provider{},
+	}
+	for name, p := range discover.Providers {
+		providers[name] = p
+	}
+
+	for name, p := range providers {
+		t.Run(name, func(t *testing.T) {
+			f, ok := fixtures[name]
+			if !ok {
+				if !packageSuites[name] {
+					t.Fatalf("provider %q has no offline fixtures", name)
+				}
+				t.Skip("runs the suite in its own package")
+			}
+			f.Name = name
+			discovertest.RunConformance(t, p, f)
+		})
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package addrlist parses the address lists read by the file and exec
+// providers.
+package addrlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Parse parses either a JSON array of strings or a list of addresses
+// separated by newlines or spaces in which everything after a '#' is a
+// comment. Data is only parsed as JSON if it is a JSON array, so lines
+// may start with a bracketed IPv6 address. An empty list yields a nil
+// slice.
+func Parse(data []byte) ([]string, error) {
+	var addrs []string
+
+	var list []json.RawMessage
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) && json.Unmarshal(data, &list) == nil {
+		for _, raw := range list {
+			var addr string
+			if err := json.Unmarshal(raw, &addr); err != nil {
+				return nil, fmt.Errorf("invalid JSON address list: %s is not a string", raw)
+			}
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs, nil
+	}
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		addrs = append(addrs, strings.Fields(line)...)
+	}
+	return addrs, nil
+}
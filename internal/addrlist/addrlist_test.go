@@ -0,0 +1,45 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package addrlist_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/go-discover/internal/addrlist"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Data     string
+		Expected []string
+		Err      bool
+	}{
+		{"empty", "", nil, false},
+		{"only comments", "# nothing here\n\n", nil, false},
+		{"lines", "10.0.0.1\n10.0.0.2:8301\n", []string{"10.0.0.1", "10.0.0.2:8301"}, false},
+		{"crlf and comments", "# servers\r\n10.0.0.1 # first\r\n\r\n  10.0.0.2\r\n", []string{"10.0.0.1", "10.0.0.2"}, false},
+		{"spaces", "10.0.0.1 10.0.0.2\t10.0.0.3", []string{"10.0.0.1", "10.0.0.2", "10.0.0.3"}, false},
+		{"json", ` ["10.0.0.1", "", "[::1]:8301"]`, []string{"10.0.0.1", "[::1]:8301"}, false},
+		{"empty json", `[]`, nil, false},
+		{"json null", `null`, []string{"null"}, false},
+		{"bracketed ipv6 lines", "[fd00::1]:8301\n[fd00::2]:8301 # second\n", []string{"[fd00::1]:8301", "[fd00::2]:8301"}, false},
+		{"bracketed ipv6 and ipv4", "  [fd00::1]:8301 10.0.0.1", []string{"[fd00::1]:8301", "10.0.0.1"}, false},
+		{"not a json array", `["10.0.0.1",`, []string{`["10.0.0.1",`}, false},
+		{"json with non-strings", `[1, 2]`, nil, true},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			addrs, err := addrlist.Parse([]byte(tt.Data))
+			if (err != nil) != tt.Err {
+				t.Fatalf("got error %v, want error %v", err, tt.Err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
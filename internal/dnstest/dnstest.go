@@ -0,0 +1,128 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package dnstest provides an in-process DNS server for testing the DNS
+// based providers.
+package dnstest
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// Server is an authoritative DNS server which answers from a fixed set of
+// records over UDP and TCP on the same local port.
+type Server struct {
+	// Addr is the "host:port" address the server listens on.
+	Addr string
+
+	records []dns.RR
+
+	mu      sync.Mutex
+	queries map[string]int
+}
+
+// NewServer starts a server which serves the given records. Records use
+// the zone file format, e.g. "node.example.com. 60 IN A 10.0.0.1". Names
+// without any record are answered with NXDOMAIN. The server is shut down
+// when the test finishes.
+func NewServer(t testing.TB, records ...string) *Server {
+	t.Helper()
+
+	s := &Server{queries: map[string]int{}}
+	for _, r := range records {
+		rr, err := dns.NewRR(r)
+		if err != nil {
+			t.Fatalf("dnstest: invalid record %q: %s", r, err)
+		}
+		s.records = append(s.records, rr)
+	}
+
+	pc, l := listen(t)
+	s.Addr = pc.LocalAddr().String()
+
+	for _, srv := range []*dns.Server{
+		{PacketConn: pc, Handler: s.handler("udp")},
+		{Listener: l, Handler: s.handler("tcp")},
+	} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	return s
+}
+
+// Queries returns the number of queries received over network, which is
+// either "udp" or "tcp".
+func (s *Server) Queries(network string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.queries[network]
+}
+
+func (s *Server) handler(network string) dns.HandlerFunc {
+	return func(w dns.ResponseWriter, req *dns.Msg) {
+		s.mu.Lock()
+		s.queries[network]++
+		s.mu.Unlock()
+
+		m := new(dns.Msg)
+		m.SetReply(req)
+		m.Authoritative = true
+		for _, q := range req.Question {
+			known := false
+			for _, rr := range s.records {
+				h := rr.Header()
+				if !strings.EqualFold(h.Name, q.Name) {
+					continue
+				}
+				known = true
+				if h.Rrtype == q.Qtype || q.Qtype == dns.TypeANY {
+					m.Answer = append(m.Answer, dns.Copy(rr))
+				}
+			}
+			if !known {
+				m.Rcode = dns.RcodeNameError
+			}
+		}
+		w.WriteMsg(m)
+	}
+}
+
+// listen opens a UDP and a TCP socket on the same local port.
+func listen(t testing.TB) (net.PacketConn, net.Listener) {
+	t.Helper()
+	var err error
+	for i := 0; i < 10; i++ {
+		var pc net.PacketConn
+		pc, err = net.ListenPacket("udp", "127.0.0.1:0")
+		if err != nil {
+			break
+		}
+		var l net.Listener
+		l, err = net.Listen("tcp", pc.LocalAddr().String())
+		if err == nil {
+			return pc, l
+		}
+		pc.Close()
+	}
+	t.Fatalf("dnstest: listen: %s", err)
+	return nil, nil
+}
+
+// Blackhole returns the address of a UDP socket which never answers.
+func Blackhole(t testing.TB) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("dnstest: listen: %s", err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	return pc.LocalAddr().String()
+}
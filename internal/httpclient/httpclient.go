@@ -0,0 +1,124 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package httpclient builds the HTTP clients of the providers which talk
+// to an HTTP API directly, such as http, consul, nomad, etcd and docker.
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout is the timeout used when no "timeout" is configured.
+const DefaultTimeout = 10 * time.Second
+
+// TLSEnv names the environment variables which are read for the TLS
+// settings that are not set in the args. Empty names are not read.
+type TLSEnv struct {
+	CAFile     string
+	CertFile   string
+	KeyFile    string
+	Insecure   string
+	ServerName string
+}
+
+// TLSConfig builds a client TLS configuration from the "ca_file",
+// "cert_file", "key_file", "insecure_ssl" and "tls_server_name" args
+// and the environment variables in env.
+func TLSConfig(args map[string]string, env TLSEnv) (*tls.Config, error) {
+	cfg := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: argsOrEnv(args, "tls_server_name", env.ServerName),
+	}
+
+	if v := argsOrEnv(args, "insecure_ssl", env.Insecure); v != "" {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("insecure_ssl must be boolean value: %w", err)
+		}
+		cfg.InsecureSkipVerify = insecure
+	}
+
+	if path := argsOrEnv(args, "ca_file", env.CAFile); path != "" {
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("reading ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_file %s contains no certificates", path)
+		}
+		cfg.RootCAs = pool
+	}
+
+	certFile := argsOrEnv(args, "cert_file", env.CertFile)
+	keyFile := argsOrEnv(args, "key_file", env.KeyFile)
+	if (certFile == "") != (keyFile == "") {
+		return nil, fmt.Errorf("cert_file and key_file must be set together")
+	}
+	if certFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
+// Timeout returns the "timeout" arg, or DefaultTimeout if it isn't set.
+func Timeout(args map[string]string) (time.Duration, error) {
+	v := args["timeout"]
+	if v == "" {
+		return DefaultTimeout, nil
+	}
+	timeout, err := time.ParseDuration(v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout: %w", err)
+	}
+	return timeout, nil
+}
+
+// New returns a client with its own copy of the default transport which
+// uses tlsConfig. Callers should close its idle connections when done.
+func New(tlsConfig *tls.Config) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsConfig
+	return &http.Client{Transport: transport}
+}
+
+// CheckStatus returns an error unless resp has a 2xx status. The error
+// contains the "message" of a JSON error response or the beginning of
+// the body.
+func CheckStatus(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		return nil
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	var e struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	return fmt.Errorf("unexpected status %s: %s", resp.Status, e.Message)
+}
+
+// argsOrEnv returns args[key] if it is set and the environment variable
+// env otherwise.
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok || env == "" {
+		return value
+	}
+	return os.Getenv(env)
+}
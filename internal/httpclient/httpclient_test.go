@@ -0,0 +1,129 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package httpclient_test
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+	"github.com/hashicorp/go-discover/internal/tlstest"
+)
+
+func TestTLSConfig(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t)
+	caFile := ca.WriteCert(t, dir, "ca.pem")
+	certFile, keyFile := ca.Issue(t, "client", x509.ExtKeyUsageClientAuth).Write(t, dir, "client")
+
+	env := httpclient.TLSEnv{
+		CAFile:     "TEST_CACERT",
+		CertFile:   "TEST_CLIENT_CERT",
+		KeyFile:    "TEST_CLIENT_KEY",
+		Insecure:   "TEST_SKIP_VERIFY",
+		ServerName: "TEST_TLS_SERVER_NAME",
+	}
+	t.Setenv("TEST_CACERT", caFile)
+	t.Setenv("TEST_CLIENT_CERT", certFile)
+	t.Setenv("TEST_CLIENT_KEY", keyFile)
+	t.Setenv("TEST_SKIP_VERIFY", "true")
+	t.Setenv("TEST_TLS_SERVER_NAME", "server.test")
+
+	cfg, err := httpclient.TLSConfig(map[string]string{}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RootCAs == nil || len(cfg.Certificates) != 1 || !cfg.InsecureSkipVerify || cfg.ServerName != "server.test" {
+		t.Fatalf("environment not used: %#v", cfg)
+	}
+
+	// Args take precedence over the environment, even when empty.
+	cfg, err = httpclient.TLSConfig(map[string]string{"ca_file": "", "cert_file": "", "key_file": "", "insecure_ssl": ""}, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RootCAs != nil || len(cfg.Certificates) != 0 || cfg.InsecureSkipVerify {
+		t.Fatalf("args not used: %#v", cfg)
+	}
+
+	// Without names no environment variables are read.
+	cfg, err = httpclient.TLSConfig(map[string]string{}, httpclient.TLSEnv{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.RootCAs != nil || len(cfg.Certificates) != 0 || cfg.InsecureSkipVerify || cfg.ServerName != "" {
+		t.Fatalf("environment used: %#v", cfg)
+	}
+
+	cases := []struct {
+		Name string
+		Args map[string]string
+		Err  string
+	}{
+		{"invalid insecure_ssl", map[string]string{"insecure_ssl": "maybe"}, "insecure_ssl must be boolean value"},
+		{"missing ca_file", map[string]string{"ca_file": dir + "/missing.pem"}, "reading ca_file"},
+		{"ca_file without certificates", map[string]string{"ca_file": keyFile}, "contains no certificates"},
+		{"key without certificate", map[string]string{"key_file": keyFile}, "must be set together"},
+		{"mismatched key", map[string]string{"cert_file": caFile, "key_file": keyFile}, "loading client certificate"},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			_, err := httpclient.TLSConfig(tt.Args, httpclient.TLSEnv{})
+			if err == nil || !strings.Contains(err.Error(), tt.Err) {
+				t.Fatalf("got error %v, want %q", err, tt.Err)
+			}
+		})
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	if d, err := httpclient.Timeout(map[string]string{}); err != nil || d != httpclient.DefaultTimeout {
+		t.Fatalf("got %s, %v want default", d, err)
+	}
+	if d, err := httpclient.Timeout(map[string]string{"timeout": "3s"}); err != nil || d != 3*time.Second {
+		t.Fatalf("got %s, %v want 3s", d, err)
+	}
+	if _, err := httpclient.Timeout(map[string]string{"timeout": "3"}); err == nil || !strings.Contains(err.Error(), "invalid timeout") {
+		t.Fatalf("got error %v, want invalid timeout", err)
+	}
+}
+
+func TestCheckStatus(t *testing.T) {
+	cases := []struct {
+		Name   string
+		Status int
+		Body   string
+		Err    string
+	}{
+		{"ok", http.StatusOK, "", ""},
+		{"no content", http.StatusNoContent, "", ""},
+		{"plain text", http.StatusForbidden, "Permission denied\n", "unexpected status 403 Forbidden: Permission denied"},
+		{"json message", http.StatusNotFound, `{"message":"no such network"}`, "unexpected status 404 Not Found: no such network"},
+		{"json without message", http.StatusBadRequest, `{"error":"bad"}`, `unexpected status 400 Bad Request: {"error":"bad"}`},
+	}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			resp := &http.Response{
+				StatusCode: tt.Status,
+				Status:     fmt.Sprintf("%d %s", tt.Status, http.StatusText(tt.Status)),
+				Body:       io.NopCloser(strings.NewReader(tt.Body)),
+			}
+			err := httpclient.CheckStatus(resp)
+			if tt.Err == "" {
+				if err != nil {
+					t.Fatalf("err: %s", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.Err {
+				t.Fatalf("got error %v, want %q", err, tt.Err)
+			}
+		})
+	}
+}
@@ -0,0 +1,14 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package resolver
+
+import "testing"
+
+// SetSystemServers replaces the nameservers of the system configuration
+// for the duration of the test.
+func SetSystemServers(t testing.TB, servers ...string) {
+	orig := systemServers
+	systemServers = func() ([]string, error) { return servers, nil }
+	t.Cleanup(func() { systemServers = orig })
+}
@@ -0,0 +1,146 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package resolver holds the nameserver and transport settings shared by
+// the DNS based providers.
+package resolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// systemServers returns the "host:port" addresses of the nameservers of
+// the system configuration. It is replaced in tests.
+var systemServers = func() ([]string, error) {
+	conf, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err != nil {
+		return nil, fmt.Errorf("no nameserver configured: %w", err)
+	}
+	if len(conf.Servers) == 0 {
+		return nil, fmt.Errorf("no nameserver configured in /etc/resolv.conf")
+	}
+	var servers []string
+	for _, server := range conf.Servers {
+		servers = append(servers, net.JoinHostPort(server, conf.Port))
+	}
+	return servers, nil
+}
+
+// Resolver sends queries to Nameserver using Transport.
+type Resolver struct {
+	// Nameserver is the "host:port" address of the DNS server. If it is
+	// empty the servers of the system configuration are used.
+	Nameserver string
+
+	// Transport is "udp" or "tcp". Queries are sent over UDP by default
+	// and truncated responses are retried over TCP.
+	Transport string
+}
+
+// New validates the nameserver and transport args of a provider. The
+// port of nameserver defaults to 53.
+func New(nameserver, transport string) (*Resolver, error) {
+	switch transport {
+	case "", "udp", "tcp":
+	default:
+		return nil, fmt.Errorf("invalid transport %q, must be \"udp\" or \"tcp\"", transport)
+	}
+	if nameserver != "" {
+		if _, _, err := net.SplitHostPort(nameserver); err != nil {
+			nameserver = net.JoinHostPort(nameserver, "53")
+		}
+	}
+	return &Resolver{Nameserver: nameserver, Transport: transport}, nil
+}
+
+// Net returns a net.Resolver which uses the settings of r. If neither a
+// nameserver nor a transport is set the default resolver is returned.
+func (r *Resolver) Net() *net.Resolver {
+	if r.Nameserver == "" && r.Transport == "" {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if r.Nameserver != "" {
+				address = r.Nameserver
+			}
+			// The resolver retries truncated UDP responses over TCP, so
+			// only force the transport when TCP is requested.
+			if r.Transport == "tcp" {
+				network = "tcp"
+			}
+			var d net.Dialer
+			return d.DialContext(ctx, network, address)
+		},
+	}
+}
+
+// Exchange sends the query m to the nameserver and returns the response.
+// Without a nameserver the servers of the system configuration are
+// tried in turn until one answers. Truncated UDP responses are retried
+// over TCP like the Go resolver does.
+func (r *Resolver) Exchange(ctx context.Context, m *dns.Msg) (*dns.Msg, error) {
+	servers := []string{r.Nameserver}
+	if r.Nameserver == "" {
+		var err error
+		if servers, err = systemServers(); err != nil {
+			return nil, err
+		}
+	}
+
+	network := r.Transport
+	if network == "" {
+		network = "udp"
+	}
+
+	var errs []error
+	for _, server := range servers {
+		c := &dns.Client{Net: network}
+		in, _, err := c.ExchangeContext(ctx, m, server)
+		if err == nil && in.Truncated && network == "udp" {
+			c.Net = "tcp"
+			in, _, err = c.ExchangeContext(ctx, m, server)
+		}
+		if err == nil {
+			return in, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+	}
+	return nil, errors.Join(errs...)
+}
+
+// SortSRV orders records by ascending priority and, within the same
+// priority, by descending weight. Ties are broken by target and port so
+// that the order does not depend on the order of the DNS response.
+//
+// RFC 2782 asks clients to pick records of the same priority at random
+// in proportion to their weight. For a list of peers to join a stable
+// order is more useful, so the record with the highest weight comes
+// first instead.
+func SortSRV(records []*net.SRV) {
+	sort.SliceStable(records, func(i, j int) bool {
+		a, b := records[i], records[j]
+		if a.Priority != b.Priority {
+			return a.Priority < b.Priority
+		}
+		if a.Weight != b.Weight {
+			return a.Weight > b.Weight
+		}
+		if t := strings.Compare(strings.ToLower(a.Target), strings.ToLower(b.Target)); t != 0 {
+			return t < 0
+		}
+		return a.Port < b.Port
+	})
+}
@@ -0,0 +1,129 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package resolver_test
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/internal/dnstest"
+	"github.com/hashicorp/go-discover/internal/resolver"
+	"github.com/miekg/dns"
+)
+
+func TestNew(t *testing.T) {
+	cases := []struct {
+		Name       string
+		Nameserver string
+		Transport  string
+		Expected   *resolver.Resolver
+		Err        string
+	}{
+		{"system", "", "", &resolver.Resolver{}, ""},
+		{"default port", "10.0.0.2", "", &resolver.Resolver{Nameserver: "10.0.0.2:53"}, ""},
+		{"ipv6 default port", "fd00::2", "tcp", &resolver.Resolver{Nameserver: "[fd00::2]:53", Transport: "tcp"}, ""},
+		{"port", "10.0.0.2:5353", "udp", &resolver.Resolver{Nameserver: "10.0.0.2:5353", Transport: "udp"}, ""},
+		{"invalid transport", "", "sctp", nil, `invalid transport "sctp"`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			r, err := resolver.New(tt.Nameserver, tt.Transport)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(r, tt.Expected) {
+				t.Fatalf("got %#v want %#v", r, tt.Expected)
+			}
+		})
+	}
+}
+
+func TestNet(t *testing.T) {
+	if r := (&resolver.Resolver{}).Net(); r != net.DefaultResolver {
+		t.Fatal("want the default resolver without settings")
+	}
+	if r := (&resolver.Resolver{Transport: "tcp"}).Net(); r == net.DefaultResolver || !r.PreferGo {
+		t.Fatal("want a Go resolver with a transport")
+	}
+}
+
+// closedServer returns the address of a UDP port nobody listens on.
+func closedServer(t *testing.T) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := pc.LocalAddr().String()
+	pc.Close()
+	return addr
+}
+
+func TestExchangeSystemServers(t *testing.T) {
+	srv := dnstest.NewServer(t, "node.example.test. 60 IN A 10.0.0.1")
+	closed := closedServer(t)
+
+	m := new(dns.Msg)
+	m.SetQuestion("node.example.test.", dns.TypeA)
+
+	// The servers are tried in turn until one answers.
+	resolver.SetSystemServers(t, closed, srv.Addr)
+	in, err := (&resolver.Resolver{}).Exchange(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Answer) != 1 || in.Answer[0].(*dns.A).A.String() != "10.0.0.1" {
+		t.Fatalf("bad: %v", in.Answer)
+	}
+
+	resolver.SetSystemServers(t, closed)
+	if _, err := (&resolver.Resolver{}).Exchange(context.Background(), m); err == nil || !strings.Contains(err.Error(), closed) {
+		t.Fatalf("got error %v, want the failed server", err)
+	}
+
+	// A configured nameserver replaces the system servers.
+	in, err = (&resolver.Resolver{Nameserver: srv.Addr, Transport: "tcp"}).Exchange(context.Background(), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(in.Answer) != 1 || srv.Queries("tcp") != 1 {
+		t.Fatalf("bad: %v, %d TCP queries", in.Answer, srv.Queries("tcp"))
+	}
+}
+
+func TestSortSRV(t *testing.T) {
+	records := []*net.SRV{
+		{Target: "c.example.test.", Port: 8300, Priority: 20, Weight: 10},
+		{Target: "b.example.test.", Port: 8300, Priority: 10, Weight: 5},
+		{Target: "B.example.test.", Port: 8301, Priority: 10, Weight: 10},
+		{Target: "a.example.test.", Port: 8301, Priority: 10, Weight: 10},
+		{Target: "a.example.test.", Port: 8300, Priority: 10, Weight: 10},
+	}
+	resolver.SortSRV(records)
+
+	var got []string
+	for _, r := range records {
+		got = append(got, net.JoinHostPort(r.Target, strconv.Itoa(int(r.Port))))
+	}
+	want := []string{
+		"a.example.test.:8300",
+		"a.example.test.:8301",
+		"B.example.test.:8301",
+		"b.example.test.:8300",
+		"c.example.test.:8300",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %v want %v", got, want)
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package txtfilter matches the TXT records of DNS-SD service instances
+// against the txt_filter of the dnssd and mdns providers.
+package txtfilter
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Filter is a set of TXT record attributes which a service instance
+// must have. Keys are compared case insensitively and values exactly as
+// described in RFC 6763, section 6.4.
+type Filter map[string]*string
+
+// Parse parses a comma separated list of "key=value" pairs.
+// A key without "=" only requires the attribute to be present and
+// "key=" requires it to have an empty value.
+func Parse(s string) (Filter, error) {
+	f := Filter{}
+	for _, kv := range strings.Split(s, ",") {
+		if kv = strings.TrimSpace(kv); kv == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(kv, "=")
+		if k == "" {
+			return nil, fmt.Errorf("invalid txt_filter %q: empty key", kv)
+		}
+		k = strings.ToLower(k)
+		if !ok {
+			f[k] = nil
+			continue
+		}
+		f[k] = &v
+	}
+	return f, nil
+}
+
+// Empty reports whether the filter matches every instance.
+func (f Filter) Empty() bool {
+	return len(f) == 0
+}
+
+// Match reports whether the TXT record strings txt have all attributes
+// of the filter. Only the first occurrence of a key counts.
+func (f Filter) Match(txt []string) bool {
+	attrs := map[string]*string{}
+	for _, s := range txt {
+		k, v, ok := strings.Cut(s, "=")
+		if k = strings.ToLower(k); k == "" {
+			continue
+		}
+		if _, dup := attrs[k]; dup {
+			continue
+		}
+		if !ok {
+			attrs[k] = nil
+			continue
+		}
+		attrs[k] = &v
+	}
+
+	for k, want := range f {
+		got, ok := attrs[k]
+		if !ok {
+			return false
+		}
+		if want != nil && (got == nil || *got != *want) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,48 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package txtfilter_test
+
+import (
+	"testing"
+
+	"github.com/hashicorp/go-discover/internal/txtfilter"
+)
+
+func TestMatch(t *testing.T) {
+	cases := []struct {
+		Filter string
+		TXT    []string
+		Match  bool
+	}{
+		{"", nil, true},
+		{"role=server", []string{"role=server"}, true},
+		{"role=server", []string{"ROLE=server"}, true},
+		{"role=server", []string{"role=client"}, false},
+		{"role=server", []string{"role"}, false},
+		{"role=server", []string{"role=client", "role=server"}, false},
+		{"role", []string{"role=client"}, true},
+		{"role", []string{"role"}, true},
+		{"role=", []string{"role="}, true},
+		{"role=", []string{"role"}, false},
+		{"role=server,dc=dc1", []string{"dc=dc1", "role=server"}, true},
+		{"role=server,dc=dc1", []string{"role=server"}, false},
+		{"path=/a=b", []string{"path=/a=b"}, true},
+	}
+
+	for _, tt := range cases {
+		f, err := txtfilter.Parse(tt.Filter)
+		if err != nil {
+			t.Fatalf("%q: %s", tt.Filter, err)
+		}
+		if got := f.Match(tt.TXT); got != tt.Match {
+			t.Fatalf("%q.Match(%q) = %v, want %v", tt.Filter, tt.TXT, got, tt.Match)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	if _, err := txtfilter.Parse("=dc1"); err == nil {
+		t.Fatal("expected an error for an empty key")
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/autoscaling"
+	asgtypes "github.com/aws/aws-sdk-go-v2/service/autoscaling/types"
+)
+
+// asgInstanceIDs returns the ids of the instances of the Auto Scaling
+// groups selected by asg_name, tag_key/tag_value and tags which are in
+// one of the lifecycle states of lifecycle_state.
+func asgInstanceIDs(ctx context.Context, cfg aws.Config, endpoint string, args map[string]string, l *log.Logger) ([]string, error) {
+	match, err := lifecycleStates(args["lifecycle_state"])
+	if err != nil {
+		return nil, err
+	}
+
+	input := &autoscaling.DescribeAutoScalingGroupsInput{
+		AutoScalingGroupNames: splitList(args["asg_name"]),
+		MaxRecords:            aws.Int32(100),
+	}
+	filters, err := tagFilters(args)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range filters {
+		input.Filters = append(input.Filters, asgtypes.Filter{Name: f.Name, Values: f.Values})
+	}
+	l.Printf("[INFO] discover-aws: Filter Auto Scaling groups with asg_name=%s filters=%d", args["asg_name"], len(filters))
+
+	svc := autoscaling.NewFromConfig(cfg, func(o *autoscaling.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	var ids []string
+	paginator := autoscaling.NewDescribeAutoScalingGroupsPaginator(svc, input)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("discover-aws: DescribeAutoScalingGroups failed: %s", err)
+		}
+
+		for _, g := range page.AutoScalingGroups {
+			l.Printf("[DEBUG] discover-aws: Auto Scaling group %s has %d instances", aws.ToString(g.AutoScalingGroupName), len(g.Instances))
+			for _, inst := range g.Instances {
+				id := aws.ToString(inst.InstanceId)
+				if !match(string(inst.LifecycleState)) {
+					l.Printf("[DEBUG] discover-aws: Ignoring instance %s in lifecycle state %s", id, inst.LifecycleState)
+					continue
+				}
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	l.Printf("[DEBUG] discover-aws: Found %d Auto Scaling instances", len(ids))
+	return ids, nil
+}
+
+// lifecycleStates returns a function which reports whether a lifecycle
+// state is selected by the comma separated list of states v. States
+// prefixed with "!" are excluded, and if only exclusions are given all
+// other states are included.
+func lifecycleStates(v string) (func(string) bool, error) {
+	if v == "" {
+		v = "InService"
+	}
+
+	all := false
+	include := map[string]bool{}
+	exclude := map[string]bool{}
+	for _, s := range strings.Split(v, ",") {
+		s = strings.TrimSpace(s)
+		switch {
+		case s == "":
+		case s == "all":
+			all = true
+		case strings.HasPrefix(s, "!"):
+			if s = strings.TrimPrefix(s, "!"); s == "" {
+				return nil, fmt.Errorf("discover-aws: invalid lifecycle_state %q", v)
+			}
+			exclude[s] = true
+		default:
+			include[s] = true
+		}
+	}
+	if len(include) == 0 {
+		all = true
+	}
+
+	return func(state string) bool {
+		if exclude[state] {
+			return false
+		}
+		return all || include[state]
+	}, nil
+}
@@ -0,0 +1,209 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+// testGroup is an Auto Scaling group served by the fake Auto Scaling API.
+type testGroup struct {
+	Name      string
+	Tags      map[string]string
+	Instances [][2]string // instance id and lifecycle state
+}
+
+var testGroups = []testGroup{
+	{
+		Name: "consul-a",
+		Tags: map[string]string{"role": "consul"},
+		Instances: [][2]string{
+			{"i-1", "InService"},
+			{"i-2", "Terminating:Wait"},
+		},
+	},
+	{
+		Name: "consul-b",
+		Tags: map[string]string{"role": "consul"},
+		Instances: [][2]string{
+			{"i-3", "InService"},
+			{"i-4", "Pending"},
+		},
+	},
+	{
+		Name:      "web",
+		Tags:      map[string]string{"role": "web"},
+		Instances: [][2]string{{"i-5", "InService"}},
+	},
+}
+
+func (f *fakeAWS) describeAutoScalingGroups(w http.ResponseWriter, form url.Values) {
+	var names []string
+	for n := 1; form.Get(fmt.Sprintf("AutoScalingGroupNames.member.%d", n)) != ""; n++ {
+		names = append(names, form.Get(fmt.Sprintf("AutoScalingGroupNames.member.%d", n)))
+	}
+	var filters []ec2Filter
+	for n := 1; form.Get(fmt.Sprintf("Filters.member.%d.Name", n)) != ""; n++ {
+		filter := ec2Filter{Name: form.Get(fmt.Sprintf("Filters.member.%d.Name", n))}
+		for m := 1; form.Get(fmt.Sprintf("Filters.member.%d.Values.member.%d", n, m)) != ""; m++ {
+			filter.Values = append(filter.Values, form.Get(fmt.Sprintf("Filters.member.%d.Values.member.%d", n, m)))
+		}
+		filters = append(filters, filter)
+	}
+
+	var matched []testGroup
+Groups:
+	for _, g := range f.Groups {
+		if len(names) > 0 {
+			found := false
+			for _, name := range names {
+				found = found || name == g.Name
+			}
+			if !found {
+				continue
+			}
+		}
+		for _, filter := range filters {
+			if !filter.match(testInstance{Tags: g.Tags}) {
+				continue Groups
+			}
+		}
+		matched = append(matched, g)
+	}
+
+	// Serve a single group per page to exercise the pagination.
+	start := 0
+	if tok := form.Get("NextToken"); tok != "" {
+		fmt.Sscan(tok, &start)
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, `<DescribeAutoScalingGroupsResponse xmlns="http://autoscaling.amazonaws.com/doc/2011-01-01/"><DescribeAutoScalingGroupsResult><AutoScalingGroups>`)
+	if start < len(matched) {
+		g := matched[start]
+		fmt.Fprintf(w, `<member><AutoScalingGroupName>%s</AutoScalingGroupName><Instances>`, g.Name)
+		for _, inst := range g.Instances {
+			fmt.Fprintf(w, `<member><InstanceId>%s</InstanceId><LifecycleState>%s</LifecycleState><HealthStatus>Healthy</HealthStatus></member>`, inst[0], inst[1])
+		}
+		fmt.Fprint(w, `</Instances></member>`)
+	}
+	fmt.Fprint(w, `</AutoScalingGroups>`)
+	if start+1 < len(matched) {
+		fmt.Fprintf(w, `<NextToken>%d</NextToken>`, start+1)
+	}
+	fmt.Fprint(w, `</DescribeAutoScalingGroupsResult></DescribeAutoScalingGroupsResponse>`)
+}
+
+func TestAddrsASG(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+
+	// All instances of the groups are running, their lifecycle state is
+	// only known to Auto Scaling.
+	var instances []testInstance
+	for i := 1; i <= 5; i++ {
+		instances = append(instances, testInstance{
+			ID:        fmt.Sprintf("i-%d", i),
+			State:     "running",
+			PrivateIP: fmt.Sprintf("10.0.0.%d", i),
+			PublicIP:  fmt.Sprintf("198.51.100.%d", i),
+		})
+	}
+	fake := &fakeAWS{Instances: instances, Groups: testGroups}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"asg name",
+			map[string]string{"asg_name": "consul-a"},
+			[]string{"10.0.0.1"},
+			"",
+		},
+		{
+			"asg names",
+			map[string]string{"service": "asg", "asg_name": "consul-a, consul-b", "addr_type": "public_v4"},
+			[]string{"198.51.100.1", "198.51.100.3"},
+			"",
+		},
+		{
+			"tags",
+			map[string]string{"service": "asg", "tags": "role=consul"},
+			[]string{"10.0.0.1", "10.0.0.3"},
+			"",
+		},
+		{
+			"lifecycle states",
+			map[string]string{"asg_name": "consul-a,consul-b", "lifecycle_state": "InService,Pending"},
+			[]string{"10.0.0.1", "10.0.0.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"excluded lifecycle state",
+			map[string]string{"asg_name": "consul-a,consul-b", "lifecycle_state": "!Terminating:Wait"},
+			[]string{"10.0.0.1", "10.0.0.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"all lifecycle states",
+			map[string]string{"tags": "role=consul", "service": "asg", "lifecycle_state": "all"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"no instances",
+			map[string]string{"asg_name": "vault"},
+			nil,
+			"",
+		},
+		{
+			"invalid lifecycle state",
+			map[string]string{"asg_name": "consul-a", "lifecycle_state": "!"},
+			nil,
+			"invalid lifecycle_state",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+
+	// The Auto Scaling request is signed for its own service.
+	want := "AWS4-HMAC-SHA256 Credential=AKIDTEST/"
+	if auth := fake.auth["DescribeAutoScalingGroups"]; !strings.HasPrefix(auth, want) || !strings.Contains(auth, "/us-east-1/autoscaling/aws4_request") {
+		t.Fatalf("bad Authorization header %q", auth)
+	}
+}
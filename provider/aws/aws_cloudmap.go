@@ -0,0 +1,109 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/servicediscovery"
+	sdtypes "github.com/aws/aws-sdk-go-v2/service/servicediscovery/types"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// cloudMapAddrs discovers the addresses of the instances of the Cloud Map
+// service cloudmap_service in the namespace cloudmap_namespace.
+func cloudMapAddrs(ctx context.Context, cfg aws.Config, addrType string, args map[string]string, l *log.Logger) ([]string, error) {
+	namespace, service := args["cloudmap_namespace"], args["cloudmap_service"]
+	if namespace == "" || service == "" {
+		return nil, fmt.Errorf("discover-aws: cloudmap_namespace and cloudmap_service are required for service \"cloudmap\"")
+	}
+	input := &servicediscovery.DiscoverInstancesInput{
+		NamespaceName: aws.String(namespace),
+		ServiceName:   aws.String(service),
+		// The maximum, DiscoverInstances isn't paginated.
+		MaxResults: aws.Int32(1000),
+	}
+
+	for _, attr := range strings.Split(args["cloudmap_attributes"], ",") {
+		if attr = strings.TrimSpace(attr); attr == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(attr, "=")
+		if k = strings.TrimSpace(k); !ok || k == "" {
+			return nil, fmt.Errorf("discover-aws: invalid cloudmap_attributes %q, must be \"key=value\"", attr)
+		}
+		if input.QueryParameters == nil {
+			input.QueryParameters = map[string]string{}
+		}
+		input.QueryParameters[k] = strings.TrimSpace(v)
+	}
+
+	switch status := sdtypes.HealthStatusFilter(strings.ToUpper(args["cloudmap_health_status"])); status {
+	case "", sdtypes.HealthStatusFilterHealthy, sdtypes.HealthStatusFilterUnhealthy, sdtypes.HealthStatusFilterAll, sdtypes.HealthStatusFilterHealthyOrElseAll:
+		input.HealthStatus = status
+	default:
+		return nil, fmt.Errorf("discover-aws: invalid cloudmap_health_status %q", args["cloudmap_health_status"])
+	}
+
+	// IPv6 addresses are registered with a separate attribute and are
+	// private or public by their scope like for EC2.
+	ipAttr := "AWS_INSTANCE_IPV4"
+	ipv6 := addrType == "private_v6" || addrType == "public_v6"
+	if ipv6 {
+		ipAttr = "AWS_INSTANCE_IPV6"
+	}
+
+	endpoint := args["endpoint"]
+	svc := servicediscovery.NewFromConfig(cfg, func(o *servicediscovery.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			// DiscoverInstances is sent to the "data-" host of the
+			// service, which would change a custom endpoint.
+			o.APIOptions = append(o.APIOptions, disableHostPrefix)
+			l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
+		}
+	})
+
+	l.Printf("[INFO] discover-aws: Discover Cloud Map instances of %s.%s with %v health_status=%s", service, namespace, input.QueryParameters, input.HealthStatus)
+	resp, err := svc.DiscoverInstances(ctx, input)
+	if err != nil {
+		return nil, fmt.Errorf("discover-aws: DiscoverInstances failed: %s", err)
+	}
+
+	var addrs []string
+	for _, inst := range resp.Instances {
+		ip := inst.Attributes[ipAttr]
+		if ip == "" {
+			l.Printf("[DEBUG] discover-aws: Cloud Map instance %s has no %s", aws.ToString(inst.InstanceId), ipAttr)
+			continue
+		}
+		if ipv6 && !isIPv6Scope(ip, addrType == "private_v6") {
+			l.Printf("[DEBUG] discover-aws: Cloud Map instance %s has no %s address", aws.ToString(inst.InstanceId), addrType)
+			continue
+		}
+		if port := inst.Attributes["AWS_INSTANCE_PORT"]; port != "" {
+			ip = net.JoinHostPort(ip, port)
+		}
+		l.Printf("[INFO] discover-aws: Cloud Map instance %s has address %s", aws.ToString(inst.InstanceId), ip)
+		addrs = append(addrs, ip)
+	}
+
+	l.Printf("[DEBUG] discover-aws: Found ip addresses: %v", addrs)
+	return addrs, nil
+}
+
+// disableHostPrefix keeps the SDK from prefixing the host of the
+// endpoint of an operation.
+func disableHostPrefix(stack *middleware.Stack) error {
+	return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("DisableHostPrefix",
+		func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (middleware.InitializeOutput, middleware.Metadata, error) {
+			return next.HandleInitialize(smithyhttp.DisableEndpointHostPrefix(ctx, true), in)
+		}), middleware.Before)
+}
@@ -0,0 +1,222 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+// testCloudMapInstance is an instance registered in the fake Cloud Map.
+type testCloudMapInstance struct {
+	Namespace  string
+	Service    string
+	ID         string
+	Health     string
+	Attributes map[string]string
+}
+
+var testCloudMapInstances = []testCloudMapInstance{
+	{
+		Namespace: "example.local", Service: "consul", ID: "consul-1", Health: "HEALTHY",
+		Attributes: map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.1", "AWS_INSTANCE_IPV6": "2001:db8::1", "AWS_INSTANCE_PORT": "8301", "role": "server"},
+	},
+	{
+		Namespace: "example.local", Service: "consul", ID: "consul-2", Health: "UNHEALTHY",
+		Attributes: map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.2", "AWS_INSTANCE_IPV6": "fd00::2", "AWS_INSTANCE_PORT": "8301", "role": "server"},
+	},
+	{
+		Namespace: "example.local", Service: "consul", ID: "consul-3", Health: "HEALTHY",
+		Attributes: map[string]string{"AWS_INSTANCE_IPV4": "10.0.0.3", "role": "client"},
+	},
+	{
+		Namespace: "example.local", Service: "web", ID: "web-1", Health: "UNHEALTHY",
+		Attributes: map[string]string{"AWS_INSTANCE_IPV4": "10.0.1.1"},
+	},
+	{
+		Namespace: "example.local", Service: "web", ID: "web-2", Health: "UNHEALTHY",
+		Attributes: map[string]string{"AWS_INSTANCE_CNAME": "web.example.com"},
+	},
+}
+
+// cloudMap serves the Cloud Map data plane JSON API action.
+func (f *fakeAWS) cloudMap(w http.ResponseWriter, r *http.Request, action string) {
+	var req struct {
+		NamespaceName   string
+		ServiceName     string
+		MaxResults      int
+		QueryParameters map[string]string
+		HealthStatus    string
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || action != "DiscoverInstances" {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, `{"__type":"com.amazonaws.servicediscovery#InvalidInput","message":"invalid request"}`)
+		return
+	}
+
+	found := false
+	var healthy, all []interface{}
+Instances:
+	for _, inst := range f.CloudMap {
+		if inst.Namespace != req.NamespaceName || inst.Service != req.ServiceName {
+			continue
+		}
+		found = true
+		for k, v := range req.QueryParameters {
+			if inst.Attributes[k] != v {
+				continue Instances
+			}
+		}
+		item := map[string]interface{}{
+			"InstanceId":    inst.ID,
+			"NamespaceName": inst.Namespace,
+			"ServiceName":   inst.Service,
+			"HealthStatus":  inst.Health,
+			"Attributes":    inst.Attributes,
+		}
+		all = append(all, item)
+		if inst.Health == "HEALTHY" {
+			healthy = append(healthy, item)
+		}
+	}
+	if !found {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"com.amazonaws.servicediscovery#ServiceNotFound","message":"Service not found: %s"}`, req.ServiceName)
+		return
+	}
+
+	instances := healthy
+	switch req.HealthStatus {
+	case "UNHEALTHY":
+		instances = nil
+		for _, item := range all {
+			if item.(map[string]interface{})["HealthStatus"] == "UNHEALTHY" {
+				instances = append(instances, item)
+			}
+		}
+	case "ALL":
+		instances = all
+	case "HEALTHY_OR_ELSE_ALL":
+		if len(healthy) == 0 {
+			instances = all
+		}
+	}
+	if len(instances) > req.MaxResults {
+		instances = instances[:req.MaxResults]
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"Instances": instances, "InstancesRevision": 1})
+}
+
+func TestAddrsCloudMap(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	fake := &fakeAWS{CloudMap: testCloudMapInstances}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"healthy",
+			map[string]string{"cloudmap_service": "consul"},
+			[]string{"10.0.0.1:8301", "10.0.0.3"},
+			"",
+		},
+		{
+			"attributes",
+			map[string]string{"cloudmap_service": "consul", "cloudmap_attributes": "role=server", "cloudmap_health_status": "all"},
+			[]string{"10.0.0.1:8301", "10.0.0.2:8301"},
+			"",
+		},
+		{
+			"unhealthy",
+			map[string]string{"cloudmap_service": "consul", "cloudmap_health_status": "unhealthy"},
+			[]string{"10.0.0.2:8301"},
+			"",
+		},
+		{
+			"healthy or else all",
+			map[string]string{"cloudmap_service": "web", "cloudmap_health_status": "healthy_or_else_all"},
+			[]string{"10.0.1.1"},
+			"",
+		},
+		{
+			"public IPv6",
+			map[string]string{"cloudmap_service": "consul", "addr_type": "public_v6", "cloudmap_health_status": "all"},
+			[]string{"[2001:db8::1]:8301"},
+			"",
+		},
+		{
+			"private IPv6",
+			map[string]string{"cloudmap_service": "consul", "addr_type": "private_v6", "cloudmap_health_status": "all"},
+			[]string{"[fd00::2]:8301"},
+			"",
+		},
+		{
+			"unknown service",
+			map[string]string{"cloudmap_service": "vault"},
+			nil,
+			"ServiceNotFound: Service not found: vault",
+		},
+		{
+			"missing service",
+			nil,
+			nil,
+			"cloudmap_namespace and cloudmap_service are required",
+		},
+		{
+			"invalid attributes",
+			map[string]string{"cloudmap_service": "consul", "cloudmap_attributes": "role"},
+			nil,
+			"invalid cloudmap_attributes",
+		},
+		{
+			"invalid health status",
+			map[string]string{"cloudmap_service": "consul", "cloudmap_health_status": "passing"},
+			nil,
+			"invalid cloudmap_health_status",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			args["service"] = "cloudmap"
+			args["cloudmap_namespace"] = "example.local"
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+
+	if auth := fake.auth["DiscoverInstances"]; !strings.Contains(auth, "/us-east-1/servicediscovery/aws4_request") {
+		t.Fatalf("got Authorization %q", auth)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+// loadConfig loads the SDK configuration for region with the credentials
+// selected by args. Only the credential mode is logged, never the
+// secrets.
+func loadConfig(ctx context.Context, region, addrType string, args map[string]string, l *log.Logger) (aws.Config, error) {
+	accessKey := args["access_key_id"]
+	secretKey := args["secret_access_key"]
+	sessionToken := args["session_token"]
+	profile := args["profile"]
+	roleARN := args["role_arn"]
+	tokenFile := args["web_identity_token_file"]
+
+	if tokenFile != "" && roleARN == "" {
+		return aws.Config{}, fmt.Errorf("discover-aws: web_identity_token_file requires role_arn")
+	}
+
+	opts := []func(*config.LoadOptions) error{config.WithRegion(region)}
+
+	_, found := aws.GetUseDualStackEndpoint()
+	static := accessKey != "" && secretKey != ""
+	if found && !(static && (addrType == "public_v4" || addrType == "private_v4")) {
+		opts = append(opts, config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled))
+	}
+
+	switch {
+	case static:
+		l.Printf("[INFO] discover-aws: Using static credentials provider")
+		staticCreds := credentials.NewStaticCredentialsProvider(accessKey, secretKey, sessionToken)
+		opts = append(opts, config.WithCredentialsProvider(aws.NewCredentialsCache(staticCreds)))
+	case profile != "":
+		l.Printf("[INFO] discover-aws: Using shared config profile %s", profile)
+		opts = append(opts, config.WithSharedConfigProfile(profile))
+	case tokenFile != "":
+		// The web identity token replaces the credentials.
+	default:
+		l.Printf("[INFO] discover-aws: Using default credential chain")
+	}
+
+	cfg, err := config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return aws.Config{}, fmt.Errorf("discover-aws: unable to load SDK config, %s", err)
+	}
+
+	if roleARN == "" {
+		return cfg, nil
+	}
+
+	sessionName := args["role_session_name"]
+	if sessionName == "" {
+		sessionName = "go-discover"
+	}
+	svc := sts.NewFromConfig(cfg, func(o *sts.Options) {
+		if endpoint := args["sts_endpoint"]; endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			l.Printf("[INFO] discover-aws: STS endpoint is %s", endpoint)
+		}
+	})
+
+	if tokenFile != "" {
+		l.Printf("[INFO] discover-aws: Assuming role %s with web identity token file %s", roleARN, tokenFile)
+		cfg.Credentials = aws.NewCredentialsCache(stscreds.NewWebIdentityRoleProvider(
+			svc, roleARN, stscreds.IdentityTokenFile(tokenFile),
+			func(o *stscreds.WebIdentityRoleOptions) {
+				o.RoleSessionName = sessionName
+			}))
+		return cfg, nil
+	}
+
+	externalID := args["external_id"]
+	if externalID != "" {
+		l.Printf("[INFO] discover-aws: Assuming role %s with external id", roleARN)
+	} else {
+		l.Printf("[INFO] discover-aws: Assuming role %s", roleARN)
+	}
+	cfg.Credentials = aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(
+		svc, roleARN,
+		func(o *stscreds.AssumeRoleOptions) {
+			o.RoleSessionName = sessionName
+			if externalID != "" {
+				o.ExternalID = aws.String(externalID)
+			}
+		}))
+	return cfg, nil
+}
@@ -0,0 +1,174 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+// assumeRole answers an STS AssumeRole or AssumeRoleWithWebIdentity
+// request with the temporary access key ASIATEMP.
+func (f *fakeAWS) assumeRole(w http.ResponseWriter, action string) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprintf(w, `<%[1]sResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/"><%[1]sResult>`+
+		`<Credentials><AccessKeyId>ASIATEMP</AccessKeyId><SecretAccessKey>temp-secret</SecretAccessKey>`+
+		`<SessionToken>temp-token</SessionToken><Expiration>%[2]s</Expiration></Credentials>`+
+		`<AssumedRoleUser><Arn>arn:aws:sts::123456789012:assumed-role/discover/go-discover</Arn><AssumedRoleId>AROATEST:go-discover</AssumedRoleId></AssumedRoleUser>`+
+		`</%[1]sResult></%[1]sResponse>`, action, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+}
+
+func TestAddrsCredentials(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+
+	// Isolate the tests from the credentials of the environment.
+	dir := t.TempDir()
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ROLE_ARN", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", "")
+	t.Setenv("AWS_EC2_METADATA_DISABLED", "true")
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+
+	creds := "[discover]\naws_access_key_id = AKIDPROFILE\naws_secret_access_key = profile-secret\n"
+	if err := os.WriteFile(filepath.Join(dir, "credentials"), []byte(creds), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("web-identity-token"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	roleARN := "arn:aws:iam::123456789012:role/discover"
+	cases := []struct {
+		Name   string
+		Args   map[string]string
+		Key    string            // access key the EC2 request is signed with
+		Action string            // STS action
+		Params map[string]string // expected STS parameters
+		Err    string
+	}{
+		{
+			"static",
+			map[string]string{"access_key_id": "AKIDSTATIC", "secret_access_key": "static-secret"},
+			"AKIDSTATIC",
+			"",
+			nil,
+			"",
+		},
+		{
+			"profile",
+			map[string]string{"profile": "discover"},
+			"AKIDPROFILE",
+			"",
+			nil,
+			"",
+		},
+		{
+			"assume role",
+			map[string]string{
+				"access_key_id":     "AKIDSTATIC",
+				"secret_access_key": "static-secret",
+				"role_arn":          roleARN,
+				"external_id":       "external-secret",
+			},
+			"ASIATEMP",
+			"AssumeRole",
+			map[string]string{"RoleArn": roleARN, "ExternalId": "external-secret", "RoleSessionName": "go-discover"},
+			"",
+		},
+		{
+			"assume role with profile",
+			map[string]string{"profile": "discover", "role_arn": roleARN, "role_session_name": "consul"},
+			"ASIATEMP",
+			"AssumeRole",
+			map[string]string{"RoleArn": roleARN, "RoleSessionName": "consul"},
+			"",
+		},
+		{
+			"web identity",
+			map[string]string{"role_arn": roleARN, "web_identity_token_file": tokenFile},
+			"ASIATEMP",
+			"AssumeRoleWithWebIdentity",
+			map[string]string{"RoleArn": roleARN, "WebIdentityToken": "web-identity-token", "RoleSessionName": "go-discover"},
+			"",
+		},
+		{
+			"web identity without role",
+			map[string]string{"web_identity_token_file": tokenFile},
+			"",
+			"",
+			nil,
+			"web_identity_token_file requires role_arn",
+		},
+	}
+
+	p := &aws.Provider{}
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			fake := &fakeAWS{Instances: testInstances}
+			srv := httptest.NewServer(fake)
+			defer srv.Close()
+
+			args := localArgs(srv.URL)
+			delete(args, "access_key_id")
+			delete(args, "secret_access_key")
+			args["tags"] = "consul=server"
+			args["sts_endpoint"] = srv.URL
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+
+			var buf bytes.Buffer
+			l := log.New(&buf, "", 0)
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s\n%s", err, buf.String())
+			}
+			if want := []string{"10.0.0.1", "10.0.0.2", "10.0.1.3"}; !reflect.DeepEqual(addrs, want) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+
+			if auth := fake.auth["DescribeInstances"]; !strings.Contains(auth, "Credential="+tt.Key+"/") {
+				t.Fatalf("DescribeInstances signed with %q, want %s", auth, tt.Key)
+			}
+			if tt.Action != "" {
+				form := fake.forms[tt.Action]
+				if form == nil {
+					t.Fatalf("no %s request", tt.Action)
+				}
+				for k, v := range tt.Params {
+					if got := form.Get(k); got != v {
+						t.Fatalf("got %s=%q want %q", k, got, v)
+					}
+				}
+			}
+
+			for _, secret := range []string{"static-secret", "profile-secret", "external-secret", "web-identity-token", "temp-secret"} {
+				if strings.Contains(buf.String(), secret) {
+					t.Fatalf("log leaks %q:\n%s", secret, buf.String())
+				}
+			}
+		})
+	}
+}
@@ -11,17 +11,17 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
-	"github.com/aws/aws-sdk-go-v2/config"
-	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/feature/ec2/imds"
 	"github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
-	"github.com/aws/aws-sdk-go-v2/service/ecs"
-	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
 )
 
 type Provider struct{}
@@ -37,30 +37,103 @@ func (p *Provider) Help() string {
 
     provider:          "aws"
     region:            The AWS region. Default to region of instance.
+    regions:           Comma separated list of AWS regions to query concurrently instead of region.
+                       "all_enabled" adds all regions enabled for the account, which are looked up
+                       in region with 'ec2:DescribeRegions'.
+    partial_results:   "true" to return the addresses of the regions which succeeded if other
+                       regions of regions fail. Defaults to "false".
     tag_key:           The tag key to filter on
     tag_value:         The tag value to filter on
-    addr_type:         "private_v4", "public_v4" or "public_v6". Defaults to "private_v4".
+    tags:              Comma separated list of "key=value" tags or "key" tag keys the EC2
+                       instances must all have, e.g. tags="role=server,env=prod".
+    filter:            Semicolon separated list of EC2 DescribeInstances filters in the form
+                       "name=value[,value...]", e.g. filter="vpc-id=vpc-123;availability-zone=eu-west-1a,eu-west-1b".
+    instance_state:    Comma separated list of EC2 instance states or "all". Defaults to "running".
+    addr_type:         "private_v4", "public_v4", "private_v6", "public_v6", "private_dns",
+                       "public_dns" or "all_private_v4". Defaults to "private_v4". IPv6 addresses
+                       are taken from the primary network interface, unique local addresses
+                       (fc00::/7) are private and global unicast addresses public.
+                       "all_private_v4" returns every private IPv4 address of every network
+                       interface.
+    device_index:      The device index of the network interface of EC2 instances to take the
+                       addresses from. Defaults to the addresses of the instance.
     access_key_id:     The AWS access key to use
     secret_access_key: The AWS secret access key to use
-    service:           The AWS service to filter. "ec2" or "ecs". Defaults to "ec2".
+    session_token:     The AWS session token to use with the access key
+    profile:           The profile of the shared configuration and credentials files to use
+    role_arn:          The ARN of an IAM role to assume with the credentials
+    external_id:       The external ID to pass when assuming role_arn
+    role_session_name: The session name for the assumed role. Defaults to "go-discover".
+    web_identity_token_file: Path to a web identity (OIDC) token to assume role_arn with
+                       instead of the credentials, e.g. a Kubernetes service account token
+    sts_endpoint:      The endpoint URL of AWS STS to assume the role with
+    service:           The AWS service to filter. "ec2", "ecs", "asg", "cloudmap" or "elbv2". Defaults to "ec2".
+    asg_name:          Comma separated list of Auto Scaling group names for service "asg". Implies
+                       service "asg" if set. Defaults to all groups matching tag_key/tag_value and tags.
+    lifecycle_state:   Comma separated list of Auto Scaling lifecycle states of the instances for
+                       service "asg", or "all". States prefixed with "!" are excluded instead, e.g.
+                       "all,!Terminating:Wait". Defaults to "InService".
     ecs_cluster:       The AWS ECS Cluster Name or Full ARN to limit searching within. Default none, search all.
     ecs_family:        The AWS ECS Task Definition Family to limit searching within. Default none, search all.
+    ecs_service:       The AWS ECS Service Name to limit searching within. Default none, search all.
+    ecs_port:          The container port or the name of a port mapping to add to the ECS task addresses.
+                       In bridge and host network mode the host port bound to it is used.
+    ecs_container:     The name of the container of ecs_port. Defaults to any container of the task.
+    target_group_arn:  Comma separated list of ARNs of Elastic Load Balancing target groups for
+                       service "elbv2". Implies service "elbv2" if set.
+    target_group_name: Comma separated list of names of target groups for service "elbv2".
+                       Implies service "elbv2" if set.
+    target_port:       "true" to add the port of the targets to their addresses for service
+                       "elbv2". Defaults to "false".
+    cloudmap_namespace: The name of the AWS Cloud Map namespace for service "cloudmap"
+    cloudmap_service:  The name of the AWS Cloud Map service for service "cloudmap"
+    cloudmap_attributes: Comma separated list of "key=value" custom attributes the Cloud Map
+                       instances must all have.
+    cloudmap_health_status: The health status of the Cloud Map instances, "healthy", "unhealthy",
+                       "all" or "healthy_or_else_all". Defaults to "healthy".
     endpoint:          The endpoint URL of the AWS Service to use. If not set the AWS
                        client will set this value, which defaults to the public DNS name
                        for the service in the specified region.
 
-    For EC2 discovery the only required IAM permission is 'ec2:DescribeInstances'.
+    The credentials are taken from access_key_id and secret_access_key, the profile or the
+    default credential chain in that order. If role_arn is set, they are used to assume the
+    role with 'sts:AssumeRole' unless web_identity_token_file is set.
+
+    For EC2 discovery the tag_key/tag_value pair, tags and filter are combined, so instances
+    must match all of them. At least one of them is required. The only required IAM permission is 'ec2:DescribeInstances'.
     If the Consul agent is running on AWS instance it is recommended you use an IAM role,
     otherwise it is recommended you make a dedicated IAM user and access key used only
     for auto-joining.
 
-    For ECS discovery the following IAM permissions are required on the AWS ECS Task Role
-    associated with the Service performing discovery.
+    For Auto Scaling discovery tag_key/tag_value and tags select the Auto Scaling groups
+    while filter and instance_state apply to their instances. The addresses are looked up
+    like for EC2 discovery, so 'autoscaling:DescribeAutoScalingGroups' and
+    'ec2:DescribeInstances' are required.
+
+    For Elastic Load Balancing discovery the healthy targets of the target groups are
+    returned. Instance targets have the addresses of the instance like for EC2 discovery and
+    IP targets their IP address. 'elasticloadbalancing:DescribeTargetHealth',
+    'elasticloadbalancing:DescribeTargetGroups' for target_group_name and
+    'ec2:DescribeInstances' for instance targets are required.
+
+    For Cloud Map discovery the AWS_INSTANCE_IPV4 attribute of the instances is returned,
+    or AWS_INSTANCE_IPV6 for addr_type "private_v6" and "public_v6" if it has that scope, with
+    AWS_INSTANCE_PORT if it is set. The required IAM permission is
+    'servicediscovery:DiscoverInstances'.
+
+    For ECS discovery tasks in awsvpc network mode have the addresses of their network
+    interface and tasks in bridge and host network mode the addresses of their container
+    instance. Tasks must match the tag_key/tag_value pair, ecs_service and ecs_family, and at
+    least one of them or ecs_cluster is required. The following IAM permissions are required
+    on the AWS ECS Task Role associated with the Service performing discovery.
 		"ecs:ListClusters"
 		"ecs:ListServices"
 		"ecs:DescribeServices"
 		"ecs:ListTasks"
 		"ecs:DescribeTasks"
+    Tasks in bridge and host network mode also require "ecs:DescribeContainerInstances" and
+    "ec2:DescribeInstances", port mapping names "ecs:DescribeTaskDefinition" and addr_type
+    "public_v4" for tasks in awsvpc network mode "ec2:DescribeNetworkInterfaces".
 `
 }
 
@@ -77,24 +150,36 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	tagKey := args["tag_key"]
 	tagValue := args["tag_value"]
 	addrType := args["addr_type"]
-	accessKey := args["access_key_id"]
-	secretKey := args["secret_access_key"]
-	sessionToken := args["session_token"]
 	service := args["service"]
-	ecsCluster := args["ecs_cluster"]
-	ecsFamily := args["ecs_family"]
-	endpoint := args["endpoint"]
 
-	if service != "ec2" && service != "ecs" {
-		l.Printf("[INFO] discover-aws: Service type %s is not supported. Valid values are {ec2,ecs}. Falling back to 'ec2'", service)
+	if service == "" && args["asg_name"] != "" {
+		service = "asg"
+	}
+	if service == "" && (args["target_group_arn"] != "" || args["target_group_name"] != "") {
+		service = "elbv2"
+	}
+
+	if service != "ec2" && service != "ecs" && service != "asg" && service != "cloudmap" && service != "elbv2" {
+		l.Printf("[INFO] discover-aws: Service type %s is not supported. Valid values are {ec2,ecs,asg,cloudmap,elbv2}. Falling back to 'ec2'", service)
 		service = "ec2"
-	} else if service == "ecs" && addrType != "private_v4" {
-		l.Printf("[INFO] discover-aws: Address Type %s is not supported for ECS. Valid values are {private_v4}. Falling back to 'private_v4'", addrType)
-		addrType = "private_v4"
 	}
 
-	if addrType != "private_v4" && addrType != "public_v4" && addrType != "public_v6" {
-		l.Printf("[INFO] discover-aws: Address type %s is not supported. Valid values are {private_v4,public_v4,public_v6}. Falling back to 'private_v4'", addrType)
+	if service == "ec2" && tagKey == "" && args["tags"] == "" && args["filter"] == "" {
+		return nil, fmt.Errorf("discover-aws: tag_key, tags or filter is required for ec2")
+	}
+	if service == "ecs" && tagKey == "" && args["ecs_service"] == "" && args["ecs_family"] == "" && args["ecs_cluster"] == "" {
+		return nil, fmt.Errorf("discover-aws: tag_key, ecs_service, ecs_family or ecs_cluster is required for ecs")
+	}
+
+	switch addrType {
+	case "private_v4", "public_v4", "private_v6", "public_v6":
+	case "private_dns", "public_dns", "all_private_v4":
+		if service == "ecs" || service == "cloudmap" {
+			l.Printf("[INFO] discover-aws: Address type %s is not supported for %s. Valid values are {private_v4,public_v4,private_v6,public_v6}. Falling back to 'private_v4'", addrType, service)
+			addrType = "private_v4"
+		}
+	default:
+		l.Printf("[INFO] discover-aws: Address type %s is not supported. Valid values are {private_v4,public_v4,private_v6,public_v6,private_dns,public_dns,all_private_v4}. Falling back to 'private_v4'", addrType)
 		addrType = "private_v4"
 	}
 
@@ -104,226 +189,384 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	}
 
 	l.Printf("[DEBUG] discover-aws: Using region=%s tag_key=%s tag_value=%s addr_type=%s", region, tagKey, tagValue, addrType)
-	if accessKey == "" && secretKey == "" {
-		l.Printf("[DEBUG] discover-aws: No static credentials")
-		l.Printf("[DEBUG] discover-aws: Using environment variables, shared credentials or instance role")
-	} else {
-		l.Printf("[DEBUG] discover-aws: Static credentials provided")
+	if args["regions"] != "" {
+		return p.multiRegionAddrs(region, service, addrType, args, l)
 	}
 
 	if region == "" {
-		_, ecsEnabled := os.LookupEnv("ECS_CONTAINER_METADATA_URI_V4")
-		if ecsEnabled {
-			// Get ECS Task Region from metadata, so it works on Fargate and EC2-ECS
-			l.Printf("[INFO] discover-aws: Region not provided. Looking up region in ecs metadata...")
-			taskMetadata, err := getECSTaskMetadata()
-			if err != nil {
-				return nil, fmt.Errorf("discover-aws: Failed retrieving ECS Task Metadata: %s", err)
-			}
-
-			region, err = getEcsTaskRegion(taskMetadata)
-			if err != nil {
-				return nil, fmt.Errorf("discover-aws: Failed retrieving ECS Task Region: %s", err)
-			}
-		} else {
-			l.Printf("[INFO] discover-aws: Region not provided. Looking up region in ec2 metadata...")
-			ec2meta := imds.New(imds.Options{})
-			identity, err := ec2meta.GetInstanceIdentityDocument(context.TODO(), &imds.GetInstanceIdentityDocumentInput{})
-			if err != nil {
-				return nil, fmt.Errorf("discover-aws: GetInstanceIdentityDocument failed: %s", err)
-			}
-			region = identity.Region
+		var err error
+		if region, err = instanceRegion(l); err != nil {
+			return nil, err
 		}
 	}
 	l.Printf("[INFO] discover-aws: Region is %s", region)
 
+	return p.regionAddrs(region, service, addrType, args, l)
+}
+
+// regionAddrs discovers the addresses of service in region.
+func (p *Provider) regionAddrs(region, service, addrType string, args map[string]string, l *log.Logger) ([]string, error) {
+	endpoint := args["endpoint"]
+
 	l.Printf("[DEBUG] discover-aws: Creating session...")
-	var cfg aws.Config
-	var err error
-	_, found := aws.GetUseDualStackEndpoint()
-	if accessKey != "" && secretKey != "" {
-		l.Printf("[INFO] discover-aws: Using static credentials provider")
-		staticCreds := credentials.NewStaticCredentialsProvider(accessKey, secretKey, sessionToken)
-		switch {
-		case !found || addrType == "public_v4" || addrType == "private_v4":
-			cfg, err = config.LoadDefaultConfig(context.TODO(),
-				config.WithRegion(region),
-				config.WithCredentialsProvider(aws.NewCredentialsCache(staticCreds)),
-			)
-		case found:
-			cfg, err = config.LoadDefaultConfig(context.TODO(),
-				config.WithRegion(region),
-				config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled),
-				config.WithCredentialsProvider(aws.NewCredentialsCache(staticCreds)),
-			)
+	cfg, err := loadConfig(context.TODO(), region, addrType, args, l)
+	if err != nil {
+		return nil, err
+	}
+
+	switch service {
+	case "ecs":
+		return ecsAddrs(cfg, addrType, args, l)
+	case "cloudmap":
+		return cloudMapAddrs(context.TODO(), cfg, addrType, args, l)
+	}
+
+	device, err := deviceIndex(args)
+	if err != nil {
+		return nil, err
+	}
+	if service == "elbv2" {
+		return elbv2Addrs(cfg, addrType, device, args, l)
+	}
+
+	svc := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
 		}
+	})
+
+	var reservations []types.Reservation
+	if service == "asg" {
+		// The instances of the Auto Scaling groups are looked up by their
+		// ids, so the tags only select the groups.
+		ids, err := asgInstanceIDs(context.TODO(), cfg, endpoint, args, l)
 		if err != nil {
-			l.Printf("[INFO] discover-aws: unable to load SDK config with Static Provider, %v", err)
+			return nil, err
 		}
-	} else {
-		l.Printf("[INFO] discover-aws: Using default credential chain")
-		switch {
-		case found:
-			cfg, err = config.LoadDefaultConfig(context.TODO(),
-				config.WithRegion(region),
-				config.WithUseDualStackEndpoint(aws.DualStackEndpointStateEnabled),
-			)
-		case !found:
-			cfg, err = config.LoadDefaultConfig(context.TODO(),
-				config.WithRegion(region),
-			)
+		filters, err := instanceFilters(args)
+		if err != nil {
+			return nil, err
 		}
+
+		// Requests are limited to 200 values per filter.
+		const maxIDs = 200
+		for i := 0; i < len(ids); i += maxIDs {
+			idFilter := types.Filter{Name: aws.String("instance-id"), Values: ids[i:min(i+maxIDs, len(ids))]}
+			r, err := describeInstances(svc, append([]types.Filter{idFilter}, filters...), l)
+			if err != nil {
+				return nil, err
+			}
+			reservations = append(reservations, r...)
+		}
+	} else {
+		filters, err := ec2Filters(args)
 		if err != nil {
-			return nil, fmt.Errorf("discover-aws: unable to load SDK config with default credential chain, %s", err)
+			return nil, err
+		}
+		if reservations, err = describeInstances(svc, filters, l); err != nil {
+			return nil, err
 		}
 	}
 
-	// Split here for ec2 vs ecs decision tree
-	if service == "ecs" {
-		svc := ecs.NewFromConfig(cfg, func(o *ecs.Options) {
-			if endpoint != "" {
-				o.BaseEndpoint = aws.String(endpoint)
-				l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
-			}
-		})
+	l.Printf("[DEBUG] discover-aws: Found %d reservations", len(reservations))
+	var addrs []string
+	for _, r := range reservations {
+		l.Printf("[DEBUG] discover-aws: Reservation %s has %d instances", *r.ReservationId, len(r.Instances))
+		for _, inst := range r.Instances {
+			l.Printf("[DEBUG] discover-aws: Found instance %s", *inst.InstanceId)
+			addrs = append(addrs, InstanceAddrs(inst, addrType, device, l)...)
+		}
+	}
 
-		log.Printf("[INFO] discover-aws: Filter ECS tasks with %s=%s", tagKey, tagValue)
-		var clusterArns []string
+	l.Printf("[DEBUG] discover-aws: Found ip addresses: %v", addrs)
+	return addrs, nil
+}
 
-		// If an ECS Cluster Name (ARN) was specified, dont lookup all the cluster arns
-		if ecsCluster == "" {
-			arns, err := getEcsClusters(svc)
-			if err != nil {
-				return nil, fmt.Errorf("discover-aws: Failed to get ECS clusters: %s", err)
+// InstanceAddrs returns the addresses of an EC2 instance for addrType. If
+// deviceIndex is not negative only the network interface attached at
+// this device index is used, otherwise the addresses of the instance and
+// its primary network interface. "all_private_v4" returns the private
+// IPv4 addresses of all selected network interfaces.
+//
+// This is a separate method so that we can unit test this without having
+// to setup EC2 instances. It shouldn't generally be called externally.
+func InstanceAddrs(inst types.Instance, addrType string, deviceIndex int, l *log.Logger) []string {
+	id := aws.ToString(inst.InstanceId)
+	l.Printf("[DEBUG] discover-aws: Instance %s has %d network interfaces", id, len(inst.NetworkInterfaces))
+
+	// The network interfaces in the order of their device index.
+	nics := make([]types.InstanceNetworkInterface, 0, len(inst.NetworkInterfaces))
+	for _, ni := range inst.NetworkInterfaces {
+		if deviceIndex < 0 || nicDeviceIndex(ni) == deviceIndex {
+			nics = append(nics, ni)
+		}
+	}
+	sort.SliceStable(nics, func(i, j int) bool { return nicDeviceIndex(nics[i]) < nicDeviceIndex(nics[j]) })
+	if deviceIndex >= 0 && len(nics) == 0 {
+		l.Printf("[DEBUG] discover-aws: Instance %s has no network interface with device index %d", id, deviceIndex)
+		return nil
+	}
+
+	var addr *string
+	switch addrType {
+	case "all_private_v4":
+		var addrs []string
+		for _, ni := range nics {
+			for _, a := range ni.PrivateIpAddresses {
+				if a.PrivateIpAddress != nil {
+					l.Printf("[INFO] discover-aws: Instance %s has private ip %s on NetworkInterfaceId %s", id, *a.PrivateIpAddress, aws.ToString(ni.NetworkInterfaceId))
+					addrs = append(addrs, *a.PrivateIpAddress)
+				}
 			}
-			clusterArns = arns
-		} else {
-			clusterArns = []string{ecsCluster}
 		}
+		if len(addrs) == 0 && deviceIndex < 0 && inst.PrivateIpAddress != nil {
+			l.Printf("[INFO] discover-aws: Instance %s has private ip %s", id, *inst.PrivateIpAddress)
+			addrs = append(addrs, *inst.PrivateIpAddress)
+		}
+		return addrs
 
-		var taskIps []string
-		for _, clusterArn := range clusterArns {
-			taskArns, err := getEcsTasks(svc, &clusterArn, &ecsFamily)
-			if err != nil {
-				return nil, fmt.Errorf("discover-aws: Failed to get ECS Tasks: %s", err)
+	case "private_v6", "public_v6":
+		addr = instanceIPv6(inst, nics, deviceIndex, addrType == "private_v6")
+
+	case "public_v4":
+		addr = inst.PublicIpAddress
+		if deviceIndex >= 0 {
+			addr = nil
+			if nics[0].Association != nil {
+				addr = nics[0].Association.PublicIp
 			}
-			log.Printf("[DEBUG] discover-aws: Found %d ECS Tasks", len(taskArns))
-
-			// Once all the possibly paged task arns are collected, collect task descriptions with 100 task maximum
-			// ref: https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_DescribeTasks.html#ECS-DescribeTasks-request-tasks
-			pageLimit := 100
-			for i := 0; i < len(taskArns); i += pageLimit {
-				taskGroup := taskArns[i:min(i+pageLimit, len(taskArns))]
-				ecsTaskIps, err := getEcsTaskIps(svc, &clusterArn, taskGroup, &tagKey, &tagValue)
-				if err != nil {
-					return nil, fmt.Errorf("discover-aws: Failed to get ECS Task IPs: %s", err)
-				}
-				taskIps = append(taskIps, ecsTaskIps...)
-				log.Printf("[DEBUG] discover-aws: Found %d ECS IPs", len(ecsTaskIps))
+		}
+
+	case "private_dns":
+		addr = inst.PrivateDnsName
+		if deviceIndex >= 0 {
+			addr = nics[0].PrivateDnsName
+		}
+
+	case "public_dns":
+		addr = inst.PublicDnsName
+		if deviceIndex >= 0 {
+			addr = nil
+			if nics[0].Association != nil {
+				addr = nics[0].Association.PublicDnsName
 			}
 		}
-		log.Printf("[DEBUG] discover-aws: Discovered ECS Task IPs: %v", taskIps)
-		return taskIps, nil
+
+	default:
+		// EC2-Classic don't have the PrivateIpAddress field
+		addr = inst.PrivateIpAddress
+		if deviceIndex >= 0 {
+			addr = nics[0].PrivateIpAddress
+		}
 	}
 
-	// When not using ECS continue with the default EC2 search
+	if aws.ToString(addr) == "" {
+		l.Printf("[DEBUG] discover-aws: Instance %s has no %s address", id, addrType)
+		return nil
+	}
+	l.Printf("[INFO] discover-aws: Instance %s has %s address %s", id, addrType, *addr)
+	return []string{*addr}
+}
 
-	svc := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
-		if endpoint != "" {
-			o.BaseEndpoint = aws.String(endpoint)
-			l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
+// instanceIPv6 returns the private or public IPv6 address of the network
+// interface at deviceIndex or the primary network interface. The primary
+// IPv6 address is preferred. Unique local addresses are private and
+// global unicast addresses public.
+func instanceIPv6(inst types.Instance, nics []types.InstanceNetworkInterface, deviceIndex int, private bool) *string {
+	scoped := func(v *string) bool { return isIPv6Scope(aws.ToString(v), private) }
+
+	if deviceIndex < 0 {
+		if len(nics) == 0 || nicDeviceIndex(nics[0]) != 0 {
+			// Only the instance address is known.
+			if scoped(inst.Ipv6Address) {
+				return inst.Ipv6Address
+			}
+			return nil
 		}
-	})
+	}
 
-	l.Printf("[INFO] discover-aws: Filter instances with %s=%s", tagKey, tagValue)
-	resp, err := svc.DescribeInstances(context.TODO(), &ec2.DescribeInstancesInput{
-		Filters: []types.Filter{
-			{
-				Name:   aws.String("tag:" + tagKey),
-				Values: []string{tagValue},
-			},
-			{
-				Name:   aws.String("instance-state-name"),
-				Values: []string{"running"},
-			},
-		},
-	})
-	if err != nil {
-		return nil, fmt.Errorf("discover-aws: DescribeInstancesInput failed: %s", err)
+	var addr *string
+	for _, a := range nics[0].Ipv6Addresses {
+		if !scoped(a.Ipv6Address) {
+			continue
+		}
+		if aws.ToBool(a.IsPrimaryIpv6) {
+			return a.Ipv6Address
+		}
+		if addr == nil {
+			addr = a.Ipv6Address
+		}
 	}
+	return addr
+}
 
-	l.Printf("[DEBUG] discover-aws: Found %d reservations", len(resp.Reservations))
-	var addrs []string
-	for _, r := range resp.Reservations {
-		l.Printf("[DEBUG] discover-aws: Reservation %s has %d instances", *r.ReservationId, len(r.Instances))
-		for _, inst := range r.Instances {
-			id := *inst.InstanceId
-			l.Printf("[DEBUG] discover-aws: Found instance %s", id)
-
-			switch addrType {
-			case "public_v6":
-				l.Printf("[DEBUG] discover-aws: Instance %s has %d network interfaces", id, len(inst.NetworkInterfaces))
-
-				for _, networkinterface := range inst.NetworkInterfaces {
-					l.Printf("[DEBUG] discover-aws: Checking NetworInterfaceId %s on Instance %s", *networkinterface.NetworkInterfaceId, id)
-					// Check if instance got any ipv6
-					if networkinterface.Ipv6Addresses == nil {
-						l.Printf("[DEBUG] discover-aws: Instance %s has no IPv6 on NetworkInterfaceId %s", id, *networkinterface.NetworkInterfaceId)
-						continue
-					}
-					for _, ipv6address := range networkinterface.Ipv6Addresses {
-						l.Printf("[INFO] discover-aws: Instance %s has IPv6 %s on NetworkInterfaceId %s", id, *ipv6address.Ipv6Address, *networkinterface.NetworkInterfaceId)
-						addrs = append(addrs, *ipv6address.Ipv6Address)
-					}
-				}
+// isIPv6Scope reports whether v is a private or public IPv6 address.
+func isIPv6Scope(v string, private bool) bool {
+	ip, err := netip.ParseAddr(v)
+	if err != nil || !ip.Is6() || !ip.IsGlobalUnicast() {
+		return false
+	}
+	return ip.IsPrivate() == private
+}
 
-			case "public_v4":
-				if inst.PublicIpAddress == nil {
-					l.Printf("[DEBUG] discover-aws: Instance %s has no public IPv4", id)
-					continue
-				}
+// nicDeviceIndex returns the device index of the attachment of ni, or -1
+// if it isn't attached.
+func nicDeviceIndex(ni types.InstanceNetworkInterface) int {
+	if ni.Attachment == nil || ni.Attachment.DeviceIndex == nil {
+		return -1
+	}
+	return int(*ni.Attachment.DeviceIndex)
+}
 
-				l.Printf("[INFO] discover-aws: Instance %s has public ip %s", id, *inst.PublicIpAddress)
-				addrs = append(addrs, *inst.PublicIpAddress)
+// deviceIndex returns the device_index of args, or -1 if it isn't set.
+func deviceIndex(args map[string]string) (int, error) {
+	v := args["device_index"]
+	if v == "" {
+		return -1, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("discover-aws: invalid device_index %q", v)
+	}
+	return n, nil
+}
 
-			default:
-				// EC2-Classic don't have the PrivateIpAddress field
-				if inst.PrivateIpAddress == nil {
-					l.Printf("[DEBUG] discover-aws: Instance %s has no private ip", id)
-					continue
-				}
+// instanceRegion looks up the region of the ECS task or EC2 instance
+// discover is running on.
+func instanceRegion(l *log.Logger) (string, error) {
+	_, ecsEnabled := os.LookupEnv("ECS_CONTAINER_METADATA_URI_V4")
+	if ecsEnabled {
+		// Get ECS Task Region from metadata, so it works on Fargate and EC2-ECS
+		l.Printf("[INFO] discover-aws: Region not provided. Looking up region in ecs metadata...")
+		taskMetadata, err := getECSTaskMetadata()
+		if err != nil {
+			return "", fmt.Errorf("discover-aws: Failed retrieving ECS Task Metadata: %s", err)
+		}
 
-				l.Printf("[INFO] discover-aws: Instance %s has private ip %s", id, *inst.PrivateIpAddress)
-				addrs = append(addrs, *inst.PrivateIpAddress)
-			}
+		region, err := getEcsTaskRegion(taskMetadata)
+		if err != nil {
+			return "", fmt.Errorf("discover-aws: Failed retrieving ECS Task Region: %s", err)
 		}
+		return region, nil
 	}
 
-	l.Printf("[DEBUG] discover-aws: Found ip addresses: %v", addrs)
-	return addrs, nil
-}
-
-func min(a, b int) int {
-	if a <= b {
-		return a
+	l.Printf("[INFO] discover-aws: Region not provided. Looking up region in ec2 metadata...")
+	ec2meta := imds.New(imds.Options{})
+	identity, err := ec2meta.GetInstanceIdentityDocument(context.TODO(), &imds.GetInstanceIdentityDocumentInput{})
+	if err != nil {
+		return "", fmt.Errorf("discover-aws: GetInstanceIdentityDocument failed: %s", err)
 	}
-	return b
+	return identity.Region, nil
 }
 
-func getEcsClusters(svc *ecs.Client) ([]string, error) {
-	var clusterArns []string
-	paginator := ecs.NewListClustersPaginator(svc, &ecs.ListClustersInput{})
+// describeInstances returns the reservations of all instances matching
+// filters.
+func describeInstances(svc *ec2.Client, filters []types.Filter, l *log.Logger) ([]types.Reservation, error) {
+	for _, f := range filters {
+		l.Printf("[INFO] discover-aws: Filter instances with %s=%s", *f.Name, strings.Join(f.Values, ","))
+	}
 
+	var reservations []types.Reservation
+	paginator := ec2.NewDescribeInstancesPaginator(svc, &ec2.DescribeInstancesInput{Filters: filters})
 	for paginator.HasMorePages() {
 		page, err := paginator.NextPage(context.TODO())
 		if err != nil {
-			return nil, fmt.Errorf("ListClusters failed: %s", err)
+			return nil, fmt.Errorf("discover-aws: DescribeInstancesInput failed: %s", err)
+		}
+		reservations = append(reservations, page.Reservations...)
+	}
+	return reservations, nil
+}
+
+// ec2Filters returns the DescribeInstances filters for the tag_key and
+// tag_value pair, tags, filter and instance_state.
+func ec2Filters(args map[string]string) ([]types.Filter, error) {
+	filters, err := tagFilters(args)
+	if err != nil {
+		return nil, err
+	}
+	instFilters, err := instanceFilters(args)
+	if err != nil {
+		return nil, err
+	}
+	return append(filters, instFilters...), nil
+}
+
+// tagFilters returns the filters for the tag_key and tag_value pair and
+// tags. EC2 and Auto Scaling use the same names for tag filters.
+func tagFilters(args map[string]string) ([]types.Filter, error) {
+	var filters []types.Filter
+	if args["tag_key"] != "" {
+		filters = append(filters, types.Filter{
+			Name:   aws.String("tag:" + args["tag_key"]),
+			Values: []string{args["tag_value"]},
+		})
+	}
+
+	for _, tag := range strings.Split(args["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag == "" {
+			continue
+		}
+		k, v, ok := strings.Cut(tag, "=")
+		if k = strings.TrimSpace(k); k == "" {
+			return nil, fmt.Errorf("discover-aws: invalid tag %q", tag)
+		}
+		if !ok {
+			filters = append(filters, types.Filter{Name: aws.String("tag-key"), Values: []string{k}})
+			continue
 		}
-		clusterArns = append(clusterArns, page.ClusterArns...)
-		log.Printf("[DEBUG] discover-aws: Retrieved %d ClusterArns", len(clusterArns))
+		filters = append(filters, types.Filter{Name: aws.String("tag:" + k), Values: []string{strings.TrimSpace(v)}})
 	}
 
-	return clusterArns, nil
+	return filters, nil
+}
+
+// instanceFilters returns the DescribeInstances filters for filter and
+// instance_state.
+func instanceFilters(args map[string]string) ([]types.Filter, error) {
+	var filters []types.Filter
+	hasState := false
+	for _, expr := range strings.Split(args["filter"], ";") {
+		if expr = strings.TrimSpace(expr); expr == "" {
+			continue
+		}
+		name, values, ok := strings.Cut(expr, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" || values == "" {
+			return nil, fmt.Errorf("discover-aws: invalid filter %q, must be \"name=value[,value...]\"", expr)
+		}
+		f := types.Filter{Name: aws.String(name)}
+		for _, v := range strings.Split(values, ",") {
+			f.Values = append(f.Values, strings.TrimSpace(v))
+		}
+		filters = append(filters, f)
+		hasState = hasState || name == "instance-state-name"
+	}
+
+	states := args["instance_state"]
+	switch {
+	case hasState && states != "":
+		return nil, fmt.Errorf("discover-aws: instance_state can't be combined with an instance-state-name filter")
+	case hasState, states == "all":
+	case states == "":
+		filters = append(filters, types.Filter{Name: aws.String("instance-state-name"), Values: []string{"running"}})
+	default:
+		f := types.Filter{Name: aws.String("instance-state-name")}
+		for _, s := range strings.Split(states, ",") {
+			f.Values = append(f.Values, strings.TrimSpace(s))
+		}
+		filters = append(filters, f)
+	}
+
+	return filters, nil
+}
+
+func min(a, b int) int {
+	if a <= b {
+		return a
+	}
+	return b
 }
 
 func getECSTaskMetadata() (ECSTaskMeta, error) {
@@ -357,91 +600,3 @@ func getEcsTaskRegion(e ECSTaskMeta) (string, error) {
 	}
 	return a.Region, nil
 }
-
-func getEcsTasks(svc *ecs.Client, clusterArn *string, family *string) ([]string, error) {
-	var taskArns []string
-	lti := ecs.ListTasksInput{
-		Cluster:       clusterArn,
-		DesiredStatus: ecstypes.DesiredStatusRunning,
-	}
-	if *family != "" {
-		lti.Family = family
-	}
-
-	paginator := ecs.NewListTasksPaginator(svc, &lti)
-
-	pageNum := 0
-	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(context.TODO())
-		if err != nil {
-			return nil, fmt.Errorf("ListTasks failed: %w", err)
-		}
-		pageNum++
-		taskArns = append(taskArns, page.TaskArns...)
-		log.Printf("[DEBUG] discover-aws: Retrieved %d TaskArns from page %d", len(taskArns), pageNum)
-	}
-
-	return taskArns, nil
-}
-
-func getEcsTaskIps(svc *ecs.Client, clusterArn *string, taskArns []string, tagKey *string, tagValue *string) ([]string, error) {
-	// Describe all the tasks listed for this cluster
-	taskDescriptions, err := svc.DescribeTasks(context.TODO(), &ecs.DescribeTasksInput{
-		Cluster: clusterArn,
-		Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
-		Tasks:   taskArns,
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("DescribeTasks failed: %s", err)
-	}
-
-	taskRequestFailures := taskDescriptions.Failures
-	tasks := taskDescriptions.Tasks
-	log.Printf("[INFO] discover-aws: Retrieved %d Task Descriptions and %d Failures", len(tasks), len(taskRequestFailures))
-
-	// Filter tasks by Tag and Connectivity Status
-	var ipList []string
-	for _, taskDescription := range tasks {
-
-		for _, tag := range taskDescription.Tags {
-			if *tag.Key == *tagKey && *tag.Value == *tagValue {
-				log.Printf("[DEBUG] discover-aws: Tag Match: %s : %s, desiredStatus: %s", *tag.Key, *tag.Value, *taskDescription.DesiredStatus)
-
-				if *taskDescription.DesiredStatus == "RUNNING" {
-					log.Printf("[INFO] discover-aws: Found Running Instance: %s", *taskDescription.TaskArn)
-					ip := getIpFromTaskDescription(&taskDescription)
-
-					if ip != nil {
-						log.Printf("[DEBUG] discover-aws: Found Private IP: %s", *ip)
-						ipList = append(ipList, *ip)
-					}
-
-				}
-
-			}
-
-		}
-	}
-
-	log.Printf("[INFO] discover-aws: Retrieved %d IPs from %d Tasks", len(ipList), len(taskArns))
-	return ipList, nil
-}
-
-func getIpFromTaskDescription(taskDesc *ecstypes.Task) *string {
-	log.Printf("[DEBUG] discover-aws: Searching %d attachments for IPs", len(taskDesc.Attachments))
-	for _, attachment := range taskDesc.Attachments {
-
-		log.Printf("[DEBUG] discover-aws: Searching %d attachment details for IPs", len(attachment.Details))
-		for _, detail := range attachment.Details {
-
-			if *detail.Name == "privateIPv4Address" {
-				log.Printf("[DEBUG] discover-aws: Parsing Private IPv4: %s", *detail.Value)
-				return detail.Value
-			}
-
-		}
-
-	}
-	return nil
-}
@@ -4,11 +4,22 @@
 package aws_test
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 
+	awssdk "github.com/aws/aws-sdk-go-v2/aws"
+	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/aws"
 )
 
@@ -284,3 +295,494 @@ func containsAny(str string, substrings []string) bool {
 	}
 	return false
 }
+
+// testInstance is an EC2 instance served by the fake EC2 API.
+type testInstance struct {
+	Region    string // all regions if empty
+	ID        string
+	State     string
+	PrivateIP string
+	PublicIP  string
+	VPC       string
+	Tags      map[string]string
+}
+
+var testInstances = []testInstance{
+	{ID: "i-1", State: "running", PrivateIP: "10.0.0.1", PublicIP: "198.51.100.1", VPC: "vpc-1", Tags: map[string]string{"consul": "server", "env": "prod"}},
+	{ID: "i-2", State: "running", PrivateIP: "10.0.0.2", PublicIP: "198.51.100.2", VPC: "vpc-1", Tags: map[string]string{"consul": "server", "env": "dev"}},
+	{ID: "i-3", State: "running", PrivateIP: "10.0.1.3", VPC: "vpc-2", Tags: map[string]string{"consul": "server", "env": "prod"}},
+	{ID: "i-4", State: "stopped", PrivateIP: "10.0.0.4", VPC: "vpc-1", Tags: map[string]string{"consul": "server", "env": "prod"}},
+	{ID: "i-5", State: "running", PrivateIP: "10.0.0.5", VPC: "vpc-1", Tags: map[string]string{"consul": "client"}},
+}
+
+// ec2Filter is a filter of a DescribeInstances request.
+type ec2Filter struct {
+	Name   string
+	Values []string
+}
+
+// match reports whether inst matches f for the filters the tests use.
+func (f ec2Filter) match(inst testInstance) bool {
+	var got string
+	var ok bool
+	switch {
+	case strings.HasPrefix(f.Name, "tag:"):
+		got, ok = inst.Tags[strings.TrimPrefix(f.Name, "tag:")]
+	case f.Name == "tag-key":
+		for _, v := range f.Values {
+			if _, ok := inst.Tags[v]; ok {
+				return true
+			}
+		}
+		return false
+	case f.Name == "instance-state-name":
+		got, ok = inst.State, true
+	case f.Name == "vpc-id":
+		got, ok = inst.VPC, true
+	case f.Name == "instance-id":
+		got, ok = inst.ID, true
+	}
+	if !ok {
+		return false
+	}
+	for _, v := range f.Values {
+		if v == got {
+			return true
+		}
+	}
+	return false
+}
+
+// parseEC2Filters parses the Filter.N.Name and Filter.N.Value.M
+// parameters of an EC2 Query API request.
+func parseEC2Filters(form url.Values) []ec2Filter {
+	var filters []ec2Filter
+	for n := 1; ; n++ {
+		name := form.Get(fmt.Sprintf("Filter.%d.Name", n))
+		if name == "" {
+			return filters
+		}
+		f := ec2Filter{Name: name}
+		for m := 1; ; m++ {
+			v, ok := form[fmt.Sprintf("Filter.%d.Value.%d", n, m)]
+			if !ok {
+				break
+			}
+			f.Values = append(f.Values, v[0])
+		}
+		filters = append(filters, f)
+	}
+}
+
+// fakeAWS is a stand-in for the AWS Query APIs. It serves EC2
+// DescribeInstances for Instances and Auto Scaling
+// DescribeAutoScalingGroups for Groups with one item per page, and the
+// STS AssumeRole and AssumeRoleWithWebIdentity actions. The ECS JSON API
+// is served for Tasks, the Cloud Map DiscoverInstances action for
+// CloudMap and Elastic Load Balancing for TargetGroups. The received EC2
+// filters of the last request and the parameters and Authorization
+// header of the last request of each action are recorded.
+type fakeAWS struct {
+	Instances    []testInstance
+	Groups       []testGroup
+	Regions      []string        // enabled regions
+	FailRegions  map[string]bool // regions DescribeInstances fails in
+	Tasks        []testTask
+	PublicIPs    map[string]string // public IPs of network interfaces
+	CloudMap     []testCloudMapInstance
+	TargetGroups []testTargetGroup
+
+	mu      sync.Mutex
+	filters []ec2Filter
+	pages   int
+	auth    map[string]string
+	forms   map[string]url.Values
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// The JSON APIs have the action in the target header.
+	if target := r.Header.Get("X-Amz-Target"); target != "" {
+		api, action, _ := strings.Cut(target, ".")
+		f.record(action, r)
+		if api == "Route53AutoNaming_v20170314" {
+			f.cloudMap(w, r, action)
+		} else {
+			f.ecs(w, r, target)
+		}
+		return
+	}
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	action := r.Form.Get("Action")
+	f.record(action, r)
+
+	switch action {
+	case "DescribeInstances":
+		f.describeInstances(w, r.Form, signingRegion(r.Header.Get("Authorization")))
+	case "DescribeRegions":
+		f.describeRegions(w)
+	case "DescribeNetworkInterfaces":
+		f.describeNetworkInterfaces(w, r.Form)
+	case "DescribeAutoScalingGroups":
+		f.describeAutoScalingGroups(w, r.Form)
+	case "DescribeTargetGroups":
+		f.describeTargetGroups(w, r.Form)
+	case "DescribeTargetHealth":
+		f.describeTargetHealth(w, r.Form)
+	case "AssumeRole", "AssumeRoleWithWebIdentity":
+		f.assumeRole(w, action)
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, "<Response><Errors><Error><Code>InvalidAction</Code><Message>%s</Message></Error></Errors></Response>", action)
+	}
+}
+
+// record records the Authorization header and parameters of a request
+// for action.
+func (f *fakeAWS) record(action string, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.auth == nil {
+		f.auth = map[string]string{}
+		f.forms = map[string]url.Values{}
+	}
+	f.auth[action] = r.Header.Get("Authorization")
+	f.forms[action] = r.Form
+}
+
+// signingRegion returns the region of the credential scope of a
+// Signature Version 4 Authorization header.
+func signingRegion(auth string) string {
+	_, scope, _ := strings.Cut(auth, "Credential=")
+	parts := strings.Split(scope, "/")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+func (f *fakeAWS) describeInstances(w http.ResponseWriter, form url.Values, region string) {
+	if f.FailRegions[region] {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<Response><Errors><Error><Code>UnauthorizedOperation</Code><Message>not authorized in region</Message></Error></Errors><RequestID>req</RequestID></Response>`)
+		return
+	}
+	filters := parseEC2Filters(form)
+
+	f.mu.Lock()
+	f.filters = filters
+	f.pages++
+	f.mu.Unlock()
+
+	var matched []testInstance
+Instances:
+	for _, inst := range f.Instances {
+		if inst.Region != "" && inst.Region != region {
+			continue
+		}
+		for _, filter := range filters {
+			if !filter.match(inst) {
+				continue Instances
+			}
+		}
+		matched = append(matched, inst)
+	}
+
+	// Serve a single instance per page to exercise the pagination.
+	start := 0
+	if tok := form.Get("NextToken"); tok != "" {
+		start, _ = strconv.Atoi(tok)
+	}
+	var page []testInstance
+	nextToken := ""
+	if start < len(matched) {
+		page = matched[start : start+1]
+		if start+1 < len(matched) {
+			nextToken = strconv.Itoa(start + 1)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, `<DescribeInstancesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req</requestId><reservationSet>`)
+	for _, inst := range page {
+		fmt.Fprintf(w, `<item><reservationId>r-%s</reservationId><instancesSet><item>`, inst.ID)
+		fmt.Fprintf(w, `<instanceId>%s</instanceId><instanceState><name>%s</name></instanceState><vpcId>%s</vpcId>`, inst.ID, inst.State, inst.VPC)
+		if inst.PrivateIP != "" {
+			fmt.Fprintf(w, `<privateIpAddress>%s</privateIpAddress>`, inst.PrivateIP)
+		}
+		if inst.PublicIP != "" {
+			fmt.Fprintf(w, `<ipAddress>%s</ipAddress>`, inst.PublicIP)
+		}
+		fmt.Fprint(w, `<tagSet>`)
+		for k, v := range inst.Tags {
+			fmt.Fprintf(w, `<item><key>%s</key><value>%s</value></item>`, k, v)
+		}
+		fmt.Fprint(w, `</tagSet></item></instancesSet></item>`)
+	}
+	fmt.Fprint(w, `</reservationSet>`)
+	if nextToken != "" {
+		fmt.Fprintf(w, `<nextToken>%s</nextToken>`, nextToken)
+	}
+	fmt.Fprint(w, `</DescribeInstancesResponse>`)
+}
+
+// localArgs returns the arguments to query a local stand-in for the AWS
+// APIs at endpoint with static credentials.
+func localArgs(endpoint string) discover.Config {
+	return discover.Config{
+		"provider":          "aws",
+		"region":            "us-east-1",
+		"access_key_id":     "AKIDTEST",
+		"secret_access_key": "secret",
+		"endpoint":          endpoint,
+	}
+}
+
+func TestAddrsLocalEC2(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	ec2 := &fakeAWS{Instances: testInstances}
+	srv := httptest.NewServer(ec2)
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"tag key and value",
+			map[string]string{"tag_key": "consul", "tag_value": "server"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.1.3"},
+			"",
+		},
+		{
+			"tags",
+			map[string]string{"tags": "consul=server, env=prod"},
+			[]string{"10.0.0.1", "10.0.1.3"},
+			"",
+		},
+		{
+			"tag keys",
+			map[string]string{"tags": "env"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.1.3"},
+			"",
+		},
+		{
+			"tag key and tags",
+			map[string]string{"tag_key": "consul", "tag_value": "server", "tags": "env=dev"},
+			[]string{"10.0.0.2"},
+			"",
+		},
+		{
+			"filter",
+			map[string]string{"tags": "consul=server", "filter": "vpc-id=vpc-1; instance-id=i-1,i-2,i-4"},
+			[]string{"10.0.0.1", "10.0.0.2"},
+			"",
+		},
+		{
+			"instance state",
+			map[string]string{"tags": "env=prod", "instance_state": "running,stopped"},
+			[]string{"10.0.0.1", "10.0.1.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"all instance states",
+			map[string]string{"tags": "consul=server", "instance_state": "all"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.1.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"instance state filter",
+			map[string]string{"filter": "instance-state-name=stopped"},
+			[]string{"10.0.0.4"},
+			"",
+		},
+		{
+			"public addresses",
+			map[string]string{"tags": "consul=server", "addr_type": "public_v4"},
+			[]string{"198.51.100.1", "198.51.100.2"},
+			"",
+		},
+		{
+			"invalid filter",
+			map[string]string{"filter": "vpc-id"},
+			nil,
+			"invalid filter",
+		},
+		{
+			"invalid tag",
+			map[string]string{"tags": "=server"},
+			nil,
+			"invalid tag",
+		},
+		{
+			"invalid device index",
+			map[string]string{"tags": "consul=server", "device_index": "eth1"},
+			nil,
+			"invalid device_index",
+		},
+		{
+			"no tags or filter",
+			map[string]string{"instance_state": "all"},
+			nil,
+			"tag_key, tags or filter is required",
+		},
+		{
+			"instance state and filter",
+			map[string]string{"filter": "instance-state-name=stopped", "instance_state": "running"},
+			nil,
+			"can't be combined",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+
+	// The default filter only selects running instances and every page
+	// is requested.
+	ec2.pages = 0
+	args := localArgs(srv.URL)
+	args["tags"] = "consul=server"
+	if _, err := p.Addrs(args, l); err != nil {
+		t.Fatal(err)
+	}
+	want := []ec2Filter{
+		{Name: "tag:consul", Values: []string{"server"}},
+		{Name: "instance-state-name", Values: []string{"running"}},
+	}
+	if !reflect.DeepEqual(ec2.filters, want) {
+		t.Fatalf("got filters %v want %v", ec2.filters, want)
+	}
+	if ec2.pages != 3 {
+		t.Fatalf("got %d pages want 3", ec2.pages)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	srv := httptest.NewServer(&fakeAWS{Instances: testInstances})
+	defer srv.Close()
+
+	args := localArgs(srv.URL)
+	args["tags"] = "consul=server"
+	invalid := localArgs(srv.URL)
+	invalid["filter"] = "vpc-id"
+
+	discovertest.RunConformance(t, &aws.Provider{}, discovertest.Fixtures{
+		Name:    "aws",
+		Args:    args,
+		Want:    []string{"10.0.0.1", "10.0.0.2", "10.0.1.3"},
+		Invalid: invalid,
+	})
+}
+
+func TestInstanceAddrs(t *testing.T) {
+	nic := func(id string, device int32, private []string, ipv6 []string, primaryIPv6 string, public string) ec2types.InstanceNetworkInterface {
+		ni := ec2types.InstanceNetworkInterface{
+			NetworkInterfaceId: awssdk.String(id),
+			Attachment:         &ec2types.InstanceNetworkInterfaceAttachment{DeviceIndex: awssdk.Int32(device)},
+			PrivateIpAddress:   awssdk.String(private[0]),
+			PrivateDnsName:     awssdk.String("ip-" + strings.ReplaceAll(private[0], ".", "-") + ".ec2.internal"),
+		}
+		for i, ip := range private {
+			ni.PrivateIpAddresses = append(ni.PrivateIpAddresses, ec2types.InstancePrivateIpAddress{
+				PrivateIpAddress: awssdk.String(ip),
+				Primary:          awssdk.Bool(i == 0),
+			})
+		}
+		for _, ip := range ipv6 {
+			ni.Ipv6Addresses = append(ni.Ipv6Addresses, ec2types.InstanceIpv6Address{
+				Ipv6Address:   awssdk.String(ip),
+				IsPrimaryIpv6: awssdk.Bool(ip == primaryIPv6),
+			})
+		}
+		if public != "" {
+			ni.Association = &ec2types.InstanceNetworkInterfaceAssociation{
+				PublicIp:      awssdk.String(public),
+				PublicDnsName: awssdk.String("ec2-" + strings.ReplaceAll(public, ".", "-") + ".compute-1.amazonaws.com"),
+			}
+		}
+		return ni
+	}
+
+	inst := ec2types.Instance{
+		InstanceId:       awssdk.String("i-1"),
+		PrivateIpAddress: awssdk.String("10.0.0.1"),
+		PrivateDnsName:   awssdk.String("ip-10-0-0-1.ec2.internal"),
+		PublicIpAddress:  awssdk.String("198.51.100.1"),
+		PublicDnsName:    awssdk.String("ec2-198-51-100-1.compute-1.amazonaws.com"),
+		Ipv6Address:      awssdk.String("2600:1f18::1"),
+		// The network interfaces are not ordered by their device index.
+		NetworkInterfaces: []ec2types.InstanceNetworkInterface{
+			nic("eni-2", 1, []string{"10.0.1.1", "10.0.1.2"}, []string{"fd00:1::1", "2600:1f18:1::1"}, "", "203.0.113.2"),
+			nic("eni-1", 0, []string{"10.0.0.1", "10.0.0.2"}, []string{"2600:1f18::2", "2600:1f18::1", "fd00::1"}, "2600:1f18::1", "198.51.100.1"),
+		},
+	}
+	bare := ec2types.Instance{
+		InstanceId:       awssdk.String("i-2"),
+		PrivateIpAddress: awssdk.String("10.0.0.5"),
+		Ipv6Address:      awssdk.String("fd00::5"),
+	}
+
+	cases := []struct {
+		Name        string
+		Instance    ec2types.Instance
+		AddrType    string
+		DeviceIndex int
+		Expected    []string
+	}{
+		{"private_v4", inst, "private_v4", -1, []string{"10.0.0.1"}},
+		{"public_v4", inst, "public_v4", -1, []string{"198.51.100.1"}},
+		{"private_dns", inst, "private_dns", -1, []string{"ip-10-0-0-1.ec2.internal"}},
+		{"public_dns", inst, "public_dns", -1, []string{"ec2-198-51-100-1.compute-1.amazonaws.com"}},
+		{"primary public_v6", inst, "public_v6", -1, []string{"2600:1f18::1"}},
+		{"private_v6", inst, "private_v6", -1, []string{"fd00::1"}},
+		{"all_private_v4", inst, "all_private_v4", -1, []string{"10.0.0.1", "10.0.0.2", "10.0.1.1", "10.0.1.2"}},
+		{"device private_v4", inst, "private_v4", 1, []string{"10.0.1.1"}},
+		{"device public_v4", inst, "public_v4", 1, []string{"203.0.113.2"}},
+		{"device private_dns", inst, "private_dns", 1, []string{"ip-10-0-1-1.ec2.internal"}},
+		{"device public_dns", inst, "public_dns", 1, []string{"ec2-203-0-113-2.compute-1.amazonaws.com"}},
+		{"device public_v6", inst, "public_v6", 1, []string{"2600:1f18:1::1"}},
+		{"device private_v6", inst, "private_v6", 1, []string{"fd00:1::1"}},
+		{"device all_private_v4", inst, "all_private_v4", 1, []string{"10.0.1.1", "10.0.1.2"}},
+		{"missing device", inst, "private_v4", 2, nil},
+		{"no network interfaces private_v6", bare, "private_v6", -1, []string{"fd00::5"}},
+		{"no network interfaces public_v6", bare, "public_v6", -1, nil},
+		{"no network interfaces all_private_v4", bare, "all_private_v4", -1, []string{"10.0.0.5"}},
+		{"no public_v4", bare, "public_v4", -1, nil},
+		{"no public_dns", bare, "public_dns", -1, nil},
+		{"no device", bare, "private_v4", 0, nil},
+	}
+
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			addrs := aws.InstanceAddrs(tt.Instance, tt.AddrType, tt.DeviceIndex, l)
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,444 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/ecs"
+	ecstypes "github.com/aws/aws-sdk-go-v2/service/ecs/types"
+)
+
+// ecsResolver looks up the addresses and ports of ECS tasks.
+type ecsResolver struct {
+	ecs       *ecs.Client
+	ec2       *ec2.Client
+	addrType  string
+	container string // ecs_container
+	port      string // ecs_port, a container port or port mapping name
+	taskDefs  map[string]*ecstypes.TaskDefinition
+	l         *log.Logger
+}
+
+// ecsAddrs discovers the addresses of the running ECS tasks selected by
+// ecs_cluster, ecs_family, ecs_service and tag_key/tag_value.
+func ecsAddrs(cfg aws.Config, addrType string, args map[string]string, l *log.Logger) ([]string, error) {
+	tagKey := args["tag_key"]
+	tagValue := args["tag_value"]
+	ecsCluster := args["ecs_cluster"]
+	ecsFamily := args["ecs_family"]
+	ecsService := args["ecs_service"]
+	endpoint := args["endpoint"]
+
+	r := &ecsResolver{
+		addrType:  addrType,
+		container: args["ecs_container"],
+		port:      args["ecs_port"],
+		taskDefs:  map[string]*ecstypes.TaskDefinition{},
+		l:         l,
+	}
+	if r.container != "" && r.port == "" {
+		return nil, fmt.Errorf("discover-aws: ecs_container requires ecs_port")
+	}
+	if n, err := strconv.Atoi(r.port); err == nil && (n < 1 || n > 65535) {
+		return nil, fmt.Errorf("discover-aws: invalid ecs_port %q", r.port)
+	}
+
+	r.ecs = ecs.NewFromConfig(cfg, func(o *ecs.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
+		}
+	})
+	r.ec2 = ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	l.Printf("[INFO] discover-aws: Filter ECS tasks with %s=%s service=%s", tagKey, tagValue, ecsService)
+	var clusterArns []string
+
+	// If an ECS Cluster Name (ARN) was specified, dont lookup all the cluster arns
+	if ecsCluster == "" {
+		arns, err := getEcsClusters(r.ecs, l)
+		if err != nil {
+			return nil, fmt.Errorf("discover-aws: Failed to get ECS clusters: %s", err)
+		}
+		clusterArns = arns
+	} else {
+		clusterArns = []string{ecsCluster}
+	}
+
+	var addrs []string
+	for _, clusterArn := range clusterArns {
+		taskArns, err := getEcsTasks(r.ecs, clusterArn, ecsFamily, ecsService, l)
+		if err != nil {
+			// The service only exists in some of the clusters.
+			var notFound *ecstypes.ServiceNotFoundException
+			if ecsCluster == "" && errors.As(err, &notFound) {
+				l.Printf("[DEBUG] discover-aws: ECS cluster %s has no service %s", clusterArn, ecsService)
+				continue
+			}
+			return nil, fmt.Errorf("discover-aws: Failed to get ECS Tasks: %s", err)
+		}
+		l.Printf("[DEBUG] discover-aws: Found %d ECS Tasks", len(taskArns))
+
+		// Once all the possibly paged task arns are collected, collect task descriptions with 100 task maximum
+		// ref: https://docs.aws.amazon.com/AmazonECS/latest/APIReference/API_DescribeTasks.html#ECS-DescribeTasks-request-tasks
+		pageLimit := 100
+		for i := 0; i < len(taskArns); i += pageLimit {
+			taskGroup := taskArns[i:min(i+pageLimit, len(taskArns))]
+			tasks, err := getEcsTaskDescriptions(r.ecs, clusterArn, taskGroup, tagKey, tagValue, l)
+			if err != nil {
+				return nil, fmt.Errorf("discover-aws: Failed to get ECS Task IPs: %s", err)
+			}
+			taskAddrs, err := r.taskAddrs(clusterArn, tasks)
+			if err != nil {
+				return nil, fmt.Errorf("discover-aws: Failed to get ECS Task IPs: %s", err)
+			}
+			addrs = append(addrs, taskAddrs...)
+			l.Printf("[DEBUG] discover-aws: Found %d ECS IPs", len(taskAddrs))
+		}
+	}
+	l.Printf("[DEBUG] discover-aws: Discovered ECS Task IPs: %v", addrs)
+	return addrs, nil
+}
+
+func getEcsClusters(svc *ecs.Client, l *log.Logger) ([]string, error) {
+	var clusterArns []string
+	paginator := ecs.NewListClustersPaginator(svc, &ecs.ListClustersInput{})
+
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("ListClusters failed: %s", err)
+		}
+		clusterArns = append(clusterArns, page.ClusterArns...)
+		l.Printf("[DEBUG] discover-aws: Retrieved %d ClusterArns", len(clusterArns))
+	}
+
+	return clusterArns, nil
+}
+
+func getEcsTasks(svc *ecs.Client, clusterArn, family, service string, l *log.Logger) ([]string, error) {
+	var taskArns []string
+	lti := ecs.ListTasksInput{
+		Cluster:       aws.String(clusterArn),
+		DesiredStatus: ecstypes.DesiredStatusRunning,
+	}
+	if family != "" {
+		lti.Family = aws.String(family)
+	}
+	if service != "" {
+		lti.ServiceName = aws.String(service)
+	}
+
+	paginator := ecs.NewListTasksPaginator(svc, &lti)
+
+	pageNum := 0
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("ListTasks failed: %w", err)
+		}
+		pageNum++
+		taskArns = append(taskArns, page.TaskArns...)
+		l.Printf("[DEBUG] discover-aws: Retrieved %d TaskArns from page %d", len(taskArns), pageNum)
+	}
+
+	return taskArns, nil
+}
+
+// getEcsTaskDescriptions describes the running tasks of taskArns which
+// have the tag tagKey=tagValue, or all of them if tagKey is empty.
+func getEcsTaskDescriptions(svc *ecs.Client, clusterArn string, taskArns []string, tagKey, tagValue string, l *log.Logger) ([]ecstypes.Task, error) {
+	// Describe all the tasks listed for this cluster
+	taskDescriptions, err := svc.DescribeTasks(context.TODO(), &ecs.DescribeTasksInput{
+		Cluster: aws.String(clusterArn),
+		Include: []ecstypes.TaskField{ecstypes.TaskFieldTags},
+		Tasks:   taskArns,
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("DescribeTasks failed: %s", err)
+	}
+
+	taskRequestFailures := taskDescriptions.Failures
+	l.Printf("[INFO] discover-aws: Retrieved %d Task Descriptions and %d Failures", len(taskDescriptions.Tasks), len(taskRequestFailures))
+
+	// Filter tasks by Tag and Connectivity Status
+	var tasks []ecstypes.Task
+	for _, taskDescription := range taskDescriptions.Tasks {
+		if tagKey != "" && !hasEcsTag(taskDescription.Tags, tagKey, tagValue) {
+			continue
+		}
+		if aws.ToString(taskDescription.DesiredStatus) != "RUNNING" {
+			continue
+		}
+		l.Printf("[INFO] discover-aws: Found Running Task: %s", aws.ToString(taskDescription.TaskArn))
+		tasks = append(tasks, taskDescription)
+	}
+	return tasks, nil
+}
+
+func hasEcsTag(tags []ecstypes.Tag, key, value string) bool {
+	for _, tag := range tags {
+		if aws.ToString(tag.Key) == key && aws.ToString(tag.Value) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// ecsTarget is the address of a task before the addresses which need
+// another request are resolved.
+type ecsTarget struct {
+	task              string
+	ip                string
+	eni               string // network interface to look up the public IP of
+	containerInstance string // container instance to look up the address of
+	port              string
+}
+
+// taskAddrs returns the addresses of tasks in clusterArn. Tasks in awsvpc
+// network mode have the addresses of their network interface, tasks in
+// bridge and host network mode the addresses of their container instance.
+func (r *ecsResolver) taskAddrs(clusterArn string, tasks []ecstypes.Task) ([]string, error) {
+	var targets []ecsTarget
+	var enis, containerInstances []string
+	seen := map[string]bool{}
+	for i := range tasks {
+		task := &tasks[i]
+		t := ecsTarget{task: aws.ToString(task.TaskArn)}
+
+		details := getEniFromTaskDescription(task, r.l)
+		switch {
+		case details != nil && r.addrType == "public_v4":
+			t.eni = details["networkInterfaceId"]
+			enis = append(enis, t.eni)
+		case details != nil && (r.addrType == "private_v6" || r.addrType == "public_v6"):
+			if ip := details["ipv6Address"]; isIPv6Scope(ip, r.addrType == "private_v6") {
+				t.ip = ip
+			}
+		case details != nil:
+			t.ip = details["privateIPv4Address"]
+		case task.ContainerInstanceArn != nil:
+			t.containerInstance = *task.ContainerInstanceArn
+			if !seen[t.containerInstance] {
+				seen[t.containerInstance] = true
+				containerInstances = append(containerInstances, t.containerInstance)
+			}
+		default:
+			r.l.Printf("[DEBUG] discover-aws: Task %s has no network interface or container instance", t.task)
+			continue
+		}
+
+		if r.port != "" {
+			port, err := r.taskPort(task, details != nil)
+			if err != nil {
+				return nil, err
+			}
+			if port == 0 {
+				r.l.Printf("[DEBUG] discover-aws: Task %s exposes no port %s", t.task, r.port)
+				continue
+			}
+			t.port = strconv.Itoa(int(port))
+		}
+		targets = append(targets, t)
+	}
+
+	publicIPs, err := r.eniPublicIPs(enis)
+	if err != nil {
+		return nil, err
+	}
+	instanceIPs, err := r.containerInstanceIPs(clusterArn, containerInstances)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+	for _, t := range targets {
+		switch {
+		case t.eni != "":
+			t.ip = publicIPs[t.eni]
+		case t.containerInstance != "":
+			t.ip = instanceIPs[t.containerInstance]
+		}
+		if t.ip == "" {
+			r.l.Printf("[DEBUG] discover-aws: Task %s has no %s address", t.task, r.addrType)
+			continue
+		}
+		r.l.Printf("[DEBUG] discover-aws: Task %s has %s address %s", t.task, r.addrType, t.ip)
+		if t.port != "" {
+			addrs = append(addrs, net.JoinHostPort(t.ip, t.port))
+		} else {
+			addrs = append(addrs, t.ip)
+		}
+	}
+
+	r.l.Printf("[INFO] discover-aws: Retrieved %d IPs from %d Tasks", len(addrs), len(tasks))
+	return addrs, nil
+}
+
+// getEniFromTaskDescription returns the details of the elastic network
+// interface attachment of a task in awsvpc network mode, e.g. its
+// privateIPv4Address, ipv6Address and networkInterfaceId, or nil.
+func getEniFromTaskDescription(taskDesc *ecstypes.Task, l *log.Logger) map[string]string {
+	l.Printf("[DEBUG] discover-aws: Searching %d attachments for IPs", len(taskDesc.Attachments))
+	for _, attachment := range taskDesc.Attachments {
+		if aws.ToString(attachment.Type) != "ElasticNetworkInterface" {
+			continue
+		}
+
+		details := map[string]string{}
+		for _, detail := range attachment.Details {
+			details[aws.ToString(detail.Name)] = aws.ToString(detail.Value)
+		}
+		return details
+	}
+	return nil
+}
+
+// taskPort returns the port at which the container port ecs_port of the
+// task is reachable, which is the bound host port in bridge and host
+// network mode, or 0 if the task doesn't expose it.
+func (r *ecsResolver) taskPort(task *ecstypes.Task, awsvpc bool) (int32, error) {
+	containerPort, err := r.containerPort(task)
+	if err != nil || containerPort == 0 {
+		return 0, err
+	}
+
+	for _, c := range task.Containers {
+		if r.container != "" && aws.ToString(c.Name) != r.container {
+			continue
+		}
+		if awsvpc {
+			return containerPort, nil
+		}
+		for _, b := range c.NetworkBindings {
+			if aws.ToInt32(b.ContainerPort) == containerPort && aws.ToInt32(b.HostPort) != 0 {
+				return *b.HostPort, nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// containerPort resolves ecs_port to a container port. Port names are
+// looked up in the port mappings of the task definition.
+func (r *ecsResolver) containerPort(task *ecstypes.Task) (int32, error) {
+	if n, err := strconv.Atoi(r.port); err == nil {
+		return int32(n), nil
+	}
+
+	arn := aws.ToString(task.TaskDefinitionArn)
+	def, ok := r.taskDefs[arn]
+	if !ok {
+		out, err := r.ecs.DescribeTaskDefinition(context.TODO(), &ecs.DescribeTaskDefinitionInput{
+			TaskDefinition: task.TaskDefinitionArn,
+		})
+		if err != nil {
+			return 0, fmt.Errorf("DescribeTaskDefinition failed: %s", err)
+		}
+		def = out.TaskDefinition
+		r.taskDefs[arn] = def
+	}
+	if def == nil {
+		return 0, nil
+	}
+
+	for _, c := range def.ContainerDefinitions {
+		if r.container != "" && aws.ToString(c.Name) != r.container {
+			continue
+		}
+		for _, m := range c.PortMappings {
+			if aws.ToString(m.Name) == r.port {
+				return aws.ToInt32(m.ContainerPort), nil
+			}
+		}
+	}
+	return 0, nil
+}
+
+// eniPublicIPs returns the public IPv4 addresses of the network
+// interfaces enis by their id.
+func (r *ecsResolver) eniPublicIPs(enis []string) (map[string]string, error) {
+	ips := map[string]string{}
+	if len(enis) == 0 {
+		return ips, nil
+	}
+
+	paginator := ec2.NewDescribeNetworkInterfacesPaginator(r.ec2, &ec2.DescribeNetworkInterfacesInput{
+		NetworkInterfaceIds: enis,
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("DescribeNetworkInterfaces failed: %s", err)
+		}
+		for _, ni := range page.NetworkInterfaces {
+			if ni.Association != nil && ni.Association.PublicIp != nil {
+				ips[aws.ToString(ni.NetworkInterfaceId)] = *ni.Association.PublicIp
+			}
+		}
+	}
+	return ips, nil
+}
+
+// containerInstanceIPs returns the addresses of the EC2 instances of the
+// container instances arns in clusterArn by their ARN.
+func (r *ecsResolver) containerInstanceIPs(clusterArn string, arns []string) (map[string]string, error) {
+	ips := map[string]string{}
+	if len(arns) == 0 {
+		return ips, nil
+	}
+
+	out, err := r.ecs.DescribeContainerInstances(context.TODO(), &ecs.DescribeContainerInstancesInput{
+		Cluster:            aws.String(clusterArn),
+		ContainerInstances: arns,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("DescribeContainerInstances failed: %s", err)
+	}
+	byInstance := map[string]string{}
+	var ids []string
+	for _, ci := range out.ContainerInstances {
+		id := aws.ToString(ci.Ec2InstanceId)
+		if id == "" {
+			continue
+		}
+		if _, ok := byInstance[id]; !ok {
+			ids = append(ids, id)
+		}
+		byInstance[id] = aws.ToString(ci.ContainerInstanceArn)
+	}
+	if len(ids) == 0 {
+		return ips, nil
+	}
+
+	// A container instance may run several tasks, so there are at most
+	// as many instances as the 100 tasks of a DescribeTasks request.
+	filters := []types.Filter{{Name: aws.String("instance-id"), Values: ids}}
+	reservations, err := describeInstances(r.ec2, filters, r.l)
+	if err != nil {
+		return nil, err
+	}
+	for _, res := range reservations {
+		for _, inst := range res.Instances {
+			if addrs := InstanceAddrs(inst, r.addrType, -1, r.l); len(addrs) > 0 {
+				ips[byInstance[aws.ToString(inst.InstanceId)]] = addrs[0]
+			}
+		}
+	}
+	return ips, nil
+}
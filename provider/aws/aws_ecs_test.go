@@ -0,0 +1,353 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+const testECSARN = "arn:aws:ecs:us-east-1:123456789012:"
+
+// testTask is an ECS task served by the fake ECS API. Its task
+// definition is the family with a single container.
+type testTask struct {
+	Cluster           string
+	Service           string
+	Family            string
+	ID                string
+	Tags              map[string]string
+	ENI               map[string]string // attachment details in awsvpc network mode
+	ContainerInstance string            // EC2 instance id in bridge and host network mode
+	Container         string
+	Ports             map[string][2]int32 // port mapping name to container and host port
+}
+
+var testTasks = []testTask{
+	{
+		Cluster: "consul", Service: "consul-server", Family: "consul", ID: "t-1",
+		Tags:      map[string]string{"consul": "server"},
+		ENI:       map[string]string{"networkInterfaceId": "eni-1", "privateIPv4Address": "10.1.0.1", "ipv6Address": "2001:db8::1"},
+		Container: "consul",
+		Ports:     map[string][2]int32{"serf-lan": {8301, 8301}},
+	},
+	{
+		Cluster: "consul", Service: "consul-server", Family: "consul", ID: "t-2",
+		Tags:      map[string]string{"consul": "server"},
+		ENI:       map[string]string{"networkInterfaceId": "eni-2", "privateIPv4Address": "10.1.0.2", "ipv6Address": "fd00::2"},
+		Container: "consul",
+		Ports:     map[string][2]int32{"serf-lan": {8301, 8301}},
+	},
+	{
+		Cluster: "consul", Service: "consul-client", Family: "consul-client", ID: "t-3",
+		Tags:              map[string]string{"consul": "client"},
+		ContainerInstance: "i-1",
+		Container:         "consul",
+		Ports:             map[string][2]int32{"serf-lan": {8301, 32768}},
+	},
+	{
+		Cluster: "web", Service: "web", Family: "web", ID: "t-4",
+		ContainerInstance: "i-2",
+		Container:         "web",
+		Ports:             map[string][2]int32{"http": {8080, 8080}},
+	},
+}
+
+// ecs serves the ECS JSON API action of target.
+func (f *fakeAWS) ecs(w http.ResponseWriter, r *http.Request, target string) {
+	var req struct {
+		Cluster            string
+		Family             string
+		ServiceName        string
+		TaskDefinition     string
+		Tasks              []string
+		ContainerInstances []string
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	cluster := req.Cluster[strings.LastIndex(req.Cluster, "/")+1:]
+
+	var resp interface{}
+	switch strings.TrimPrefix(target, "AmazonEC2ContainerServiceV20141113.") {
+	case "ListClusters":
+		var arns []string
+		for _, t := range f.Tasks {
+			if arn := testECSARN + "cluster/" + t.Cluster; len(arns) == 0 || arns[len(arns)-1] != arn {
+				arns = append(arns, arn)
+			}
+		}
+		resp = map[string]interface{}{"clusterArns": arns}
+
+	case "ListTasks":
+		arns := []string{}
+		found := false
+		for _, t := range f.Tasks {
+			if t.Cluster != cluster || (req.ServiceName != "" && t.Service != req.ServiceName) {
+				continue
+			}
+			found = true
+			if req.Family == "" || t.Family == req.Family {
+				arns = append(arns, testECSARN+"task/"+t.Cluster+"/"+t.ID)
+			}
+		}
+		if req.ServiceName != "" && !found {
+			w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"ServiceNotFoundException","message":"Service not found."}`)
+			return
+		}
+		resp = map[string]interface{}{"taskArns": arns}
+
+	case "DescribeTasks":
+		tasks := []interface{}{}
+		for _, arn := range req.Tasks {
+			for _, t := range f.Tasks {
+				if arn == testECSARN+"task/"+t.Cluster+"/"+t.ID {
+					tasks = append(tasks, t.describe())
+				}
+			}
+		}
+		resp = map[string]interface{}{"tasks": tasks, "failures": []interface{}{}}
+
+	case "DescribeTaskDefinition":
+		for _, t := range f.Tasks {
+			if req.TaskDefinition == testECSARN+"task-definition/"+t.Family+":1" {
+				var mappings []interface{}
+				for name, p := range t.Ports {
+					mappings = append(mappings, map[string]interface{}{"name": name, "containerPort": p[0]})
+				}
+				resp = map[string]interface{}{"taskDefinition": map[string]interface{}{
+					"taskDefinitionArn":    req.TaskDefinition,
+					"containerDefinitions": []interface{}{map[string]interface{}{"name": t.Container, "portMappings": mappings}},
+				}}
+			}
+		}
+
+	case "DescribeContainerInstances":
+		instances := []interface{}{}
+		for _, arn := range req.ContainerInstances {
+			instances = append(instances, map[string]interface{}{
+				"containerInstanceArn": arn,
+				"ec2InstanceId":        arn[strings.LastIndex(arn, "/")+1:],
+			})
+		}
+		resp = map[string]interface{}{"containerInstances": instances}
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	if resp == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprintf(w, `{"__type":"InvalidParameterException","message":%q}`, target)
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+// describe returns the task as described by DescribeTasks.
+func (t testTask) describe() map[string]interface{} {
+	var tags, details, bindings []interface{}
+	for k, v := range t.Tags {
+		tags = append(tags, map[string]string{"key": k, "value": v})
+	}
+	for k, v := range t.ENI {
+		details = append(details, map[string]string{"name": k, "value": v})
+	}
+	for _, p := range t.Ports {
+		if t.ENI == nil {
+			bindings = append(bindings, map[string]interface{}{"containerPort": p[0], "hostPort": p[1], "protocol": "tcp"})
+		}
+	}
+
+	task := map[string]interface{}{
+		"taskArn":           testECSARN + "task/" + t.Cluster + "/" + t.ID,
+		"clusterArn":        testECSARN + "cluster/" + t.Cluster,
+		"taskDefinitionArn": testECSARN + "task-definition/" + t.Family + ":1",
+		"desiredStatus":     "RUNNING",
+		"lastStatus":        "RUNNING",
+		"tags":              tags,
+		"containers":        []interface{}{map[string]interface{}{"name": t.Container, "networkBindings": bindings}},
+	}
+	if details != nil {
+		task["attachments"] = []interface{}{map[string]interface{}{"type": "ElasticNetworkInterface", "status": "ATTACHED", "details": details}}
+	}
+	if t.ContainerInstance != "" {
+		task["containerInstanceArn"] = testECSARN + "container-instance/" + t.Cluster + "/" + t.ContainerInstance
+	}
+	return task
+}
+
+func (f *fakeAWS) describeNetworkInterfaces(w http.ResponseWriter, form url.Values) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, `<DescribeNetworkInterfacesResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req</requestId><networkInterfaceSet>`)
+	for n := 1; form.Get(fmt.Sprintf("NetworkInterfaceId.%d", n)) != ""; n++ {
+		id := form.Get(fmt.Sprintf("NetworkInterfaceId.%d", n))
+		fmt.Fprintf(w, `<item><networkInterfaceId>%s</networkInterfaceId>`, id)
+		if ip := f.PublicIPs[id]; ip != "" {
+			fmt.Fprintf(w, `<association><publicIp>%s</publicIp></association>`, ip)
+		}
+		fmt.Fprint(w, `</item>`)
+	}
+	fmt.Fprint(w, `</networkInterfaceSet></DescribeNetworkInterfacesResponse>`)
+}
+
+func TestAddrsECSLocal(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	srv := httptest.NewServer(&fakeAWS{
+		Instances: testInstances,
+		Tasks:     testTasks,
+		PublicIPs: map[string]string{"eni-1": "203.0.113.1"},
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"no selector",
+			map[string]string{"ecs_port": "8301"},
+			nil,
+			"tag_key, ecs_service, ecs_family or ecs_cluster is required",
+		},
+		{
+			"all tasks of cluster",
+			map[string]string{"ecs_cluster": "consul"},
+			[]string{"10.1.0.1", "10.1.0.2", "10.0.0.1"},
+			"",
+		},
+		{
+			"tag",
+			map[string]string{"tag_key": "consul", "tag_value": "server"},
+			[]string{"10.1.0.1", "10.1.0.2"},
+			"",
+		},
+		{
+			"service in some clusters",
+			map[string]string{"ecs_service": "consul-server"},
+			[]string{"10.1.0.1", "10.1.0.2"},
+			"",
+		},
+		{
+			"service in cluster",
+			map[string]string{"ecs_cluster": "web", "ecs_service": "web"},
+			[]string{"10.0.0.2"},
+			"",
+		},
+		{
+			"public IPv4 of network interface",
+			map[string]string{"ecs_service": "consul-server", "addr_type": "public_v4"},
+			[]string{"203.0.113.1"},
+			"",
+		},
+		{
+			"public IPv6 of network interface",
+			map[string]string{"ecs_service": "consul-server", "addr_type": "public_v6"},
+			[]string{"2001:db8::1"},
+			"",
+		},
+		{
+			"private IPv6 of network interface",
+			map[string]string{"ecs_service": "consul-server", "addr_type": "private_v6"},
+			[]string{"fd00::2"},
+			"",
+		},
+		{
+			"public IPv4 of container instance",
+			map[string]string{"ecs_cluster": "web", "addr_type": "public_v4"},
+			[]string{"198.51.100.2"},
+			"",
+		},
+		{
+			"container port",
+			map[string]string{"ecs_service": "consul-server", "ecs_port": "8301"},
+			[]string{"10.1.0.1:8301", "10.1.0.2:8301"},
+			"",
+		},
+		{
+			"IPv6 and container port",
+			map[string]string{"ecs_service": "consul-server", "ecs_port": "serf-lan", "addr_type": "public_v6"},
+			[]string{"[2001:db8::1]:8301"},
+			"",
+		},
+		{
+			"bridge network mode port name",
+			map[string]string{"ecs_family": "consul-client", "ecs_port": "serf-lan"},
+			[]string{"10.0.0.1:32768"},
+			"",
+		},
+		{
+			"host network mode container",
+			map[string]string{"ecs_cluster": "web", "ecs_container": "web", "ecs_port": "http"},
+			[]string{"10.0.0.2:8080"},
+			"",
+		},
+		{
+			"tasks without port",
+			map[string]string{"ecs_cluster": "consul", "ecs_port": "http"},
+			nil,
+			"",
+		},
+		{
+			"other container",
+			map[string]string{"ecs_service": "consul-server", "ecs_container": "envoy", "ecs_port": "8301"},
+			nil,
+			"",
+		},
+		{
+			"unknown service in cluster",
+			map[string]string{"ecs_cluster": "web", "ecs_service": "consul-server"},
+			nil,
+			"ServiceNotFoundException",
+		},
+		{
+			"container without port",
+			map[string]string{"ecs_service": "consul-server", "ecs_container": "consul"},
+			nil,
+			"ecs_container requires ecs_port",
+		},
+		{
+			"invalid port",
+			map[string]string{"ecs_service": "consul-server", "ecs_port": "0"},
+			nil,
+			"invalid ecs_port",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			args["service"] = "ecs"
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2"
+	elbtypes "github.com/aws/aws-sdk-go-v2/service/elasticloadbalancingv2/types"
+)
+
+// elbv2Addrs discovers the addresses of the healthy targets of the target
+// groups selected by target_group_arn and target_group_name. Instance
+// targets have the addresses of the instance for addrType and the network
+// interface at device, and IP targets their IP address.
+func elbv2Addrs(cfg aws.Config, addrType string, device int, args map[string]string, l *log.Logger) ([]string, error) {
+	endpoint := args["endpoint"]
+	withPort := false
+	if v := args["target_port"]; v != "" {
+		var err error
+		if withPort, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-aws: invalid target_port %q", v)
+		}
+	}
+
+	svc := elasticloadbalancingv2.NewFromConfig(cfg, func(o *elasticloadbalancingv2.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			l.Printf("[INFO] discover-aws: Endpoint is %s", endpoint)
+		}
+	})
+
+	groupArns := splitList(args["target_group_arn"])
+	if names := splitList(args["target_group_name"]); len(names) > 0 {
+		paginator := elasticloadbalancingv2.NewDescribeTargetGroupsPaginator(svc, &elasticloadbalancingv2.DescribeTargetGroupsInput{
+			Names: names,
+		})
+		for paginator.HasMorePages() {
+			page, err := paginator.NextPage(context.TODO())
+			if err != nil {
+				return nil, fmt.Errorf("discover-aws: DescribeTargetGroups failed: %s", err)
+			}
+			for _, g := range page.TargetGroups {
+				groupArns = append(groupArns, aws.ToString(g.TargetGroupArn))
+			}
+		}
+	}
+	if len(groupArns) == 0 {
+		return nil, fmt.Errorf("discover-aws: target_group_arn or target_group_name is required for service \"elbv2\"")
+	}
+
+	var targets []elbtypes.TargetDescription
+	var ids []string
+	for _, arn := range groupArns {
+		l.Printf("[INFO] discover-aws: Describe health of targets of %s", arn)
+		out, err := svc.DescribeTargetHealth(context.TODO(), &elasticloadbalancingv2.DescribeTargetHealthInput{
+			TargetGroupArn: aws.String(arn),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("discover-aws: DescribeTargetHealth failed: %s", err)
+		}
+		for _, d := range out.TargetHealthDescriptions {
+			if d.Target == nil || d.TargetHealth == nil {
+				continue
+			}
+			id := aws.ToString(d.Target.Id)
+			if d.TargetHealth.State != elbtypes.TargetHealthStateEnumHealthy {
+				l.Printf("[DEBUG] discover-aws: Ignoring target %s in state %s", id, d.TargetHealth.State)
+				continue
+			}
+			// Targets are instance ids, IP addresses or the ARN of an
+			// Application Load Balancer which has no addresses.
+			switch {
+			case strings.HasPrefix(id, "i-"):
+				ids = append(ids, id)
+			case net.ParseIP(id) == nil:
+				l.Printf("[DEBUG] discover-aws: Ignoring target %s", id)
+				continue
+			}
+			targets = append(targets, *d.Target)
+		}
+	}
+	l.Printf("[DEBUG] discover-aws: Found %d healthy targets", len(targets))
+
+	instances := map[string][]string{}
+	if len(ids) > 0 {
+		svc := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+			if endpoint != "" {
+				o.BaseEndpoint = aws.String(endpoint)
+			}
+		})
+		// Requests are limited to 200 values per filter.
+		const maxIDs = 200
+		for i := 0; i < len(ids); i += maxIDs {
+			idFilter := types.Filter{Name: aws.String("instance-id"), Values: ids[i:min(i+maxIDs, len(ids))]}
+			reservations, err := describeInstances(svc, []types.Filter{idFilter}, l)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range reservations {
+				for _, inst := range r.Instances {
+					instances[aws.ToString(inst.InstanceId)] = InstanceAddrs(inst, addrType, device, l)
+				}
+			}
+		}
+	}
+
+	// An instance may be registered in several target groups or with
+	// several ports.
+	var addrs []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		id := aws.ToString(t.Id)
+		ips := []string{id}
+		if strings.HasPrefix(id, "i-") {
+			ips = instances[id]
+		}
+		for _, ip := range ips {
+			if withPort && t.Port != nil {
+				ip = net.JoinHostPort(ip, strconv.Itoa(int(*t.Port)))
+			}
+			if !seen[ip] {
+				seen[ip] = true
+				addrs = append(addrs, ip)
+			}
+		}
+	}
+
+	l.Printf("[DEBUG] discover-aws: Found ip addresses: %v", addrs)
+	return addrs, nil
+}
+
+// splitList returns the non-empty elements of the comma separated list v.
+func splitList(v string) []string {
+	var list []string
+	for _, s := range strings.Split(v, ",") {
+		if s = strings.TrimSpace(s); s != "" {
+			list = append(list, s)
+		}
+	}
+	return list
+}
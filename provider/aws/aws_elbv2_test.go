@@ -0,0 +1,182 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+// testTargetGroup is a target group served by the fake Elastic Load
+// Balancing API.
+type testTargetGroup struct {
+	Name    string
+	Targets []testTarget
+}
+
+type testTarget struct {
+	ID    string
+	Port  int
+	State string
+}
+
+func (g testTargetGroup) arn() string {
+	return "arn:aws:elasticloadbalancing:us-east-1:123456789012:targetgroup/" + g.Name + "/0123456789abcdef"
+}
+
+var testTargetGroups = []testTargetGroup{
+	{
+		Name: "consul",
+		Targets: []testTarget{
+			{"i-1", 8301, "healthy"},
+			{"i-2", 8301, "unhealthy"},
+			{"i-3", 8301, "healthy"},
+			{"i-3", 8302, "healthy"},
+		},
+	},
+	{
+		Name: "web",
+		Targets: []testTarget{
+			{"10.0.2.1", 80, "healthy"},
+			{"10.0.2.2", 80, "draining"},
+			{"i-1", 80, "healthy"},
+			{"arn:aws:elasticloadbalancing:us-east-1:123456789012:loadbalancer/app/web/0123456789abcdef", 80, "healthy"},
+		},
+	},
+}
+
+// elbError writes an Elastic Load Balancing error response.
+func elbError(w http.ResponseWriter, code, msg string) {
+	w.Header().Set("Content-Type", "text/xml")
+	w.WriteHeader(http.StatusBadRequest)
+	fmt.Fprintf(w, `<ErrorResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/"><Error><Type>Sender</Type><Code>%s</Code><Message>%s</Message></Error><RequestId>req</RequestId></ErrorResponse>`, code, msg)
+}
+
+func (f *fakeAWS) describeTargetGroups(w http.ResponseWriter, form url.Values) {
+	var groups []testTargetGroup
+	for n := 1; form.Get(fmt.Sprintf("Names.member.%d", n)) != ""; n++ {
+		name := form.Get(fmt.Sprintf("Names.member.%d", n))
+		found := false
+		for _, g := range f.TargetGroups {
+			if g.Name == name {
+				groups = append(groups, g)
+				found = true
+			}
+		}
+		if !found {
+			elbError(w, "TargetGroupNotFound", "One or more target groups not found")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, `<DescribeTargetGroupsResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/"><DescribeTargetGroupsResult><TargetGroups>`)
+	for _, g := range groups {
+		fmt.Fprintf(w, `<member><TargetGroupArn>%s</TargetGroupArn><TargetGroupName>%s</TargetGroupName></member>`, g.arn(), g.Name)
+	}
+	fmt.Fprint(w, `</TargetGroups></DescribeTargetGroupsResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></DescribeTargetGroupsResponse>`)
+}
+
+func (f *fakeAWS) describeTargetHealth(w http.ResponseWriter, form url.Values) {
+	for _, g := range f.TargetGroups {
+		if g.arn() != form.Get("TargetGroupArn") {
+			continue
+		}
+		w.Header().Set("Content-Type", "text/xml")
+		fmt.Fprint(w, `<DescribeTargetHealthResponse xmlns="http://elasticloadbalancing.amazonaws.com/doc/2015-12-01/"><DescribeTargetHealthResult><TargetHealthDescriptions>`)
+		for _, t := range g.Targets {
+			fmt.Fprintf(w, `<member><Target><Id>%s</Id><Port>%d</Port></Target><TargetHealth><State>%s</State></TargetHealth></member>`, t.ID, t.Port, t.State)
+		}
+		fmt.Fprint(w, `</TargetHealthDescriptions></DescribeTargetHealthResult><ResponseMetadata><RequestId>req</RequestId></ResponseMetadata></DescribeTargetHealthResponse>`)
+		return
+	}
+	elbError(w, "TargetGroupNotFound", "Target groups '"+form.Get("TargetGroupArn")+"' not found")
+}
+
+func TestAddrsELBv2(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	srv := httptest.NewServer(&fakeAWS{Instances: testInstances, TargetGroups: testTargetGroups})
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"target group arn",
+			map[string]string{"target_group_arn": testTargetGroups[0].arn()},
+			[]string{"10.0.0.1", "10.0.1.3"},
+			"",
+		},
+		{
+			"target port",
+			map[string]string{"target_group_name": "consul", "target_port": "true"},
+			[]string{"10.0.0.1:8301", "10.0.1.3:8301", "10.0.1.3:8302"},
+			"",
+		},
+		{
+			"target group names",
+			map[string]string{"target_group_name": "consul, web"},
+			[]string{"10.0.0.1", "10.0.1.3", "10.0.2.1"},
+			"",
+		},
+		{
+			"public addresses of instance targets",
+			map[string]string{"target_group_name": "web", "addr_type": "public_v4"},
+			[]string{"10.0.2.1", "198.51.100.1"},
+			"",
+		},
+		{
+			"unknown target group name",
+			map[string]string{"target_group_name": "vault"},
+			nil,
+			"TargetGroupNotFound",
+		},
+		{
+			"missing target group",
+			map[string]string{"service": "elbv2"},
+			nil,
+			"target_group_arn or target_group_name is required",
+		},
+		{
+			"invalid target port",
+			map[string]string{"target_group_name": "web", "target_port": "8080"},
+			nil,
+			"invalid target_port",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
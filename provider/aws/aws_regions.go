@@ -0,0 +1,125 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/hashicorp/go-multierror"
+)
+
+// multiRegionAddrs discovers the addresses of service in all regions of
+// regions concurrently and merges them in the order of the regions.
+// region is used to look up the enabled regions for "all_enabled".
+func (p *Provider) multiRegionAddrs(region, service, addrType string, args map[string]string, l *log.Logger) ([]string, error) {
+	partial := false
+	if v := args["partial_results"]; v != "" {
+		var err error
+		if partial, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-aws: partial_results must be boolean value: %s", err)
+		}
+	}
+
+	var regions []string
+	seen := map[string]bool{}
+	for _, r := range strings.Split(args["regions"], ",") {
+		r = strings.TrimSpace(r)
+		if r == "all_enabled" {
+			enabled, err := enabledRegions(region, addrType, args, l)
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range enabled {
+				if !seen[r] {
+					seen[r] = true
+					regions = append(regions, r)
+				}
+			}
+			continue
+		}
+		if r != "" && !seen[r] {
+			seen[r] = true
+			regions = append(regions, r)
+		}
+	}
+	l.Printf("[INFO] discover-aws: Regions are %s", strings.Join(regions, ","))
+
+	addrs := make([][]string, len(regions))
+	errs := make([]error, len(regions))
+	var wg sync.WaitGroup
+	for i, r := range regions {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			addrs[i], errs[i] = p.regionAddrs(r, service, addrType, args, l)
+		}()
+	}
+	wg.Wait()
+
+	var all []string
+	var merr *multierror.Error
+	for i, r := range regions {
+		if errs[i] != nil {
+			merr = multierror.Append(merr, fmt.Errorf("region %s: %w", r, errs[i]))
+			continue
+		}
+		all = append(all, addrs[i]...)
+	}
+
+	if err := merr.ErrorOrNil(); err != nil {
+		merr.ErrorFormat = func(errs []error) string {
+			s := make([]string, len(errs))
+			for i, err := range errs {
+				s[i] = err.Error()
+			}
+			return strings.Join(s, "; ")
+		}
+		if !partial || len(merr.Errors) == len(regions) {
+			return nil, fmt.Errorf("discover-aws: %d of %d regions failed: %w", len(merr.Errors), len(regions), err)
+		}
+		for _, err := range merr.Errors {
+			l.Printf("[WARN] discover-aws: Ignoring failed %s", err)
+		}
+	}
+	return all, nil
+}
+
+// enabledRegions returns the regions enabled for the account, looked up
+// in region or the region of the instance if it is empty.
+func enabledRegions(region, addrType string, args map[string]string, l *log.Logger) ([]string, error) {
+	if region == "" {
+		var err error
+		if region, err = instanceRegion(l); err != nil {
+			return nil, err
+		}
+	}
+
+	cfg, err := loadConfig(context.TODO(), region, addrType, args, l)
+	if err != nil {
+		return nil, err
+	}
+	svc := ec2.NewFromConfig(cfg, func(o *ec2.Options) {
+		if endpoint := args["endpoint"]; endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	})
+
+	// Without AllRegions only the enabled regions are returned.
+	resp, err := svc.DescribeRegions(context.TODO(), &ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, fmt.Errorf("discover-aws: DescribeRegions failed: %s", err)
+	}
+	var regions []string
+	for _, r := range resp.Regions {
+		regions = append(regions, aws.ToString(r.RegionName))
+	}
+	return regions, nil
+}
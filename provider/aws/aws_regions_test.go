@@ -0,0 +1,115 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package aws_test
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/go-discover/provider/aws"
+)
+
+func (f *fakeAWS) describeRegions(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/xml")
+	fmt.Fprint(w, `<DescribeRegionsResponse xmlns="http://ec2.amazonaws.com/doc/2016-11-15/"><requestId>req</requestId><regionInfo>`)
+	for _, r := range f.Regions {
+		fmt.Fprintf(w, `<item><regionName>%s</regionName><regionEndpoint>ec2.%s.amazonaws.com</regionEndpoint><optInStatus>opt-in-not-required</optInStatus></item>`, r, r)
+	}
+	fmt.Fprint(w, `</regionInfo></DescribeRegionsResponse>`)
+}
+
+func TestAddrsRegions(t *testing.T) {
+	t.Setenv("AWS_USE_DUALSTACK_ENDPOINT", "")
+	srv := httptest.NewServer(&fakeAWS{
+		Instances: []testInstance{
+			{Region: "us-east-1", ID: "i-1", State: "running", PrivateIP: "10.1.0.1", Tags: map[string]string{"consul": "server"}},
+			{Region: "us-east-1", ID: "i-2", State: "running", PrivateIP: "10.1.0.2", Tags: map[string]string{"consul": "server"}},
+			{Region: "eu-west-1", ID: "i-3", State: "running", PrivateIP: "10.2.0.1", Tags: map[string]string{"consul": "server"}},
+			{Region: "ap-south-1", ID: "i-4", State: "running", PrivateIP: "10.3.0.1", Tags: map[string]string{"consul": "server"}},
+			{Region: "sa-east-1", ID: "i-5", State: "running", PrivateIP: "10.4.0.1", Tags: map[string]string{"consul": "server"}},
+		},
+		Regions:     []string{"us-east-1", "eu-west-1", "sa-east-1"},
+		FailRegions: map[string]bool{"sa-east-1": true},
+	})
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"single region",
+			map[string]string{"region": "eu-west-1"},
+			[]string{"10.2.0.1"},
+			"",
+		},
+		{
+			"regions",
+			map[string]string{"regions": "eu-west-1, us-east-1,ap-south-1"},
+			[]string{"10.2.0.1", "10.1.0.1", "10.1.0.2", "10.3.0.1"},
+			"",
+		},
+		{
+			"failed region",
+			map[string]string{"regions": "us-east-1,sa-east-1"},
+			nil,
+			"1 of 2 regions failed: region sa-east-1:",
+		},
+		{
+			"partial results",
+			map[string]string{"regions": "us-east-1,sa-east-1", "partial_results": "true"},
+			[]string{"10.1.0.1", "10.1.0.2"},
+			"",
+		},
+		{
+			"partial results without results",
+			map[string]string{"regions": "sa-east-1", "partial_results": "true"},
+			nil,
+			"1 of 1 regions failed",
+		},
+		{
+			"all enabled regions",
+			map[string]string{"regions": "all_enabled,ap-south-1", "partial_results": "true"},
+			[]string{"10.1.0.1", "10.1.0.2", "10.2.0.1", "10.3.0.1"},
+			"",
+		},
+		{
+			"invalid partial results",
+			map[string]string{"regions": "us-east-1", "partial_results": "sometimes"},
+			nil,
+			"partial_results must be boolean value",
+		},
+	}
+
+	p := &aws.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := localArgs(srv.URL)
+			args["tags"] = "consul=server"
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
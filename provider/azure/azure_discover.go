@@ -9,12 +9,19 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
 	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/compute/armcompute"
 	"github.com/Azure/azure-sdk-for-go/sdk/resourcemanager/network/armnetwork"
 )
 
@@ -34,7 +41,23 @@ func (p *Provider) Help() string {
    client_id:         The id of the client
    subscription_id:   The id of the subscription
    secret_access_key: The authentication credential
+   client_certificate_path: Path of a PEM or PKCS#12 certificate with private key to authenticate
+                      the client with instead of secret_access_key
+   client_certificate_password: The password of the client certificate, if any
+   federated_token_file: Path of a federated token file to authenticate the client with workload
+                      identity, for example on AKS
+   msi_client_id:     The client id of the user-assigned managed identity to authenticate with
+   environment:       The Azure cloud, "AzurePublicCloud", "AzureChinaCloud" or "AzureUSGovernment",
+                      or "public", "china" or "usgovernment" like ARM_ENVIRONMENT of Terraform.
+                      Defaults to "AzurePublicCloud".
+   resource_manager_endpoint: Custom Azure Resource Manager endpoint, for example of Azure Stack Hub
+   resource_manager_audience: The token audience of the custom Azure Resource Manager endpoint.
+                      Defaults to resource_manager_endpoint.
+   authority_host:    Custom Microsoft Entra ID authority host, for example https://login.example.com/
    msft_telemetry_opt_in: Optional boolean string to opt in to sending telemetry to Microsoft
+   addr_type:         "private_v4", "private_v6", "public_v4" or "public_v6". Defaults to "private_v4".
+                      The addresses of all IP configurations of the network interfaces are returned,
+                      public addresses are those of the public IP addresses they reference.
 
     **NOTE** The secret_access_key value often may have an equals sign in it's value,
     especially if generated from the Azure Portal. So is important to wrap in single quotes
@@ -45,12 +68,18 @@ func (p *Provider) Help() string {
     export ARM_TENANT_ID for tenant
     export ARM_CLIENT_ID for client
     export ARM_CLIENT_SECRET for secret access key
+    export ARM_CLIENT_CERTIFICATE_PATH for client certificate path
+    export ARM_CLIENT_CERTIFICATE_PASSWORD for client certificate password
+    export AZURE_FEDERATED_TOKEN_FILE for federated token file
+    export ARM_ENVIRONMENT for environment
 
    Set the following environment variables to enable AzureSDK client's log and telemetry:
 	export AZURE_SDK_GO_LOGGING=all
 	export OPT_IN_MSFT_TELEMETRY=true
 
 
+   The client is authenticated with the first of its secret, its certificate, a federated token file
+   and the managed identity msi_client_id which is configured.
    If none of those options are given, the Azure SDK is using the default  environment based authentication outlined
    here https://docs.microsoft.com/en-us/go/azure/azure-sdk-go-authorization#use-environment-based-authentication
    This will fallback to MSI if nothing is explicitly specified.
@@ -59,17 +88,27 @@ func (p *Provider) Help() string {
 
    tag_name:          The name of the tag to filter on
    tag_value:         The value of the tag to filter on
+   tag_scope:         "nic" to match the tags of network interfaces or "vm" to match the tags of
+                      virtual machines and use their network interfaces. Defaults to "nic".
+   resource_group:    Optional name of the resource group to filter on
 
    Use these configuration parameters when using Virtual Machine Scale Sets:
 
    resource_group:    The name of the resource group to filter on
    vm_scale_set:      The name of the virtual machine scale set to filter on
+   orchestration_mode: The orchestration mode of the scale set, "uniform" or "flexible".
+                      Defaults to the mode of the scale set, which is looked up.
 
-   When using tags the only permission needed is Microsoft.Network/networkInterfaces/*
+   When using tags the only permission needed is Microsoft.Network/networkInterfaces/*,
+   with tag_scope "vm" Microsoft.Compute/virtualMachines/read is needed too.
 
-   When using Virtual Machine Scale Sets the only role action needed is Microsoft.Compute/virtualMachineScaleSets/*/read.
-   The Azure provider only supports Virtual Machine Scale Sets deployed in [Uniform mode](https://learn.microsoft.com/en-us/azure/virtual-machine-scale-sets/virtual-machine-scale-sets-orchestration-modes#scale-sets-with-uniform-orchestration).
-   As of 2023 VMSS deploys using Flexible mode by default.
+   Public addresses require Microsoft.Network/publicIPAddresses/read, and for Uniform scale
+   sets Microsoft.Compute/virtualMachineScaleSets/*/read.
+
+   When using Virtual Machine Scale Sets in Uniform mode the only role action needed is
+   Microsoft.Compute/virtualMachineScaleSets/*/read. In Flexible mode, the default since 2023,
+   the addresses are looked up from the virtual machines of the scale set, which requires
+   Microsoft.Compute/virtualMachines/read and Microsoft.Network/networkInterfaces/read too.
 
    It is recommended you make a dedicated key used only for auto-joining.
 `
@@ -103,12 +142,6 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 		l = log.New(io.Discard, "", 0)
 	}
 
-	// check for environmental variables, and use if the argument hasn't been set in config
-	tenantID := argsOrEnv(args, "tenant_id", "ARM_TENANT_ID")
-	clientID := argsOrEnv(args, "client_id", "ARM_CLIENT_ID")
-	subscriptionID := argsOrEnv(args, "subscription_id", "ARM_SUBSCRIPTION_ID")
-	secretKey := argsOrEnv(args, "secret_access_key", "ARM_CLIENT_SECRET")
-
 	var clientPolicies []policy.Policy
 	// AzureSDK clients create their own connection pipelines and inherit
 	// from the credential config's policy.ClientOptions object
@@ -138,63 +171,216 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 		},
 	}
 
+	return ClientOptionsAddrs(args, clientOpts, l)
+}
+
+// ClientOptionsAddrs discovers the addresses in the cloud and with the
+// credential configured by args, with the client options clientOpts for
+// the credential and the clients.
+//
+// This is a separate method so that we can unit test this against a
+// local stand-in for Microsoft Entra ID and Azure Resource Manager. It
+// shouldn't generally be called externally.
+func ClientOptionsAddrs(args map[string]string, clientOpts policy.ClientOptions, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	cloudConf, err := cloudConfig(args)
+	if err != nil {
+		return nil, err
+	}
+	clientOpts.Cloud = cloudConf
+	// Instance discovery only knows the authorities of the Azure clouds
+	disableInstanceDiscovery := args["authority_host"] != ""
+
+	// check for environmental variables, and use if the argument hasn't been set in config
+	tenantID := argsOrEnv(args, "tenant_id", "ARM_TENANT_ID")
+	clientID := argsOrEnv(args, "client_id", "ARM_CLIENT_ID")
+	secretKey := argsOrEnv(args, "secret_access_key", "ARM_CLIENT_SECRET")
+	certPath := argsOrEnv(args, "client_certificate_path", "ARM_CLIENT_CERTIFICATE_PATH")
+	certPassword := argsOrEnv(args, "client_certificate_password", "ARM_CLIENT_CERTIFICATE_PASSWORD")
+	tokenFile := argsOrEnv(args, "federated_token_file", "AZURE_FEDERATED_TOKEN_FILE")
+	msiClientID := args["msi_client_id"]
+
 	// Try to use the argument and environment provided arguments first, if this fails fall back to the SDK's
 	// DefaultCredential which attempts to find default config for Azure envars, AzureWorkloadIdentityCredentials,
 	// Azure ManagedIdentityCredentials, as well as local credentials
 	// https://pkg.go.dev/github.com/Azure/azure-sdk-for-go/sdk/azidentity#DefaultAzureCredential
 
-	var (
-		clientSecretCred *azidentity.ClientSecretCredential
-		defaultCred      *azidentity.DefaultAzureCredential
-	)
-	if tenantID != "" && clientID != "" && secretKey != "" {
-		var err error
-		clientSecretCred, err = azidentity.NewClientSecretCredential(tenantID, clientID, secretKey,
-			&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOpts})
+	var cred azcore.TokenCredential
+	switch {
+	case tenantID != "" && clientID != "" && secretKey != "":
+		l.Printf("[DEBUG] discover-azure: using client secret of client %s", clientID)
+		cred, err = azidentity.NewClientSecretCredential(tenantID, clientID, secretKey,
+			&azidentity.ClientSecretCredentialOptions{ClientOptions: clientOpts, DisableInstanceDiscovery: disableInstanceDiscovery})
 
 		if err != nil {
 			return nil, fmt.Errorf("discover-azure (ClientCredentials): %w", err)
 		}
-	} else {
-		var err error
-		defaultCred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{ClientOptions: clientOpts})
+	case tenantID != "" && clientID != "" && certPath != "":
+		l.Printf("[DEBUG] discover-azure: using client certificate %s of client %s", certPath, clientID)
+		data, err := os.ReadFile(certPath)
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure (ClientCertificate): %w", err)
+		}
+		certs, key, err := azidentity.ParseCertificates(data, []byte(certPassword))
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure (ClientCertificate): %w", err)
+		}
+		cred, err = azidentity.NewClientCertificateCredential(tenantID, clientID, certs, key,
+			&azidentity.ClientCertificateCredentialOptions{ClientOptions: clientOpts, DisableInstanceDiscovery: disableInstanceDiscovery})
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure (ClientCertificate): %w", err)
+		}
+	case tokenFile != "":
+		// The tenant and client default to AZURE_TENANT_ID and
+		// AZURE_CLIENT_ID which the workload identity webhook sets.
+		l.Printf("[DEBUG] discover-azure: using workload identity with federated token file %s", tokenFile)
+		cred, err = azidentity.NewWorkloadIdentityCredential(&azidentity.WorkloadIdentityCredentialOptions{
+			ClientOptions:            clientOpts,
+			ClientID:                 clientID,
+			TenantID:                 tenantID,
+			TokenFilePath:            tokenFile,
+			DisableInstanceDiscovery: disableInstanceDiscovery,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure (WorkloadIdentity): %w", err)
+		}
+	case msiClientID != "":
+		l.Printf("[DEBUG] discover-azure: using managed identity %s", msiClientID)
+		cred, err = azidentity.NewManagedIdentityCredential(&azidentity.ManagedIdentityCredentialOptions{
+			ClientOptions: clientOpts,
+			ID:            azidentity.ClientID(msiClientID),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure (ManagedIdentity): %w", err)
+		}
+	default:
+		cred, err = azidentity.NewDefaultAzureCredential(&azidentity.DefaultAzureCredentialOptions{
+			ClientOptions:            clientOpts,
+			TenantID:                 tenantID,
+			DisableInstanceDiscovery: disableInstanceDiscovery,
+		})
 		if err != nil {
 			return nil, fmt.Errorf("discover-azure (EnvironmentCredentials): %w", err)
 		}
 	}
 
-	// Use tags if using network interfaces
+	return ClientAddrs(args, cred, &arm.ClientOptions{ClientOptions: clientOpts}, l)
+}
+
+// cloudConfig returns the configuration of the Azure cloud environment,
+// with the endpoints of resource_manager_endpoint and authority_host if
+// they are set.
+func cloudConfig(args map[string]string) (cloud.Configuration, error) {
+	var conf cloud.Configuration
+	switch env := argsOrEnv(args, "environment", "ARM_ENVIRONMENT"); strings.ToLower(env) {
+	// The short names are the values of ARM_ENVIRONMENT used by Terraform.
+	case "", "azurepubliccloud", "public":
+		conf = cloud.AzurePublic
+	case "azurechinacloud", "china":
+		conf = cloud.AzureChina
+	case "azureusgovernment", "azureusgovernmentcloud", "usgovernment":
+		conf = cloud.AzureGovernment
+	default:
+		return conf, fmt.Errorf("discover-azure: invalid environment %q", env)
+	}
+
+	// Copy the services, the configurations are shared
+	services := map[cloud.ServiceName]cloud.ServiceConfiguration{}
+	for k, v := range conf.Services {
+		services[k] = v
+	}
+	conf.Services = services
+
+	if endpoint := args["resource_manager_endpoint"]; endpoint != "" {
+		audience := args["resource_manager_audience"]
+		if audience == "" {
+			audience = endpoint
+		}
+		conf.Services[cloud.ResourceManager] = cloud.ServiceConfiguration{Endpoint: endpoint, Audience: audience}
+	}
+	if host := args["authority_host"]; host != "" {
+		conf.ActiveDirectoryAuthorityHost = host
+	}
+	return conf, nil
+}
+
+// clients are the Azure Resource Manager clients of a subscription.
+type clients struct {
+	subscriptionID string
+	interfaces     *armnetwork.InterfacesClient
+	publicIPs      *armnetwork.PublicIPAddressesClient
+	scaleSets      *armcompute.VirtualMachineScaleSetsClient
+	vms            *armcompute.VirtualMachinesClient
+}
+
+// ClientAddrs discovers the addresses with the Azure Resource Manager
+// clients created with cred and opts.
+//
+// This is a separate method so that we can unit test this against a
+// local stand-in for Azure Resource Manager. It shouldn't generally be
+// called externally.
+func ClientAddrs(args map[string]string, cred azcore.TokenCredential, opts *arm.ClientOptions, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	subscriptionID := argsOrEnv(args, "subscription_id", "ARM_SUBSCRIPTION_ID")
+
+	// Use tags if using network interfaces or virtual machines
 	tagName := args["tag_name"]
 	tagValue := args["tag_value"]
+	tagScope := args["tag_scope"]
 
 	// Use resourceGroup and vmScaleSet if using vm scale sets
 	resourceGroup := args["resource_group"]
 	vmScaleSet := args["vm_scale_set"]
 
+	addrType := args["addr_type"]
+	switch addrType {
+	case "private_v4", "private_v6", "public_v4", "public_v6":
+	case "":
+		addrType = "private_v4"
+	default:
+		l.Printf("[INFO] discover-azure: Address type %s is not supported. Valid values are {private_v4,private_v6,public_v4,public_v6}. Falling back to 'private_v4'", addrType)
+		addrType = "private_v4"
+	}
+
 	if subscriptionID == "" {
 		return nil, fmt.Errorf("discover-azure (Credentials): subscription_id not provided as argument or environment variable")
 	}
-	// Create NetworkInterfaceClient with the appropriate credential and no additional configuration
-	// as the client will inherit telemetry config from the credentials
-	var vmnet *armnetwork.InterfacesClient
-	if clientSecretCred != nil {
-		vmnet, err = armnetwork.NewInterfacesClient(subscriptionID, clientSecretCred, nil)
-		if err != nil {
-			return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
-		}
-	} else {
-		vmnet, err = armnetwork.NewInterfacesClient(subscriptionID, defaultCred, nil)
-		if err != nil {
-			return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
-		}
+	// Create the clients with the appropriate credential, they use the
+	// same user agent and telemetry config as the credentials
+	var err error
+	c := clients{subscriptionID: subscriptionID}
+	if c.interfaces, err = armnetwork.NewInterfacesClient(subscriptionID, cred, opts); err != nil {
+		return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
+	}
+	if c.publicIPs, err = armnetwork.NewPublicIPAddressesClient(subscriptionID, cred, opts); err != nil {
+		return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
+	}
+	if c.scaleSets, err = armcompute.NewVirtualMachineScaleSetsClient(subscriptionID, cred, opts); err != nil {
+		return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
+	}
+	if c.vms, err = armcompute.NewVirtualMachinesClient(subscriptionID, cred, opts); err != nil {
+		return nil, fmt.Errorf("discover-azure (Azure Client): %w", err)
 	}
 
-	if tagName != "" && tagValue != "" && resourceGroup == "" && vmScaleSet == "" {
-		l.Printf("[DEBUG] discover-azure: using tag method. tag_name: %s, tag_value: %s", tagName, tagValue)
-		return fetchAddrsWithTags(tagName, tagValue, *vmnet, l)
+	if tagName != "" && tagValue != "" && vmScaleSet == "" {
+		l.Printf("[DEBUG] discover-azure: using tag method. tag_name: %s, tag_value: %s, tag_scope: %s, resource_group: %s", tagName, tagValue, tagScope, resourceGroup)
+		switch strings.ToLower(tagScope) {
+		case "", "nic":
+			return fetchAddrsWithTags(tagName, tagValue, resourceGroup, addrType, c, l)
+		case "vm":
+			return fetchAddrsWithVmTags(tagName, tagValue, resourceGroup, addrType, c, l)
+		default:
+			return nil, fmt.Errorf("discover-azure: invalid tag_scope %q", tagScope)
+		}
 	} else if resourceGroup != "" && vmScaleSet != "" && tagName == "" && tagValue == "" {
 		l.Printf("[DEBUG] discover-azure: using vm scale set method. resource_group: %s, vm_scale_set: %s", resourceGroup, vmScaleSet)
-		return fetchAddrsWithVmScaleSet(resourceGroup, vmScaleSet, *vmnet, l)
+		return fetchAddrsWithVmScaleSet(resourceGroup, vmScaleSet, args["orchestration_mode"], addrType, c, l)
 	} else {
 		l.Printf("[ERROR] discover-azure: tag_name: %s, tag_value: %s", tagName, tagValue)
 		l.Printf("[ERROR] discover-azure: resource_group %s, vm_scale_set %s", resourceGroup, vmScaleSet)
@@ -202,15 +388,152 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	}
 }
 
-func fetchAddrsWithTags(tagName string, tagValue string, vmnet armnetwork.InterfacesClient, l *log.Logger) ([]string, error) {
+func fetchAddrsWithTags(tagName string, tagValue string, resourceGroup string, addrType string, c clients, l *log.Logger) ([]string, error) {
 	// Get all network interfaces across resource groups
-	// unless there is a compelling reason to restrict
+	// unless restricted to a resource group
+	ctx := context.Background()
+	var nics []*armnetwork.Interface
+	if resourceGroup == "" {
+		pager := c.interfaces.NewListAllPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("discover-azure: %w", err)
+			}
+			nics = append(nics, page.Value...)
+		}
+	} else {
+		pager := c.interfaces.NewListPager(resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("discover-azure: %w", err)
+			}
+			nics = append(nics, page.Value...)
+		}
+	}
+	if len(nics) == 0 {
+		return nil, fmt.Errorf("discover-azure: no interfaces")
+	}
+
+	// Collect any address with the matching tag
+	var addrs []string
+	for _, v := range nics {
+		var id string
+		if v.ID != nil {
+			id = *v.ID
+		} else {
+			id = "unknown_interface_id"
+		}
+		if !hasTag(v.Tags, tagName, tagValue, "Interface "+id, l) {
+			continue
+		}
+		iAddrs, err := c.interfaceAddrs(ctx, v, addrType, l)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, iAddrs...)
+	}
+	l.Printf("[DEBUG] discover-azure: Found ip addresses: %v", addrs)
+	return addrs, nil
+}
+
+func fetchAddrsWithVmTags(tagName string, tagValue string, resourceGroup string, addrType string, c clients, l *log.Logger) ([]string, error) {
+	// Get all virtual machines across resource groups unless restricted
+	// to a resource group, and the addresses of their network interfaces
+	ctx := context.Background()
+	var vms []*armcompute.VirtualMachine
+	if resourceGroup == "" {
+		pager := c.vms.NewListAllPager(nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("discover-azure: %w", err)
+			}
+			vms = append(vms, page.Value...)
+		}
+	} else {
+		pager := c.vms.NewListPager(resourceGroup, nil)
+		for pager.More() {
+			page, err := pager.NextPage(ctx)
+			if err != nil {
+				return nil, fmt.Errorf("discover-azure: %w", err)
+			}
+			vms = append(vms, page.Value...)
+		}
+	}
+	if len(vms) == 0 {
+		return nil, fmt.Errorf("discover-azure: no virtual machines")
+	}
+
+	var addrs []string
+	for _, vm := range vms {
+		var id string
+		if vm.ID != nil {
+			id = *vm.ID
+		} else {
+			id = "unknown_vm_id"
+		}
+		if !hasTag(vm.Tags, tagName, tagValue, "Virtual machine "+id, l) {
+			continue
+		}
+		vmAddrs, err := c.vmAddrs(ctx, vm, addrType, l)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, vmAddrs...)
+	}
+	l.Printf("[DEBUG] discover-azure: Found ip addresses: %v", addrs)
+	return addrs, nil
+}
 
+// hasTag returns whether the tags of the resource have the tag tagName
+// with value tagValue.
+func hasTag(tags map[string]*string, tagName string, tagValue string, resource string, l *log.Logger) bool {
+	if tags == nil {
+		l.Printf("[DEBUG] discover-azure: %s has no tags", resource)
+		return false
+	}
+	tv := tags[tagName] // *string
+	if tv == nil {
+		l.Printf("[DEBUG] discover-azure: %s did not have tag: %s", resource, tagName)
+		return false
+	}
+	if *tv != tagValue {
+		l.Printf("[DEBUG] discover-azure: %s tag value was: %s which did not match: %s", resource, *tv, tagValue)
+		return false
+	}
+	return true
+}
+
+func fetchAddrsWithVmScaleSet(resourceGroup string, vmScaleSet string, mode string, addrType string, c clients, l *log.Logger) ([]string, error) {
 	ctx := context.Background()
-	pager := vmnet.NewListAllPager(nil)
+
+	// The network interfaces of Flexible scale sets are not listed as
+	// scale set network interfaces, they belong to the virtual machines.
+	switch strings.ToLower(mode) {
+	case "":
+		ss, err := c.scaleSets.Get(ctx, resourceGroup, vmScaleSet, nil)
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure: %w", err)
+		}
+		if ss.Properties != nil && ss.Properties.OrchestrationMode != nil && *ss.Properties.OrchestrationMode == armcompute.OrchestrationModeFlexible {
+			l.Printf("[DEBUG] discover-azure: vm scale set %s uses Flexible orchestration mode", vmScaleSet)
+			return fetchAddrsWithFlexibleVmScaleSet(ctx, resourceGroup, vmScaleSet, addrType, c, l)
+		}
+		l.Printf("[DEBUG] discover-azure: vm scale set %s uses Uniform orchestration mode", vmScaleSet)
+	case "uniform":
+	case "flexible":
+		return fetchAddrsWithFlexibleVmScaleSet(ctx, resourceGroup, vmScaleSet, addrType, c, l)
+	default:
+		return nil, fmt.Errorf("discover-azure: invalid orchestration_mode %q", mode)
+	}
+
+	// Get all network interfaces for a specific virtual machine scale set
+	pager := c.interfaces.NewListVirtualMachineScaleSetNetworkInterfacesPager(resourceGroup, vmScaleSet, nil)
 	var addrs []string
-	for pager.More() {
 
+	for pager.More() {
 		page, err := pager.NextPage(ctx)
 		if err != nil {
 			return nil, fmt.Errorf("discover-azure: %w", err)
@@ -218,51 +541,25 @@ func fetchAddrsWithTags(tagName string, tagValue string, vmnet armnetwork.Interf
 		if len(page.Value) == 0 {
 			return nil, fmt.Errorf("discover-azure: no interfaces")
 		}
-		// Collect any PrivateIPAddress with the matching tag on each page of results
+		// Collect all of the addresses we can
 		for _, v := range page.Value {
-			var id string
-			if v.ID != nil {
-				id = *v.ID
-			} else {
-				id = "unknown_interface_id"
-			}
-			if v.Tags == nil {
-				l.Printf("[DEBUG] discover-azure: Interface %s has no tags", id)
-				continue
-			}
-			tv := v.Tags[tagName] // *string
-			if tv == nil {
-				l.Printf("[DEBUG] discover-azure: Interface %s did not have tag: %s", id, tagName)
-				continue
-			}
-			if *tv != tagValue {
-				l.Printf("[DEBUG] discover-azure: Interface %s tag value was: %s which did not match: %s", id, *tv, tagValue)
-				continue
-			}
-			if v.Properties == nil {
-				l.Printf("[DEBUG] discover-azure: Interface %s had no properties", id)
-				continue
-			}
-			for _, x := range v.Properties.IPConfigurations {
-				if x.Properties.PrivateIPAddress == nil {
-					l.Printf("[DEBUG] discover-azure: Interface %s had no private ip", id)
-					continue
-				}
-				iAddr := *x.Properties.PrivateIPAddress
-				l.Printf("[DEBUG] discover-azure: Interface %s has private ip: %s", id, iAddr)
-				addrs = append(addrs, iAddr)
+			iAddrs, err := c.interfaceAddrs(ctx, v, addrType, l)
+			if err != nil {
+				return nil, err
 			}
+			addrs = append(addrs, iAddrs...)
 		}
 		l.Printf("[DEBUG] discover-azure: Found ip addresses: %v", addrs)
 	}
-
 	return addrs, nil
 }
 
-func fetchAddrsWithVmScaleSet(resourceGroup string, vmScaleSet string, vmnet armnetwork.InterfacesClient, l *log.Logger) ([]string, error) {
-	// Get all network interfaces for a specific virtual machine scale set
-	ctx := context.Background()
-	pager := vmnet.NewListVirtualMachineScaleSetNetworkInterfacesPager(resourceGroup, vmScaleSet, nil)
+func fetchAddrsWithFlexibleVmScaleSet(ctx context.Context, resourceGroup string, vmScaleSet string, addrType string, c clients, l *log.Logger) ([]string, error) {
+	// Get all virtual machines of the scale set and their network interfaces
+	scaleSetID := fmt.Sprintf("/subscriptions/%s/resourceGroups/%s/providers/Microsoft.Compute/virtualMachineScaleSets/%s", c.subscriptionID, resourceGroup, vmScaleSet)
+	pager := c.vms.NewListPager(resourceGroup, &armcompute.VirtualMachinesClientListOptions{
+		Filter: to.Ptr(fmt.Sprintf("'virtualMachineScaleSet/id' eq '%s'", scaleSetID)),
+	})
 	var addrs []string
 
 	for pager.More() {
@@ -271,32 +568,127 @@ func fetchAddrsWithVmScaleSet(resourceGroup string, vmScaleSet string, vmnet arm
 			return nil, fmt.Errorf("discover-azure: %w", err)
 		}
 		if len(page.Value) == 0 {
-			return nil, fmt.Errorf("discover-azure: no interfaces")
+			return nil, fmt.Errorf("discover-azure: no virtual machines")
 		}
-		// Collect all of PrivateIPAddresses we can
-		for _, v := range page.Value {
-			var id string
-			if v.ID != nil {
-				id = *v.ID
-			} else {
-				id = "unknown_interface_id"
+		for _, vm := range page.Value {
+			vmAddrs, err := c.vmAddrs(ctx, vm, addrType, l)
+			if err != nil {
+				return nil, err
 			}
-			if v.Properties == nil {
-				l.Printf("[DEBUG] discover-azure: Interface %s had properties", id)
+			addrs = append(addrs, vmAddrs...)
+		}
+		l.Printf("[DEBUG] discover-azure: Found ip addresses: %v", addrs)
+	}
+	return addrs, nil
+}
+
+// vmAddrs returns the addresses of the network interfaces of the
+// virtual machine vm.
+func (c clients) vmAddrs(ctx context.Context, vm *armcompute.VirtualMachine, addrType string, l *log.Logger) ([]string, error) {
+	if vm.Properties == nil || vm.Properties.NetworkProfile == nil {
+		if vm.ID != nil {
+			l.Printf("[DEBUG] discover-azure: Virtual machine %s has no network profile", *vm.ID)
+		}
+		return nil, nil
+	}
+
+	var addrs []string
+	for _, ref := range vm.Properties.NetworkProfile.NetworkInterfaces {
+		if ref.ID == nil {
+			continue
+		}
+		id, err := arm.ParseResourceID(*ref.ID)
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure: %w", err)
+		}
+		nic, err := c.interfaces.Get(ctx, id.ResourceGroupName, id.Name, nil)
+		if err != nil {
+			return nil, fmt.Errorf("discover-azure: %w", err)
+		}
+		iAddrs, err := c.interfaceAddrs(ctx, &nic.Interface, addrType, l)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, iAddrs...)
+	}
+	return addrs, nil
+}
+
+// interfaceAddrs returns the addresses of type addrType of all IP
+// configurations of the network interface v. Public addresses are looked
+// up from the public IP address resources the IP configurations reference.
+func (c clients) interfaceAddrs(ctx context.Context, v *armnetwork.Interface, addrType string, l *log.Logger) ([]string, error) {
+	var id string
+	if v.ID != nil {
+		id = *v.ID
+	} else {
+		id = "unknown_interface_id"
+	}
+	if v.Properties == nil {
+		l.Printf("[DEBUG] discover-azure: Interface %s had no properties", id)
+		return nil, nil
+	}
+
+	ipv6 := addrType == "private_v6" || addrType == "public_v6"
+	public := addrType == "public_v4" || addrType == "public_v6"
+
+	var addrs []string
+	for _, x := range v.Properties.IPConfigurations {
+		if x.Properties == nil {
+			continue
+		}
+		var iAddr string
+		if public {
+			if x.Properties.PublicIPAddress == nil || x.Properties.PublicIPAddress.ID == nil {
+				l.Printf("[DEBUG] discover-azure: Interface %s had no public ip", id)
 				continue
 			}
-
-			for _, x := range v.Properties.IPConfigurations {
-				if x.Properties.PrivateIPAddress == nil {
-					l.Printf("[DEBUG] discover-azure: Interface %s had no private ip", id)
-					continue
-				}
-				iAddr := *x.Properties.PrivateIPAddress
-				l.Printf("[DEBUG] discover-azure: Interface %s has private ip: %s", id, iAddr)
-				addrs = append(addrs, iAddr)
+			pip, err := c.publicIP(ctx, *x.Properties.PublicIPAddress.ID)
+			if err != nil {
+				return nil, fmt.Errorf("discover-azure: %w", err)
+			}
+			if pip.Properties == nil || pip.Properties.IPAddress == nil {
+				l.Printf("[DEBUG] discover-azure: Public ip %s of interface %s has no address", *x.Properties.PublicIPAddress.ID, id)
+				continue
+			}
+			iAddr = *pip.Properties.IPAddress
+		} else {
+			if x.Properties.PrivateIPAddress == nil {
+				l.Printf("[DEBUG] discover-azure: Interface %s had no private ip", id)
+				continue
 			}
+			iAddr = *x.Properties.PrivateIPAddress
 		}
-		l.Printf("[DEBUG] discover-azure: Found ip addresses: %v", addrs)
+		if ip := net.ParseIP(iAddr); ip == nil || (ip.To4() == nil) != ipv6 {
+			l.Printf("[DEBUG] discover-azure: Interface %s ip %s is not a %s address", id, iAddr, addrType)
+			continue
+		}
+		l.Printf("[DEBUG] discover-azure: Interface %s has %s ip: %s", id, addrType, iAddr)
+		addrs = append(addrs, iAddr)
 	}
 	return addrs, nil
 }
+
+// publicIP returns the public IP address resource with the id ref.
+func (c clients) publicIP(ctx context.Context, ref string) (armnetwork.PublicIPAddress, error) {
+	id, err := arm.ParseResourceID(ref)
+	if err != nil {
+		return armnetwork.PublicIPAddress{}, err
+	}
+
+	// The public IP addresses of the virtual machines of Uniform scale
+	// sets are nested in their IP configurations.
+	if strings.EqualFold(id.ResourceType.Namespace, "Microsoft.Compute") {
+		ipConfig := id.Parent
+		if ipConfig == nil || ipConfig.Parent == nil || ipConfig.Parent.Parent == nil || ipConfig.Parent.Parent.Parent == nil {
+			return armnetwork.PublicIPAddress{}, fmt.Errorf("unexpected public ip %s", ref)
+		}
+		nic := ipConfig.Parent
+		vm := nic.Parent
+		resp, err := c.publicIPs.GetVirtualMachineScaleSetPublicIPAddress(ctx, id.ResourceGroupName, vm.Parent.Name, vm.Name, nic.Name, ipConfig.Name, id.Name, nil)
+		return resp.PublicIPAddress, err
+	}
+
+	resp, err := c.publicIPs.Get(ctx, id.ResourceGroupName, id.Name, nil)
+	return resp.PublicIPAddress, err
+}
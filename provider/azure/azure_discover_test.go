@@ -4,10 +4,30 @@
 package azure_test
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/arm"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/cloud"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
 	discover "github.com/hashicorp/go-discover"
 	"github.com/hashicorp/go-discover/provider/azure"
 )
@@ -26,10 +46,6 @@ func TestTagAddrsWithEnv(t *testing.T) {
 		t.Skip("Azure Enviornmental credentials missing")
 	}
 
-	if args["environment"] == "" {
-		t.Log("Environments other than Public not supported at the moment")
-	}
-
 	p := &azure.Provider{}
 	l := log.New(os.Stderr, "", log.LstdFlags)
 	addrs, err := p.Addrs(args, l)
@@ -56,10 +72,6 @@ func TestTagAddrs(t *testing.T) {
 		t.Skip("Azure credentials missing")
 	}
 
-	if args["environment"] == "" {
-		t.Log("Environments other than Public not supported at the moment")
-	}
-
 	p := &azure.Provider{}
 	l := log.New(os.Stderr, "", log.LstdFlags)
 	addrs, err := p.Addrs(args, l)
@@ -87,10 +99,6 @@ func TestVmScaleSetAddrs(t *testing.T) {
 		t.Skip("Azure credentials missing")
 	}
 
-	if args["environment"] == "" {
-		t.Log("Environments other than Public not supported at the moment")
-	}
-
 	p := &azure.Provider{}
 	l := log.New(os.Stderr, "", log.LstdFlags)
 	addrs, err := p.Addrs(args, l)
@@ -101,3 +109,618 @@ func TestVmScaleSetAddrs(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+// testNIC is a network interface served by the fake Azure Resource
+// Manager.
+type testNIC struct {
+	ResourceGroup string
+	Name          string
+	Tags          map[string]string
+	PrivateIPs    []string // of the IP configurations
+	PublicIPs     []string // public IP address ids of the IP configurations
+}
+
+func (n testNIC) id() string {
+	return "/subscriptions/sub/resourceGroups/" + n.ResourceGroup + "/providers/Microsoft.Network/networkInterfaces/" + n.Name
+}
+
+func (n testNIC) resource() map[string]interface{} {
+	var configs []interface{}
+	for i, ip := range n.PrivateIPs {
+		version := "IPv4"
+		if strings.Contains(ip, ":") {
+			version = "IPv6"
+		}
+		props := map[string]interface{}{
+			"primary":                 i == 0,
+			"privateIPAddress":        ip,
+			"privateIPAddressVersion": version,
+		}
+		if i < len(n.PublicIPs) && n.PublicIPs[i] != "" {
+			props["publicIPAddress"] = map[string]interface{}{"id": n.PublicIPs[i]}
+		}
+		configs = append(configs, map[string]interface{}{
+			"id":         fmt.Sprintf("%s/ipConfigurations/ipconfig%d", n.id(), i+1),
+			"name":       fmt.Sprintf("ipconfig%d", i+1),
+			"properties": props,
+		})
+	}
+	return map[string]interface{}{
+		"id":         n.id(),
+		"name":       n.Name,
+		"tags":       n.Tags,
+		"properties": map[string]interface{}{"ipConfigurations": configs},
+	}
+}
+
+// testVM is a virtual machine, which is in a Flexible scale set if
+// ScaleSet is set.
+type testVM struct {
+	ResourceGroup string
+	Name          string
+	Tags          map[string]string
+	ScaleSet      string
+	NICs          []string // network interfaces as "resource group/name"
+}
+
+func (vm testVM) resource() map[string]interface{} {
+	var nics []interface{}
+	for _, nic := range vm.NICs {
+		rg, name, _ := strings.Cut(nic, "/")
+		nics = append(nics, map[string]interface{}{"id": testNIC{ResourceGroup: rg, Name: name}.id()})
+	}
+	return map[string]interface{}{
+		"id":   "/subscriptions/sub/resourceGroups/" + vm.ResourceGroup + "/providers/Microsoft.Compute/virtualMachines/" + vm.Name,
+		"name": vm.Name,
+		"tags": vm.Tags,
+		"properties": map[string]interface{}{
+			"networkProfile": map[string]interface{}{"networkInterfaces": nics},
+		},
+	}
+}
+
+// testScaleSet is a virtual machine scale set. The network interfaces of
+// Uniform scale sets belong to the scale set.
+type testScaleSet struct {
+	ResourceGroup string
+	Name          string
+	Mode          string
+	NICs          []testNIC
+}
+
+func (s testScaleSet) id() string {
+	return "/subscriptions/sub/resourceGroups/" + s.ResourceGroup + "/providers/Microsoft.Compute/virtualMachineScaleSets/" + s.Name
+}
+
+// fakeARM is a stand-in for the Azure Resource Manager APIs of the
+// subscription "sub" which serves NICs, VMs, ScaleSets and PublicIPs.
+type fakeARM struct {
+	NICs      []testNIC
+	VMs       []testVM
+	ScaleSets []testScaleSet
+	PublicIPs map[string]string // public IP address id to address
+}
+
+func (f *fakeARM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "Bearer token" {
+		armError(w, http.StatusUnauthorized, "InvalidAuthenticationToken", "invalid token")
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	// .../publicIPAddresses/name, the paths of public IP addresses of scale
+	// sets are lower case.
+	case strings.Contains(strings.ToLower(r.URL.Path), "/publicipaddresses/"):
+		for id, ip := range f.PublicIPs {
+			if strings.EqualFold(id, r.URL.Path) {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"id":         id,
+					"properties": map[string]interface{}{"ipAddress": ip},
+				})
+				return
+			}
+		}
+
+	// /subscriptions/sub[/resourceGroups/rg]/providers/Microsoft.Network/networkInterfaces
+	case parts[len(parts)-1] == "networkInterfaces" && (len(parts) == 5 || len(parts) == 7):
+		list := []interface{}{}
+		for _, n := range f.NICs {
+			if len(parts) == 5 || n.ResourceGroup == parts[3] {
+				list = append(list, n.resource())
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": list})
+		return
+
+	// /subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/networkInterfaces/name
+	case len(parts) == 8 && parts[6] == "networkInterfaces":
+		for _, n := range f.NICs {
+			if n.ResourceGroup == parts[3] && n.Name == parts[7] {
+				json.NewEncoder(w).Encode(n.resource())
+				return
+			}
+		}
+
+	// /subscriptions/sub[/resourceGroups/rg]/providers/Microsoft.Compute/virtualMachines
+	case parts[len(parts)-1] == "virtualMachines" && (len(parts) == 5 || len(parts) == 7):
+		filter := r.URL.Query().Get("$filter")
+		list := []interface{}{}
+		for _, vm := range f.VMs {
+			if len(parts) == 7 && vm.ResourceGroup != parts[3] {
+				continue
+			}
+			scaleSet := testScaleSet{ResourceGroup: vm.ResourceGroup, Name: vm.ScaleSet}
+			if filter != "" && (vm.ScaleSet == "" || filter != "'virtualMachineScaleSet/id' eq '"+scaleSet.id()+"'") {
+				continue
+			}
+			list = append(list, vm.resource())
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"value": list})
+		return
+
+	// /subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/name[/networkInterfaces]
+	case len(parts) >= 8 && parts[6] == "virtualMachineScaleSets":
+		for _, s := range f.ScaleSets {
+			if s.ResourceGroup != parts[3] || s.Name != parts[7] {
+				continue
+			}
+			if len(parts) == 8 {
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"id":         s.id(),
+					"name":       s.Name,
+					"properties": map[string]interface{}{"orchestrationMode": s.Mode},
+				})
+				return
+			}
+			// Flexible scale sets have no scale set network interfaces.
+			list := []interface{}{}
+			for _, n := range s.NICs {
+				list = append(list, n.resource())
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"value": list})
+			return
+		}
+	}
+	armError(w, http.StatusNotFound, "ResourceNotFound", "The Resource '"+r.URL.Path+"' was not found.")
+}
+
+func armError(w http.ResponseWriter, status int, code, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"code": code, "message": msg}})
+}
+
+// fakeCredential returns the token the fake Azure Resource Manager
+// accepts.
+type fakeCredential struct{}
+
+func (fakeCredential) GetToken(ctx context.Context, opts policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	return azcore.AccessToken{Token: "token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+// armOptions returns the client options to use srv as Azure Resource
+// Manager.
+func armOptions(srv *httptest.Server) *arm.ClientOptions {
+	return &arm.ClientOptions{
+		ClientOptions: policy.ClientOptions{
+			Cloud: cloud.Configuration{
+				Services: map[cloud.ServiceName]cloud.ServiceConfiguration{
+					cloud.ResourceManager: {Endpoint: srv.URL, Audience: "https://management.core.windows.net/"},
+				},
+			},
+			Transport: srv.Client(),
+			Retry:     policy.RetryOptions{MaxRetries: -1},
+		},
+	}
+}
+
+const (
+	testPublicIP       = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Network/publicIPAddresses/"
+	testScaleSetIPConf = "/subscriptions/sub/resourceGroups/rg/providers/Microsoft.Compute/virtualMachineScaleSets/uniform/virtualMachines/0/networkInterfaces/uniform-nic/ipConfigurations/ipconfig1"
+)
+
+var testARM = &fakeARM{
+	NICs: []testNIC{
+		{
+			ResourceGroup: "rg", Name: "consul-1-nic", Tags: map[string]string{"consul": "server"},
+			PrivateIPs: []string{"10.0.0.4", "10.0.0.5", "fd00::4"},
+			PublicIPs:  []string{testPublicIP + "consul-1-v4", "", testPublicIP + "consul-1-v6"},
+		},
+		{ResourceGroup: "rg", Name: "consul-2-nic", Tags: map[string]string{"consul": "client"}, PrivateIPs: []string{"10.0.0.6"}},
+		{ResourceGroup: "other", Name: "consul-3-nic", Tags: map[string]string{"consul": "server"}, PrivateIPs: []string{"10.9.0.4"}},
+		{ResourceGroup: "rg", Name: "flex-1-nic", PrivateIPs: []string{"10.1.0.4"}},
+		{ResourceGroup: "rg", Name: "flex-2-nic", PrivateIPs: []string{"10.1.0.5"}},
+		{ResourceGroup: "net", Name: "flex-2-nic2", PrivateIPs: []string{"10.2.0.5"}},
+		{ResourceGroup: "rg", Name: "vm-nic", PrivateIPs: []string{"10.3.0.4"}, PublicIPs: []string{testPublicIP + "vm"}},
+		{ResourceGroup: "other", Name: "other-vm-nic", PrivateIPs: []string{"10.9.0.5"}},
+	},
+	VMs: []testVM{
+		{ResourceGroup: "rg", Name: "flex-1", ScaleSet: "flex", NICs: []string{"rg/flex-1-nic"}},
+		{ResourceGroup: "rg", Name: "flex-2", ScaleSet: "flex", NICs: []string{"rg/flex-2-nic", "net/flex-2-nic2"}},
+		{ResourceGroup: "rg", Name: "vm", Tags: map[string]string{"consul": "server"}, NICs: []string{"rg/vm-nic"}},
+		{ResourceGroup: "other", Name: "other-vm", Tags: map[string]string{"consul": "server"}, NICs: []string{"other/other-vm-nic"}},
+	},
+	ScaleSets: []testScaleSet{
+		{
+			ResourceGroup: "rg", Name: "uniform", Mode: "Uniform",
+			NICs: []testNIC{{
+				ResourceGroup: "rg", Name: "uniform-nic",
+				PrivateIPs: []string{"10.4.0.4"},
+				PublicIPs:  []string{testScaleSetIPConf + "/publicIPAddresses/pip"},
+			}},
+		},
+		{ResourceGroup: "rg", Name: "flex", Mode: "Flexible"},
+		{ResourceGroup: "rg", Name: "empty", Mode: "Flexible"},
+	},
+	PublicIPs: map[string]string{
+		testPublicIP + "consul-1-v4":                  "203.0.113.4",
+		testPublicIP + "consul-1-v6":                  "2001:db8::4",
+		testPublicIP + "vm":                           "203.0.113.5",
+		testScaleSetIPConf + "/publicIPAddresses/pip": "203.0.113.6",
+	},
+}
+
+func TestClientAddrs(t *testing.T) {
+	srv := httptest.NewTLSServer(testARM)
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"tags",
+			map[string]string{"tag_name": "consul", "tag_value": "server"},
+			[]string{"10.0.0.4", "10.0.0.5", "10.9.0.4"},
+			"",
+		},
+		{
+			"tags in resource group",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "resource_group": "rg"},
+			[]string{"10.0.0.4", "10.0.0.5"},
+			"",
+		},
+		{
+			"private IPv6",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "addr_type": "private_v6"},
+			[]string{"fd00::4"},
+			"",
+		},
+		{
+			"public IPv4",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "addr_type": "public_v4"},
+			[]string{"203.0.113.4"},
+			"",
+		},
+		{
+			"public IPv6",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "addr_type": "public_v6"},
+			[]string{"2001:db8::4"},
+			"",
+		},
+		{
+			"vm tags",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "tag_scope": "vm"},
+			[]string{"10.3.0.4", "10.9.0.5"},
+			"",
+		},
+		{
+			"vm tags in resource group",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "tag_scope": "vm", "resource_group": "rg", "addr_type": "public_v4"},
+			[]string{"203.0.113.5"},
+			"",
+		},
+		{
+			"invalid tag scope",
+			map[string]string{"tag_name": "consul", "tag_value": "server", "tag_scope": "subnet"},
+			nil,
+			`invalid tag_scope "subnet"`,
+		},
+		{
+			"uniform scale set",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "uniform"},
+			[]string{"10.4.0.4"},
+			"",
+		},
+		{
+			"public IPv4 of uniform scale set",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "uniform", "addr_type": "public_v4"},
+			[]string{"203.0.113.6"},
+			"",
+		},
+		{
+			"flexible scale set",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "flex"},
+			[]string{"10.1.0.4", "10.1.0.5", "10.2.0.5"},
+			"",
+		},
+		{
+			"flexible orchestration mode",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "flex", "orchestration_mode": "Flexible"},
+			[]string{"10.1.0.4", "10.1.0.5", "10.2.0.5"},
+			"",
+		},
+		{
+			"empty flexible scale set",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "empty"},
+			nil,
+			"no virtual machines",
+		},
+		{
+			"unknown scale set",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "missing"},
+			nil,
+			"ResourceNotFound",
+		},
+		{
+			"invalid orchestration mode",
+			map[string]string{"resource_group": "rg", "vm_scale_set": "flex", "orchestration_mode": "manual"},
+			nil,
+			`invalid orchestration_mode "manual"`,
+		},
+	}
+
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "azure", "subscription_id": "sub"}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := azure.ClientAddrs(args, fakeCredential{}, armOptions(srv), l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+// fakeEntraID is a stand-in for the Microsoft Entra ID token endpoints of
+// the tenant "tenant" and the App Service managed identity endpoint,
+// which issue the token for the client "client" and the Azure Resource
+// Manager ARM with the audience audience.
+type fakeEntraID struct {
+	ARM      http.Handler
+	Audience string
+}
+
+const testFederatedToken = "federated-token"
+
+func (f *fakeEntraID) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	base := "https://" + r.Host
+	switch r.URL.Path {
+	case "/tenant/v2.0/.well-known/openid-configuration":
+		json.NewEncoder(w).Encode(map[string]string{
+			"token_endpoint":         base + "/tenant/oauth2/v2.0/token",
+			"authorization_endpoint": base + "/tenant/oauth2/v2.0/authorize",
+			"issuer":                 base + "/tenant/v2.0",
+		})
+
+	case "/tenant/oauth2/v2.0/token":
+		r.ParseForm()
+		var ok bool
+		switch {
+		case r.PostForm.Get("client_secret") != "":
+			ok = r.PostForm.Get("client_secret") == "secret"
+		case r.PostForm.Get("client_assertion") == testFederatedToken:
+			ok = true
+		default:
+			// A JWT signed with the client certificate
+			ok = r.PostForm.Get("client_assertion_type") == "urn:ietf:params:oauth:client-assertion-type:jwt-bearer" &&
+				strings.Count(r.PostForm.Get("client_assertion"), ".") == 2
+		}
+		// The scope is followed by the OpenID Connect scopes
+		scope, _, _ := strings.Cut(r.PostForm.Get("scope"), " ")
+		if !ok || r.PostForm.Get("client_id") != "client" || scope != f.Audience+"/.default" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client", "error_description": "invalid client credentials "})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"token_type": "Bearer", "expires_in": 3600, "access_token": "token"})
+
+	case "/msi/token":
+		q := r.URL.Query()
+		if r.Header.Get("X-Identity-Header") != "header" || q.Get("client_id") != "identity" || q.Get("resource") != f.Audience {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request", "error_description": "unknown identity"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"token_type":   "Bearer",
+			"access_token": "token",
+			"expires_on":   strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10),
+			"resource":     f.Audience,
+		})
+
+	default:
+		f.ARM.ServeHTTP(w, r)
+	}
+}
+
+// writeTestCertificate writes a self-signed certificate and its private
+// key to a PEM file and returns its path.
+func writeTestCertificate(t *testing.T) string {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	data = append(data, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})...)
+
+	path := filepath.Join(t.TempDir(), "client.pem")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestClientOptionsAddrs(t *testing.T) {
+	for _, env := range []string{"ARM_TENANT_ID", "ARM_CLIENT_ID", "ARM_CLIENT_SECRET", "ARM_CLIENT_CERTIFICATE_PATH", "ARM_ENVIRONMENT", "AZURE_FEDERATED_TOKEN_FILE"} {
+		t.Setenv(env, "")
+	}
+	fake := &fakeEntraID{ARM: testARM}
+	srv := httptest.NewTLSServer(fake)
+	defer srv.Close()
+	fake.Audience = srv.URL
+	t.Setenv("IDENTITY_ENDPOINT", srv.URL+"/msi/token")
+	t.Setenv("IDENTITY_HEADER", "header")
+
+	certPath := writeTestCertificate(t)
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte(testFederatedToken), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		Name string
+		Args map[string]string
+		Err  string
+	}{
+		{
+			"client secret",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "secret_access_key": "secret"},
+			"",
+		},
+		{
+			"client certificate",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "client_certificate_path": certPath},
+			"",
+		},
+		{
+			"workload identity",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "federated_token_file": tokenFile},
+			"",
+		},
+		{
+			"managed identity",
+			map[string]string{"msi_client_id": "identity"},
+			"",
+		},
+		{
+			"environment with custom endpoints",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "secret_access_key": "secret", "environment": "AzureUSGovernment"},
+			"",
+		},
+		{
+			"invalid client secret",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "secret_access_key": "other"},
+			"invalid_client",
+		},
+		{
+			"unknown managed identity",
+			map[string]string{"msi_client_id": "other"},
+			"unknown identity",
+		},
+		{
+			"missing client certificate",
+			map[string]string{"tenant_id": "tenant", "client_id": "client", "client_certificate_path": filepath.Join(t.TempDir(), "missing.pem")},
+			"discover-azure (ClientCertificate)",
+		},
+		{
+			"invalid environment",
+			map[string]string{"environment": "AzureGermanCloud"},
+			`invalid environment "AzureGermanCloud"`,
+		},
+	}
+
+	opts := policy.ClientOptions{
+		Transport: srv.Client(),
+		Retry:     policy.RetryOptions{MaxRetries: -1},
+	}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider":                  "azure",
+				"subscription_id":           "sub",
+				"tag_name":                  "consul",
+				"tag_value":                 "client",
+				"resource_manager_endpoint": srv.URL,
+				"authority_host":            srv.URL,
+			}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := azure.ClientOptionsAddrs(args, opts, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if want := []string{"10.0.0.6"}; !reflect.DeepEqual(addrs, want) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestCloudConfig(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Env      string
+		Expected cloud.Configuration
+		Err      string
+	}{
+		{"default", nil, "", cloud.AzurePublic, ""},
+		{"public", map[string]string{"environment": "AzurePublicCloud"}, "", cloud.AzurePublic, ""},
+		{"public alias", map[string]string{"environment": "public"}, "", cloud.AzurePublic, ""},
+		{"china", map[string]string{"environment": "AzureChinaCloud"}, "", cloud.AzureChina, ""},
+		{"china alias", map[string]string{"environment": "china"}, "", cloud.AzureChina, ""},
+		{"us government", map[string]string{"environment": "AzureUSGovernment"}, "", cloud.AzureGovernment, ""},
+		{"us government cloud", map[string]string{"environment": "AzureUSGovernmentCloud"}, "", cloud.AzureGovernment, ""},
+		{"us government alias", map[string]string{"environment": "usgovernment"}, "", cloud.AzureGovernment, ""},
+		{"alias from env", nil, "usgovernment", cloud.AzureGovernment, ""},
+		{"args before env", map[string]string{"environment": "china"}, "usgovernment", cloud.AzureChina, ""},
+		{"unknown", map[string]string{"environment": "german"}, "", cloud.Configuration{}, `invalid environment "german"`},
+		{"unknown from env", nil, "AzureGermanCloud", cloud.Configuration{}, `invalid environment "AzureGermanCloud"`},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			t.Setenv("ARM_ENVIRONMENT", tt.Env)
+			conf, err := azure.CloudConfig(tt.Args)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(conf, tt.Expected) {
+				t.Fatalf("got %#v, want %#v", conf, tt.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,7 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package azure
+
+// CloudConfig exports cloudConfig for the tests.
+var CloudConfig = cloudConfig
@@ -0,0 +1,220 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package consul provides node discovery from a Consul catalog.
+//
+// The provider talks to the Consul HTTP API directly rather than through
+// github.com/hashicorp/consul/api since Consul itself depends on this
+// module.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+)
+
+type Provider struct {
+	userAgent string
+}
+
+func (p *Provider) SetUserAgent(s string) {
+	p.userAgent = s
+}
+
+func (p *Provider) Help() string {
+	return `Consul:
+
+    provider:        "consul"
+    service:         The name of the service to look up.
+    address:         Address of the Consul agent or server. Default "127.0.0.1:8500".
+                     Use an "https://" prefix to connect with TLS.
+    tags:            Comma separated list of tags the service instances must all have.
+    datacenter:      The datacenter to query. Defaults to the datacenter of the agent.
+    namespace:       The namespace of the service (Consul Enterprise).
+    partition:       The admin partition of the service (Consul Enterprise).
+    passing_only:    "true" to only return instances whose health checks are passing.
+                     Default "false".
+    token:           The ACL token to use.
+    ca_file:         PEM encoded CA bundle used to verify the Consul server certificate.
+    cert_file:       PEM encoded client certificate.
+    key_file:        PEM encoded key of the client certificate.
+    tls_server_name: The server name used to verify the Consul server certificate.
+    insecure_ssl:    Whether or not to skip TLS certificate validation.
+    timeout:         Timeout for the request. Default "10s".
+
+    The service address and port of every instance is returned. If an
+    instance has no service address the address of its node is used.
+
+    Variables can also be provided by environment variables:
+    export CONSUL_HTTP_ADDR for address
+    export CONSUL_HTTP_TOKEN for token
+    export CONSUL_NAMESPACE for namespace
+    export CONSUL_PARTITION for partition
+    export CONSUL_CACERT for ca_file
+    export CONSUL_CLIENT_CERT for cert_file
+    export CONSUL_CLIENT_KEY for key_file
+    export CONSUL_TLS_SERVER_NAME for tls_server_name
+
+    The ACL token requires "service:read" on the service and "node:read"
+    on the nodes it is registered on.
+`
+}
+
+// serviceEntry is the subset of an entry of the /v1/health/service
+// response used for discovery.
+type serviceEntry struct {
+	Node struct {
+		Node    string
+		Address string
+	}
+	Service struct {
+		ID      string
+		Address string
+		Port    int
+	}
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "consul" {
+		return nil, fmt.Errorf("discover-consul: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	service := args["service"]
+	if service == "" {
+		return nil, fmt.Errorf("discover-consul: service is required")
+	}
+
+	passingOnly := false
+	if v := args["passing_only"]; v != "" {
+		var err error
+		if passingOnly, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-consul: passing_only must be boolean value: %w", err)
+		}
+	}
+
+	timeout, err := httpclient.Timeout(args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-consul: %w", err)
+	}
+
+	address := argsOrEnv(args, "address", "CONSUL_HTTP_ADDR")
+	if address == "" {
+		address = "127.0.0.1:8500"
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	base, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("discover-consul: invalid address: %w", err)
+	}
+
+	// The TLS settings can also be read from the environment variables
+	// used by the Consul CLI.
+	tlsConfig, err := httpclient.TLSConfig(args, httpclient.TLSEnv{
+		CAFile:     "CONSUL_CACERT",
+		CertFile:   "CONSUL_CLIENT_CERT",
+		KeyFile:    "CONSUL_CLIENT_KEY",
+		ServerName: "CONSUL_TLS_SERVER_NAME",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discover-consul: %w", err)
+	}
+
+	q := url.Values{}
+	for _, tag := range strings.Split(args["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			q.Add("tag", tag)
+		}
+	}
+	if dc := args["datacenter"]; dc != "" {
+		q.Set("dc", dc)
+	}
+	if ns := argsOrEnv(args, "namespace", "CONSUL_NAMESPACE"); ns != "" {
+		q.Set("ns", ns)
+	}
+	if ap := argsOrEnv(args, "partition", "CONSUL_PARTITION"); ap != "" {
+		q.Set("partition", ap)
+	}
+	if passingOnly {
+		q.Set("passing", "true")
+	}
+	u := base.JoinPath("/v1/health/service", service)
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("discover-consul: %w", err)
+	}
+	if token := argsOrEnv(args, "token", "CONSUL_HTTP_TOKEN"); token != "" {
+		l.Printf("[DEBUG] discover-consul: Using ACL token")
+		req.Header.Set("X-Consul-Token", token)
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+
+	client := httpclient.New(tlsConfig)
+	defer client.CloseIdleConnections()
+
+	l.Printf("[DEBUG] discover-consul: Using address=%s service=%s %s", base.Redacted(), service, q.Encode())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discover-consul: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, fmt.Errorf("discover-consul: %w", err)
+	}
+
+	var entries []serviceEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, fmt.Errorf("discover-consul: invalid response: %w", err)
+	}
+	l.Printf("[DEBUG] discover-consul: Found %d instances of %s", len(entries), service)
+
+	var addrs []string
+	for _, e := range entries {
+		addr := e.Service.Address
+		if addr == "" {
+			addr = e.Node.Address
+		}
+		if addr == "" {
+			l.Printf("[DEBUG] discover-consul: Instance %s on node %s has no address", e.Service.ID, e.Node.Node)
+			continue
+		}
+		if e.Service.Port > 0 {
+			addr = net.JoinHostPort(addr, strconv.Itoa(e.Service.Port))
+		}
+		l.Printf("[INFO] discover-consul: Instance %s on node %s has address %s", e.Service.ID, e.Node.Node, addr)
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// argsOrEnv allows you to pick an environmental variable for a setting if the arg is not set
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
@@ -0,0 +1,244 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package consul_test
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/consul"
+)
+
+var _ discover.Provider = (*consul.Provider)(nil)
+var _ discover.ProviderWithUserAgent = (*consul.Provider)(nil)
+
+type instance struct {
+	Datacenter  string
+	Namespace   string
+	Partition   string
+	Node        string
+	NodeAddress string
+	Address     string
+	Port        int
+	Tags        []string
+	Passing     bool
+}
+
+// fakeConsul serves /v1/health/service/ from instances with the filter
+// semantics of the Consul HTTP API. Requests without the token fail.
+func fakeConsul(t *testing.T, token string, instances []instance) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Consul-Token") != token {
+			http.Error(w, "ACL not found", http.StatusForbidden)
+			return
+		}
+		name, ok := strings.CutPrefix(r.URL.Path, "/v1/health/service/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		dc, ns, ap := q.Get("dc"), q.Get("ns"), q.Get("partition")
+		if dc == "" {
+			dc = "dc1"
+		}
+		if ns == "" {
+			ns = "default"
+		}
+		if ap == "" {
+			ap = "default"
+		}
+
+		entries := []map[string]interface{}{}
+	Instances:
+		for _, inst := range instances {
+			if name != "consul" || inst.Datacenter != dc || inst.Namespace != ns || inst.Partition != ap {
+				continue
+			}
+			if q.Has("passing") && !inst.Passing {
+				continue
+			}
+			for _, tag := range q["tag"] {
+				found := false
+				for _, t := range inst.Tags {
+					found = found || t == tag
+				}
+				if !found {
+					continue Instances
+				}
+			}
+			entries = append(entries, map[string]interface{}{
+				"Node":    map[string]interface{}{"Node": inst.Node, "Address": inst.NodeAddress},
+				"Service": map[string]interface{}{"ID": "consul", "Service": "consul", "Address": inst.Address, "Port": inst.Port, "Tags": inst.Tags},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+var catalog = []instance{
+	{"dc1", "default", "default", "node-1", "10.0.0.1", "", 8300, []string{"server", "voter"}, true},
+	{"dc1", "default", "default", "node-2", "10.0.0.2", "172.16.0.2", 8300, []string{"server"}, true},
+	{"dc1", "default", "default", "node-3", "10.0.0.3", "", 8300, []string{"server", "voter"}, false},
+	{"dc1", "default", "default", "node-4", "fd00::4", "", 8300, nil, true},
+	{"dc2", "default", "default", "node-5", "10.1.0.5", "", 8300, []string{"server"}, true},
+	{"dc1", "team-a", "default", "node-6", "10.0.0.6", "", 8300, []string{"server"}, true},
+	{"dc1", "default", "part-1", "node-7", "10.0.0.7", "", 0, []string{"server"}, true},
+}
+
+func TestAddrs(t *testing.T) {
+	srv := fakeConsul(t, "s3cr3t", catalog)
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+	}{
+		{
+			"all instances",
+			nil,
+			[]string{"10.0.0.1:8300", "172.16.0.2:8300", "10.0.0.3:8300", "[fd00::4]:8300"},
+		},
+		{
+			"tags",
+			map[string]string{"tags": "server, voter"},
+			[]string{"10.0.0.1:8300", "10.0.0.3:8300"},
+		},
+		{
+			"passing only",
+			map[string]string{"tags": "server", "passing_only": "true"},
+			[]string{"10.0.0.1:8300", "172.16.0.2:8300"},
+		},
+		{
+			"datacenter",
+			map[string]string{"datacenter": "dc2"},
+			[]string{"10.1.0.5:8300"},
+		},
+		{
+			"namespace",
+			map[string]string{"namespace": "team-a"},
+			[]string{"10.0.0.6:8300"},
+		},
+		{
+			"partition without port",
+			map[string]string{"partition": "part-1"},
+			[]string{"10.0.0.7"},
+		},
+		{
+			"no instances",
+			map[string]string{"service": "vault"},
+			nil,
+		},
+	}
+
+	p := &consul.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider": "consul",
+				"service":  "consul",
+				"address":  srv.URL,
+				"token":    "s3cr3t",
+			}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAddrsEnv(t *testing.T) {
+	srv := fakeConsul(t, "from-env", catalog)
+	t.Setenv("CONSUL_HTTP_ADDR", strings.TrimPrefix(srv.URL, "http://"))
+	t.Setenv("CONSUL_HTTP_TOKEN", "from-env")
+	t.Setenv("CONSUL_NAMESPACE", "team-a")
+
+	p := &consul.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	addrs, err := p.Addrs(discover.Config{"provider": "consul", "service": "consul"}, l)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.0.6:8300"}) {
+		t.Fatalf("bad: %#v", addrs)
+	}
+}
+
+func TestAddrsACLDenied(t *testing.T) {
+	srv := fakeConsul(t, "s3cr3t", catalog)
+
+	p := &consul.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	args := discover.Config{
+		"provider": "consul",
+		"service":  "consul",
+		"address":  srv.URL,
+		"token":    "wrong",
+	}
+	_, err := p.Addrs(args, l)
+	if err == nil || !strings.Contains(err.Error(), "403 Forbidden: ACL not found") {
+		t.Fatalf("got error %v", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := fakeConsul(t, "", catalog)
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	discovertest.RunConformance(t, &consul.Provider{}, discovertest.Fixtures{
+		Name: "consul",
+		Args: discover.Config{
+			"provider":     "consul",
+			"service":      "consul",
+			"address":      srv.URL,
+			"token":        "",
+			"passing_only": "true",
+			"tags":         "server",
+		},
+		Want: []string{"10.0.0.1:8300", "172.16.0.2:8300"},
+		Invalid: discover.Config{
+			"provider": "consul",
+			"address":  srv.URL,
+		},
+		Timeout: discover.Config{
+			"provider": "consul",
+			"service":  "consul",
+			"address":  hang.URL,
+			"timeout":  "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider": "consul",
+			"service":  "consul",
+			"address":  hang.URL,
+			"timeout":  "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
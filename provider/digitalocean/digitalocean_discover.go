@@ -87,6 +87,10 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	apiToken := args["api_token"]
 	l.Printf("[DEBUG] discover-digitalocean: Using region=%s tag_name=%s", region, tagName)
 
+	if apiToken == "" {
+		return nil, fmt.Errorf("discover-digitalocean: api_token is required")
+	}
+
 	tokenSource := &TokenSource{
 		AccessToken: apiToken,
 	}
@@ -0,0 +1,144 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package dns provides node discovery for DNS A and AAAA records.
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-discover/internal/resolver"
+)
+
+type Provider struct{}
+
+func (p *Provider) Help() string {
+	return `DNS:
+
+    provider:   "dns"
+    hosts:      Comma separated list of host names to resolve.
+    port:       Optional port which is appended to every address.
+    nameserver: Address of the DNS server to query, e.g. "10.0.0.2:53".
+                Defaults to the system resolver. The port defaults to 53.
+    transport:  "udp" or "tcp". Default "udp", which falls back to TCP for
+                truncated responses.
+    timeout:    Timeout for resolving all hosts. Default "5s".
+    v6:         IPv6 addresses will be returned when set to "true" and
+                ignored when set to "false". Default "true".
+    v4:         IPv4 addresses will be returned when set to "true" and
+                ignored when set to "false". Default "true".
+
+    All A and AAAA records of every host are returned. The addresses of
+    a host are sorted with IPv4 before IPv6 so that the result does not
+    depend on the order the DNS server returns them in. Hosts which do
+    not exist are skipped.
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "dns" {
+		return nil, fmt.Errorf("discover-dns: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	var hosts []string
+	for _, h := range strings.Split(args["hosts"], ",") {
+		if h = strings.TrimSpace(h); h != "" {
+			hosts = append(hosts, h)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, fmt.Errorf("discover-dns: hosts is required")
+	}
+
+	port := args["port"]
+	if port != "" {
+		if n, err := strconv.ParseUint(port, 10, 16); err != nil || n == 0 {
+			return nil, fmt.Errorf("discover-dns: invalid port %q", port)
+		}
+	}
+
+	v4, v6 := true, true
+	var err error
+	if v := args["v4"]; v != "" {
+		if v4, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-dns: v4 must be boolean value: %w", err)
+		}
+	}
+	if v := args["v6"]; v != "" {
+		if v6, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-dns: v6 must be boolean value: %w", err)
+		}
+	}
+	network := "ip"
+	switch {
+	case v4 && !v6:
+		network = "ip4"
+	case v6 && !v4:
+		network = "ip6"
+	case !v4 && !v6:
+		return nil, fmt.Errorf("discover-dns: v4 and v6 cannot both be disabled")
+	}
+
+	timeout := 5 * time.Second
+	if v := args["timeout"]; v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("discover-dns: invalid timeout: %w", err)
+		}
+	}
+
+	conf, err := resolver.New(args["nameserver"], args["transport"])
+	if err != nil {
+		return nil, fmt.Errorf("discover-dns: %w", err)
+	}
+	netResolver := conf.Net()
+	l.Printf("[DEBUG] discover-dns: Using hosts=%v nameserver=%s transport=%s network=%s",
+		hosts, args["nameserver"], args["transport"], network)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var addrs []string
+	seen := map[netip.Addr]bool{}
+	for _, host := range hosts {
+		ips, err := netResolver.LookupNetIP(ctx, network, host)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				l.Printf("[DEBUG] discover-dns: No %s records for %s", network, host)
+				continue
+			}
+			return nil, fmt.Errorf("discover-dns: %w", err)
+		}
+
+		sort.Slice(ips, func(i, j int) bool { return ips[i].Less(ips[j]) })
+		for _, ip := range ips {
+			ip = ip.Unmap()
+			if seen[ip] {
+				continue
+			}
+			seen[ip] = true
+
+			addr := ip.String()
+			if port != "" {
+				addr = net.JoinHostPort(addr, port)
+			}
+			l.Printf("[INFO] discover-dns: Host %s has address %s", host, addr)
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
@@ -0,0 +1,187 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package dns_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/internal/dnstest"
+	"github.com/hashicorp/go-discover/provider/dns"
+)
+
+var _ discover.Provider = (*dns.Provider)(nil)
+
+var zone = []string{
+	"servers.example.test. 60 IN A 10.0.0.3",
+	"servers.example.test. 60 IN A 10.0.0.1",
+	"servers.example.test. 60 IN AAAA fd00::2",
+	"servers.example.test. 60 IN A 10.0.0.2",
+	"servers.example.test. 60 IN AAAA fd00::1",
+	"other.example.test. 60 IN A 10.0.0.9",
+	"other.example.test. 60 IN A 10.0.0.1",
+	"v6only.example.test. 60 IN AAAA fd00::6",
+}
+
+func TestAddrs(t *testing.T) {
+	srv := dnstest.NewServer(t, zone...)
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"all records sorted",
+			map[string]string{"hosts": "servers.example.test"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3", "fd00::1", "fd00::2"},
+			"",
+		},
+		{
+			"port",
+			map[string]string{"hosts": "servers.example.test", "port": "8301"},
+			[]string{"10.0.0.1:8301", "10.0.0.2:8301", "10.0.0.3:8301", "[fd00::1]:8301", "[fd00::2]:8301"},
+			"",
+		},
+		{
+			"v4 only",
+			map[string]string{"hosts": "servers.example.test", "v6": "false"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			"",
+		},
+		{
+			"v6 only",
+			map[string]string{"hosts": "servers.example.test", "v4": "false"},
+			[]string{"fd00::1", "fd00::2"},
+			"",
+		},
+		{
+			"multiple hosts deduplicated",
+			map[string]string{"hosts": "other.example.test, servers.example.test", "v6": "false"},
+			[]string{"10.0.0.1", "10.0.0.9", "10.0.0.2", "10.0.0.3"},
+			"",
+		},
+		{
+			"missing hosts are skipped",
+			map[string]string{"hosts": "missing.example.test,v6only.example.test"},
+			[]string{"fd00::6"},
+			"",
+		},
+		{
+			"no records of family",
+			map[string]string{"hosts": "v6only.example.test", "v6": "false"},
+			nil,
+			"",
+		},
+		{
+			"tcp",
+			map[string]string{"hosts": "v6only.example.test", "transport": "tcp"},
+			[]string{"fd00::6"},
+			"",
+		},
+		{
+			"invalid transport",
+			map[string]string{"hosts": "v6only.example.test", "transport": "sctp"},
+			nil,
+			`invalid transport "sctp"`,
+		},
+		{
+			"no family",
+			map[string]string{"hosts": "v6only.example.test", "v4": "false", "v6": "false"},
+			nil,
+			"cannot both be disabled",
+		},
+		{
+			"invalid port",
+			map[string]string{"hosts": "v6only.example.test", "port": "http"},
+			nil,
+			`invalid port "http"`,
+		},
+	}
+
+	p := &dns.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "dns", "nameserver": srv.Addr}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAddrsTransport(t *testing.T) {
+	p := &dns.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+
+	for _, transport := range []string{"udp", "tcp"} {
+		srv := dnstest.NewServer(t, zone...)
+		args := discover.Config{
+			"provider":   "dns",
+			"hosts":      "other.example.test",
+			"nameserver": srv.Addr,
+			"transport":  transport,
+		}
+		if _, err := p.Addrs(args, l); err != nil {
+			t.Fatalf("%s: %s", transport, err)
+		}
+		if srv.Queries(transport) == 0 {
+			t.Fatalf("%s: no queries received over %s", transport, transport)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := dnstest.NewServer(t, zone...)
+	blackhole := dnstest.Blackhole(t)
+
+	discovertest.RunConformance(t, &dns.Provider{}, discovertest.Fixtures{
+		Name: "dns",
+		Args: discover.Config{
+			"provider":   "dns",
+			"hosts":      "servers.example.test",
+			"nameserver": srv.Addr,
+			"port":       "8301",
+		},
+		Want: []string{"10.0.0.1:8301", "10.0.0.2:8301", "10.0.0.3:8301", "[fd00::1]:8301", "[fd00::2]:8301"},
+		Invalid: discover.Config{
+			"provider":   "dns",
+			"nameserver": srv.Addr,
+		},
+		Timeout: discover.Config{
+			"provider":   "dns",
+			"hosts":      "servers.example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider":   "dns",
+			"hosts":      "servers.example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
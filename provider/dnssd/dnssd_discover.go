@@ -0,0 +1,257 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package dnssd provides node discovery via unicast DNS-based service
+// discovery (RFC 6763).
+package dnssd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-discover/internal/resolver"
+	"github.com/hashicorp/go-discover/internal/txtfilter"
+	"github.com/miekg/dns"
+)
+
+type Provider struct{}
+
+func (p *Provider) Help() string {
+	return `DNS-SD:
+
+    provider:   "dnssd"
+    service:    The service type to browse, e.g. "_consul._tcp".
+    domain:     The unicast DNS domain to browse, e.g. "example.com".
+    txt_filter: Comma separated list of "key=value" pairs which must all
+                be present in the TXT record of an instance, e.g.
+                txt_filter="role=server,dc=dc1". A "key" without a value
+                only requires the key to be present.
+    nameserver: Address of the DNS server to query, e.g. "10.0.0.2:53".
+                Defaults to the servers in /etc/resolv.conf, which are
+                tried in turn. The port defaults to 53.
+    transport:  "udp" or "tcp". Default "udp", which falls back to TCP for
+                truncated responses.
+    timeout:    Timeout for all lookups. Default "5s".
+    v6:         IPv6 will be allowed and preferred when set to "true"
+                and disabled when set to "false". Default "true".
+    v4:         IPv4 will be allowed when set to "true" and disabled
+                when set to "false". Default "true".
+
+    The service instances listed by the PTR records of
+    "<service>.<domain>" are resolved through their SRV, TXT, A and AAAA
+    records. One address is returned per SRV record, ordered by instance
+    name and then by priority, lowest first, and weight, highest first.
+    Weights only set a fixed preference and are not used for the weighted
+    random selection of RFC 2782.
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "dnssd" {
+		return nil, fmt.Errorf("discover-dnssd: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	service := strings.Trim(args["service"], ".")
+	domain := strings.Trim(args["domain"], ".")
+	if service == "" || domain == "" {
+		return nil, fmt.Errorf("discover-dnssd: service and domain are required")
+	}
+
+	filter, err := txtfilter.Parse(args["txt_filter"])
+	if err != nil {
+		return nil, fmt.Errorf("discover-dnssd: %w", err)
+	}
+
+	v4, v6 := true, true
+	if v := args["v4"]; v != "" {
+		if v4, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-dnssd: v4 must be boolean value: %w", err)
+		}
+	}
+	if v := args["v6"]; v != "" {
+		if v6, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-dnssd: v6 must be boolean value: %w", err)
+		}
+	}
+	if !v4 && !v6 {
+		return nil, fmt.Errorf("discover-dnssd: v4 and v6 cannot both be disabled")
+	}
+
+	timeout := 5 * time.Second
+	if v := args["timeout"]; v != "" {
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("discover-dnssd: invalid timeout: %w", err)
+		}
+	}
+
+	conf, err := resolver.New(args["nameserver"], args["transport"])
+	if err != nil {
+		return nil, fmt.Errorf("discover-dnssd: %w", err)
+	}
+	r := &client{conf}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	name := service + "." + domain + "."
+	l.Printf("[DEBUG] discover-dnssd: Browsing %s using nameserver=%s transport=%s", name, conf.Nameserver, conf.Transport)
+
+	ptrs, err := r.query(ctx, name, dns.TypePTR)
+	if err != nil {
+		return nil, fmt.Errorf("discover-dnssd: %w", err)
+	}
+	var instances []string
+	for _, rr := range ptrs {
+		if ptr, ok := rr.(*dns.PTR); ok {
+			instances = append(instances, ptr.Ptr)
+		}
+	}
+	sort.Strings(instances)
+
+	var addrs []string
+	seen := map[string]bool{}
+	for _, instance := range instances {
+		if seen[instance] {
+			continue
+		}
+		seen[instance] = true
+
+		if !filter.Empty() {
+			txt, err := r.txt(ctx, instance)
+			if err != nil {
+				return nil, fmt.Errorf("discover-dnssd: %w", err)
+			}
+			if !filter.Match(txt) {
+				l.Printf("[DEBUG] discover-dnssd: Instance %s does not match txt_filter", instance)
+				continue
+			}
+		}
+
+		rrs, err := r.query(ctx, instance, dns.TypeSRV)
+		if err != nil {
+			return nil, fmt.Errorf("discover-dnssd: %w", err)
+		}
+		var records []*net.SRV
+		for _, rr := range rrs {
+			if srv, ok := rr.(*dns.SRV); ok && srv.Target != "." {
+				records = append(records, &net.SRV{Target: srv.Target, Port: srv.Port, Priority: srv.Priority, Weight: srv.Weight})
+			}
+		}
+		if len(records) == 0 {
+			l.Printf("[DEBUG] discover-dnssd: Instance %s has no SRV record", instance)
+			continue
+		}
+		resolver.SortSRV(records)
+
+		for _, srv := range records {
+			ip, err := r.addr(ctx, srv.Target, v4, v6)
+			if err != nil {
+				return nil, fmt.Errorf("discover-dnssd: %w", err)
+			}
+			if !ip.IsValid() {
+				l.Printf("[DEBUG] discover-dnssd: Target %s of %s has no addresses", srv.Target, instance)
+				continue
+			}
+			addr := net.JoinHostPort(ip.String(), strconv.Itoa(int(srv.Port)))
+			l.Printf("[INFO] discover-dnssd: %s -> %s", instance, addr)
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs, nil
+}
+
+// client sends the queries of the provider.
+type client struct {
+	*resolver.Resolver
+}
+
+// query returns the answers of type qtype for name. A name which does not
+// exist has no answers.
+func (c *client) query(ctx context.Context, name string, qtype uint16) ([]dns.RR, error) {
+	m := new(dns.Msg)
+	m.SetQuestion(dns.Fqdn(name), qtype)
+
+	in, err := c.Exchange(ctx, m)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s %s: %w", dns.TypeToString[qtype], name, err)
+	}
+
+	switch in.Rcode {
+	case dns.RcodeSuccess:
+	case dns.RcodeNameError:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("lookup %s %s: %s", dns.TypeToString[qtype], name, dns.RcodeToString[in.Rcode])
+	}
+
+	var answers []dns.RR
+	for _, rr := range in.Answer {
+		if rr.Header().Rrtype == qtype {
+			answers = append(answers, rr)
+		}
+	}
+	return answers, nil
+}
+
+// txt returns the strings of all TXT records of name.
+func (c *client) txt(ctx context.Context, name string) ([]string, error) {
+	rrs, err := c.query(ctx, name, dns.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+	var txt []string
+	for _, rr := range rrs {
+		txt = append(txt, rr.(*dns.TXT).Txt...)
+	}
+	return txt, nil
+}
+
+// addr returns the lowest address of host, preferring IPv6 when it is
+// enabled. The zero Addr is returned if host has no address of an enabled
+// family.
+func (c *client) addr(ctx context.Context, host string, v4, v6 bool) (netip.Addr, error) {
+	var qtypes []uint16
+	if v6 {
+		qtypes = append(qtypes, dns.TypeAAAA)
+	}
+	if v4 {
+		qtypes = append(qtypes, dns.TypeA)
+	}
+
+	for _, qtype := range qtypes {
+		rrs, err := c.query(ctx, host, qtype)
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		var ips []netip.Addr
+		for _, rr := range rrs {
+			var ip net.IP
+			switch rr := rr.(type) {
+			case *dns.A:
+				ip = rr.A
+			case *dns.AAAA:
+				ip = rr.AAAA
+			}
+			if a, ok := netip.AddrFromSlice(ip); ok {
+				ips = append(ips, a.Unmap())
+			}
+		}
+		if len(ips) > 0 {
+			sort.Slice(ips, func(i, j int) bool { return ips[i].Less(ips[j]) })
+			return ips[0], nil
+		}
+	}
+	return netip.Addr{}, nil
+}
@@ -0,0 +1,224 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package dnssd_test
+
+import (
+	"context"
+	"log"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/internal/dnstest"
+	"github.com/hashicorp/go-discover/provider/dnssd"
+)
+
+var _ discover.Provider = (*dnssd.Provider)(nil)
+
+var zone = []string{
+	// The PTR records are deliberately out of order.
+	`_consul._tcp.example.test. 60 IN PTR server-b._consul._tcp.example.test.`,
+	`_consul._tcp.example.test. 60 IN PTR server-a._consul._tcp.example.test.`,
+	`_consul._tcp.example.test. 60 IN PTR client\ 1._consul._tcp.example.test.`,
+	`_consul._tcp.example.test. 60 IN PTR gone._consul._tcp.example.test.`,
+
+	`server-a._consul._tcp.example.test. 60 IN SRV 0 0 8301 a.example.test.`,
+	`server-a._consul._tcp.example.test. 60 IN TXT "role=server" "dc=dc1"`,
+	`server-b._consul._tcp.example.test. 60 IN SRV 10 0 8301 b2.example.test.`,
+	`server-b._consul._tcp.example.test. 60 IN SRV 0 0 8302 b1.example.test.`,
+	`server-b._consul._tcp.example.test. 60 IN TXT "Role=server" "dc=dc2" "tls"`,
+	`client\ 1._consul._tcp.example.test. 60 IN SRV 0 0 8301 c.example.test.`,
+	`client\ 1._consul._tcp.example.test. 60 IN TXT "role=client" "dc=dc1"`,
+
+	`a.example.test. 60 IN A 10.0.0.2`,
+	`a.example.test. 60 IN A 10.0.0.1`,
+	`a.example.test. 60 IN AAAA fd00::1`,
+	`b1.example.test. 60 IN A 10.0.1.1`,
+	`b2.example.test. 60 IN AAAA fd00::2`,
+	`c.example.test. 60 IN A 10.0.2.1`,
+
+	`_empty._tcp.example.test. 60 IN TXT "no instances"`,
+}
+
+func TestAddrs(t *testing.T) {
+	srv := dnstest.NewServer(t, zone...)
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"all instances",
+			map[string]string{},
+			[]string{"10.0.2.1:8301", "[fd00::1]:8301", "10.0.1.1:8302", "[fd00::2]:8301"},
+			"",
+		},
+		{
+			"v4 only",
+			map[string]string{"v6": "false"},
+			[]string{"10.0.2.1:8301", "10.0.0.1:8301", "10.0.1.1:8302"},
+			"",
+		},
+		{
+			"v6 only",
+			map[string]string{"v4": "false"},
+			[]string{"[fd00::1]:8301", "[fd00::2]:8301"},
+			"",
+		},
+		{
+			"txt filter",
+			map[string]string{"txt_filter": "role=server"},
+			[]string{"[fd00::1]:8301", "10.0.1.1:8302", "[fd00::2]:8301"},
+			"",
+		},
+		{
+			"txt filter all pairs",
+			map[string]string{"txt_filter": "role=server, dc=dc1"},
+			[]string{"[fd00::1]:8301"},
+			"",
+		},
+		{
+			"txt filter key only",
+			map[string]string{"txt_filter": "TLS"},
+			[]string{"10.0.1.1:8302", "[fd00::2]:8301"},
+			"",
+		},
+		{
+			"txt filter value is case sensitive",
+			map[string]string{"txt_filter": "role=Server"},
+			nil,
+			"",
+		},
+		{
+			"tcp",
+			map[string]string{"transport": "tcp", "txt_filter": "role=client"},
+			[]string{"10.0.2.1:8301"},
+			"",
+		},
+		{
+			"no instances",
+			map[string]string{"service": "_empty._tcp"},
+			nil,
+			"",
+		},
+		{
+			"missing service",
+			map[string]string{"service": "_missing._tcp"},
+			nil,
+			"",
+		},
+		{
+			"missing domain",
+			map[string]string{"domain": ""},
+			nil,
+			"service and domain are required",
+		},
+		{
+			"invalid txt filter",
+			map[string]string{"txt_filter": "=server"},
+			nil,
+			"empty key",
+		},
+		{
+			"no family",
+			map[string]string{"v4": "false", "v6": "false"},
+			nil,
+			"cannot both be disabled",
+		},
+		{
+			"invalid transport",
+			map[string]string{"transport": "sctp"},
+			nil,
+			`invalid transport "sctp"`,
+		},
+	}
+
+	p := &dnssd.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider":   "dnssd",
+				"service":    "_consul._tcp",
+				"domain":     "example.test",
+				"nameserver": srv.Addr,
+			}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+// TestAddrsConfigString checks that a txt_filter in a config string as
+// documented in Help can be parsed.
+func TestAddrsConfigString(t *testing.T) {
+	srv := dnstest.NewServer(t, zone...)
+
+	args, err := discover.Parse(`provider=dnssd service=_consul._tcp domain=example.test txt_filter="role=server,dc=dc1" nameserver=` + srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := (&dnssd.Provider{}).Addrs(args, log.New(os.Stderr, "", log.LstdFlags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"[fd00::1]:8301"}; !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("got %v want %v", addrs, want)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := dnstest.NewServer(t, zone...)
+	blackhole := dnstest.Blackhole(t)
+
+	discovertest.RunConformance(t, &dnssd.Provider{}, discovertest.Fixtures{
+		Name: "dnssd",
+		Args: discover.Config{
+			"provider":   "dnssd",
+			"service":    "_consul._tcp",
+			"domain":     "example.test",
+			"nameserver": srv.Addr,
+		},
+		Want: []string{"10.0.2.1:8301", "[fd00::1]:8301", "10.0.1.1:8302", "[fd00::2]:8301"},
+		Invalid: discover.Config{
+			"provider":   "dnssd",
+			"service":    "_consul._tcp",
+			"nameserver": srv.Addr,
+		},
+		Timeout: discover.Config{
+			"provider":   "dnssd",
+			"service":    "_consul._tcp",
+			"domain":     "example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider":   "dnssd",
+			"service":    "_consul._tcp",
+			"domain":     "example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
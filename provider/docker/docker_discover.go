@@ -0,0 +1,354 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package docker provides node discovery for containers of a Docker
+// Engine.
+//
+// The provider talks to the Engine API directly to avoid the dependencies
+// of the Docker client library.
+package docker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+)
+
+type Provider struct {
+	userAgent string
+}
+
+func (p *Provider) SetUserAgent(s string) {
+	p.userAgent = s
+}
+
+func (p *Provider) Help() string {
+	return `Docker:
+
+    provider:   "docker"
+    host:       Address of the Docker Engine API, "unix:///path/to/socket" or
+                "tcp://host:port". Default "unix:///var/run/docker.sock".
+    label:      Comma separated list of labels the containers must all have,
+                either "key" or "key=value", e.g. label="cluster=consul,role=server".
+    network:    The network to take the container address from. Containers
+                attached to more than one network are skipped if it is not set.
+    port:       Optional container port, e.g. "8301" or "8301/udp".
+    port_type:  "container" to return the container address with the container
+                port or "host" to return the host address with the port the
+                container port is published on. Default "container".
+    cert_path:  Directory containing ca.pem, cert.pem and key.pem to connect
+                to a TCP host with TLS.
+    tls_verify: Whether or not to verify the certificate of the Docker host.
+                Default "true" if cert_path is set.
+    timeout:    Timeout for the request. Default "10s".
+
+    Only running containers are returned, ordered by name. For published
+    ports an unspecified host IP is replaced with the host name of the
+    Docker host, or 127.0.0.1 if it is reached over a unix socket.
+
+    Variables can also be provided by environment variables:
+    export DOCKER_HOST for host
+    export DOCKER_CERT_PATH for cert_path
+    export DOCKER_TLS_VERIFY for tls_verify
+`
+}
+
+// container is the subset of an entry of the /containers/json response
+// used for discovery.
+type container struct {
+	ID    string `json:"Id"`
+	Names []string
+	Ports []portBinding
+
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string
+			GlobalIPv6Address string
+		}
+	}
+}
+
+type portBinding struct {
+	IP          string
+	PrivatePort int
+	PublicPort  int
+	Type        string
+}
+
+func (c container) name() string {
+	if len(c.Names) == 0 {
+		return c.ID
+	}
+	return strings.TrimPrefix(c.Names[0], "/")
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "docker" {
+		return nil, fmt.Errorf("discover-docker: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	var port int
+	proto := "tcp"
+	if v := args["port"]; v != "" {
+		num, pr, ok := strings.Cut(v, "/")
+		if ok {
+			proto = pr
+		}
+		n, err := strconv.ParseUint(num, 10, 16)
+		if err != nil || n == 0 || (proto != "tcp" && proto != "udp" && proto != "sctp") {
+			return nil, fmt.Errorf("discover-docker: invalid port %q", v)
+		}
+		port = int(n)
+	}
+
+	portType := args["port_type"]
+	switch portType {
+	case "":
+		portType = "container"
+	case "container", "host":
+	default:
+		return nil, fmt.Errorf("discover-docker: invalid port_type %q, must be \"container\" or \"host\"", portType)
+	}
+	if portType == "host" && port == 0 {
+		return nil, fmt.Errorf("discover-docker: port_type \"host\" requires port")
+	}
+
+	timeout, err := httpclient.Timeout(args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+
+	host := argsOrEnv(args, "host", "DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	client, base, hostAddr, err := newClient(host, args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+	defer client.CloseIdleConnections()
+
+	filters := map[string][]string{"status": {"running"}}
+	for _, label := range strings.Split(args["label"], ",") {
+		if label = strings.TrimSpace(label); label != "" {
+			filters["label"] = append(filters["label"], label)
+		}
+	}
+	network := args["network"]
+	if network != "" {
+		filters["network"] = []string{network}
+	}
+	f, err := json.Marshal(filters)
+	if err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+	u := base.JoinPath("/containers/json")
+	u.RawQuery = url.Values{"filters": {string(f)}}.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+
+	l.Printf("[DEBUG] discover-docker: Using host=%s filters=%s", host, f)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, fmt.Errorf("discover-docker: %w", err)
+	}
+
+	var containers []container
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("discover-docker: invalid response: %w", err)
+	}
+	sort.Slice(containers, func(i, j int) bool { return containers[i].name() < containers[j].name() })
+	l.Printf("[DEBUG] discover-docker: Found %d containers", len(containers))
+
+	var addrs []string
+	for _, c := range containers {
+		var addr string
+		if portType == "host" {
+			addr = publishedAddr(c, port, proto, hostAddr)
+		} else {
+			if addr, err = containerAddr(c, network); err != nil {
+				l.Printf("[WARN] discover-docker: %s", err)
+				continue
+			}
+			if addr != "" && port != 0 {
+				addr = net.JoinHostPort(addr, strconv.Itoa(port))
+			}
+		}
+		if addr == "" {
+			l.Printf("[DEBUG] discover-docker: Container %s has no %s address", c.name(), portType)
+			continue
+		}
+		l.Printf("[INFO] discover-docker: Container %s has address %s", c.name(), addr)
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// containerAddr returns the address of c on network, or on its only
+// network if network is empty. IPv4 addresses are preferred.
+func containerAddr(c container, network string) (string, error) {
+	networks := c.NetworkSettings.Networks
+	if network == "" {
+		if len(networks) > 1 {
+			var names []string
+			for name := range networks {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return "", fmt.Errorf("container %s is attached to networks %s, network is required", c.name(), strings.Join(names, ", "))
+		}
+		for name := range networks {
+			network = name
+		}
+	}
+	n, ok := networks[network]
+	if !ok {
+		return "", nil
+	}
+	if n.IPAddress != "" {
+		return n.IPAddress, nil
+	}
+	return n.GlobalIPv6Address, nil
+}
+
+// publishedAddr returns the host address port/proto of c is published
+// on. Bindings to an unspecified IP are reachable on hostAddr. IPv4
+// bindings are preferred.
+func publishedAddr(c container, port int, proto, hostAddr string) string {
+	var v6 string
+	for _, b := range c.Ports {
+		if b.PrivatePort != port || b.Type != proto || b.PublicPort == 0 {
+			continue
+		}
+		publicPort := strconv.Itoa(b.PublicPort)
+		ip := net.ParseIP(b.IP)
+		if ip == nil || ip.IsUnspecified() {
+			return net.JoinHostPort(hostAddr, publicPort)
+		}
+		if ip.To4() != nil {
+			return net.JoinHostPort(ip.String(), publicPort)
+		}
+		if v6 == "" {
+			v6 = net.JoinHostPort(ip.String(), publicPort)
+		}
+	}
+	return v6
+}
+
+// newClient returns an HTTP client for the Docker host, the base URL of
+// the API and the address published ports are reachable on.
+func newClient(host string, args map[string]string) (*http.Client, *url.URL, string, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, nil, "", fmt.Errorf("invalid host: %w", err)
+	}
+
+	switch u.Scheme {
+	case "unix":
+		path := u.Path
+		if path == "" {
+			path = u.Opaque
+		}
+		client := httpclient.New(nil)
+		transport := client.Transport.(*http.Transport)
+		transport.Proxy = nil
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", path)
+		}
+		// The host name is ignored when dialing the socket.
+		return client, &url.URL{Scheme: "http", Host: "docker"}, "127.0.0.1", nil
+
+	case "tcp", "http", "https":
+		if u.Host == "" {
+			return nil, nil, "", fmt.Errorf("invalid host %q", host)
+		}
+
+		tlsConfig, err := tlsConfig(args)
+		if err != nil {
+			return nil, nil, "", err
+		}
+		scheme := u.Scheme
+		if scheme == "tcp" {
+			scheme = "http"
+			if tlsConfig != nil {
+				scheme = "https"
+			}
+		}
+		return httpclient.New(tlsConfig), &url.URL{Scheme: scheme, Host: u.Host, Path: u.Path}, u.Hostname(), nil
+
+	default:
+		return nil, nil, "", fmt.Errorf("invalid host %q, must be unix:// or tcp://", host)
+	}
+}
+
+// tlsConfig builds the TLS configuration for a TCP host from cert_path
+// and tls_verify the way the Docker CLI does. It returns nil if no
+// cert_path is set.
+func tlsConfig(args map[string]string) (*tls.Config, error) {
+	dir := argsOrEnv(args, "cert_path", "DOCKER_CERT_PATH")
+	if dir == "" {
+		return nil, nil
+	}
+
+	verify := true
+	if v := argsOrEnv(args, "tls_verify", "DOCKER_TLS_VERIFY"); v != "" {
+		var err error
+		if verify, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("tls_verify must be boolean value: %w", err)
+		}
+	}
+
+	tlsArgs := map[string]string{
+		"cert_file":    filepath.Join(dir, "cert.pem"),
+		"key_file":     filepath.Join(dir, "key.pem"),
+		"insecure_ssl": strconv.FormatBool(!verify),
+	}
+	if verify {
+		tlsArgs["ca_file"] = filepath.Join(dir, "ca.pem")
+	}
+	cfg, err := httpclient.TLSConfig(tlsArgs, httpclient.TLSEnv{})
+	if err != nil {
+		return nil, fmt.Errorf("cert_path %s: %w", dir, err)
+	}
+	return cfg, nil
+}
+
+// argsOrEnv allows you to pick an environmental variable for a setting if the arg is not set
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
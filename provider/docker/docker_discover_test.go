@@ -0,0 +1,352 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package docker_test
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/docker"
+)
+
+var _ discover.Provider = (*docker.Provider)(nil)
+var _ discover.ProviderWithUserAgent = (*docker.Provider)(nil)
+
+type testContainer struct {
+	Name     string
+	State    string
+	Labels   map[string]string
+	Networks map[string]string
+	Ports    []map[string]interface{}
+}
+
+// containers are listed newest first like the Engine API does.
+var containers = []testContainer{
+	{
+		Name:     "node-3",
+		State:    "running",
+		Labels:   map[string]string{"cluster": "consul", "role": "client"},
+		Networks: map[string]string{"consul": "172.20.0.4"},
+	},
+	{
+		Name:     "node-2",
+		State:    "running",
+		Labels:   map[string]string{"cluster": "consul", "role": "server"},
+		Networks: map[string]string{"consul": "172.20.0.3", "bridge": "172.17.0.3"},
+		Ports: []map[string]interface{}{
+			{"IP": "::", "PrivatePort": 8301, "PublicPort": 28302, "Type": "tcp"},
+			{"IP": "0.0.0.0", "PrivatePort": 8301, "PublicPort": 28302, "Type": "tcp"},
+		},
+	},
+	{
+		Name:     "node-1",
+		State:    "running",
+		Labels:   map[string]string{"cluster": "consul", "role": "server"},
+		Networks: map[string]string{"consul": "172.20.0.2"},
+		Ports: []map[string]interface{}{
+			{"IP": "127.0.0.2", "PrivatePort": 8301, "PublicPort": 28301, "Type": "tcp"},
+			{"IP": "127.0.0.2", "PrivatePort": 8301, "PublicPort": 28311, "Type": "udp"},
+			{"PrivatePort": 8500, "Type": "tcp"},
+		},
+	},
+	{
+		Name:     "node-0",
+		State:    "exited",
+		Labels:   map[string]string{"cluster": "consul", "role": "server"},
+		Networks: map[string]string{"consul": ""},
+	},
+	{
+		Name:     "web",
+		State:    "running",
+		Labels:   map[string]string{"app": "web"},
+		Networks: map[string]string{"bridge": "172.17.0.9"},
+	},
+}
+
+// fakeEngine serves /containers/json with the filter semantics of the
+// Docker Engine API.
+func fakeEngine() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		var filters map[string][]string
+		if f := r.URL.Query().Get("filters"); f != "" {
+			if err := json.Unmarshal([]byte(f), &filters); err != nil {
+				http.Error(w, `{"message":"invalid filter"}`, http.StatusBadRequest)
+				return
+			}
+		}
+
+		list := []map[string]interface{}{}
+	Containers:
+		for _, c := range containers {
+			for _, status := range filters["status"] {
+				if c.State != status {
+					continue Containers
+				}
+			}
+			for _, label := range filters["label"] {
+				k, v, ok := strings.Cut(label, "=")
+				got, has := c.Labels[k]
+				if !has || (ok && got != v) {
+					continue Containers
+				}
+			}
+			for _, network := range filters["network"] {
+				if _, ok := c.Networks[network]; !ok {
+					continue Containers
+				}
+			}
+
+			networks := map[string]interface{}{}
+			for name, ip := range c.Networks {
+				networks[name] = map[string]string{"IPAddress": ip, "GlobalIPv6Address": ""}
+			}
+			ports := c.Ports
+			if ports == nil {
+				ports = []map[string]interface{}{}
+			}
+			list = append(list, map[string]interface{}{
+				"Id":              "id-" + c.Name,
+				"Names":           []string{"/" + c.Name},
+				"State":           c.State,
+				"Labels":          c.Labels,
+				"Ports":           ports,
+				"NetworkSettings": map[string]interface{}{"Networks": networks},
+			})
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(list)
+	})
+}
+
+// unixServer serves h on a unix socket and returns its "unix://" address.
+func unixServer(t *testing.T, h http.Handler) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets are not supported: %s", err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.Start()
+	t.Cleanup(srv.Close)
+	return "unix://" + path
+}
+
+func TestAddrs(t *testing.T) {
+	host := unixServer(t, fakeEngine())
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"label",
+			map[string]string{"label": "cluster=consul", "network": "consul"},
+			[]string{"172.20.0.2", "172.20.0.3", "172.20.0.4"},
+			"",
+		},
+		{
+			"all labels must match",
+			map[string]string{"label": "cluster=consul, role=server", "network": "consul"},
+			[]string{"172.20.0.2", "172.20.0.3"},
+			"",
+		},
+		{
+			"label key only",
+			map[string]string{"label": "app"},
+			[]string{"172.17.0.9"},
+			"",
+		},
+		{
+			"network",
+			map[string]string{"network": "bridge"},
+			[]string{"172.17.0.3", "172.17.0.9"},
+			"",
+		},
+		{
+			"ambiguous network is skipped",
+			map[string]string{"label": "role=server"},
+			[]string{"172.20.0.2"},
+			"",
+		},
+		{
+			"container port",
+			map[string]string{"label": "role=server", "network": "consul", "port": "8301"},
+			[]string{"172.20.0.2:8301", "172.20.0.3:8301"},
+			"",
+		},
+		{
+			"host port",
+			map[string]string{"label": "role=server", "port": "8301", "port_type": "host"},
+			[]string{"127.0.0.2:28301", "127.0.0.1:28302"},
+			"",
+		},
+		{
+			"host port udp",
+			map[string]string{"label": "role=server", "port": "8301/udp", "port_type": "host"},
+			[]string{"127.0.0.2:28311"},
+			"",
+		},
+		{
+			"unpublished host port",
+			map[string]string{"label": "role=server", "port": "8500", "port_type": "host"},
+			nil,
+			"",
+		},
+		{
+			"no containers",
+			map[string]string{"label": "cluster=nomad"},
+			nil,
+			"",
+		},
+		{
+			"host port without port",
+			map[string]string{"port_type": "host"},
+			nil,
+			`port_type "host" requires port`,
+		},
+		{
+			"invalid port",
+			map[string]string{"port": "8301/icmp"},
+			nil,
+			`invalid port "8301/icmp"`,
+		},
+		{
+			"invalid host",
+			map[string]string{"host": "npipe:////./pipe/docker_engine"},
+			nil,
+			"must be unix:// or tcp://",
+		},
+	}
+
+	p := &docker.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "docker", "host": host}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+// TestAddrsConfigString checks that labels in a config string as
+// documented in Help can be parsed.
+func TestAddrsConfigString(t *testing.T) {
+	host := unixServer(t, fakeEngine())
+
+	args, err := discover.Parse(`provider=docker label="cluster=consul,role=server" network=consul host=` + host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addrs, err := (&docker.Provider{}).Addrs(args, log.New(os.Stderr, "", log.LstdFlags))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"172.20.0.2", "172.20.0.3"}; !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("got %v want %v", addrs, want)
+	}
+}
+
+func TestAddrsTCP(t *testing.T) {
+	var agent string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		agent = r.Header.Get("User-Agent")
+		fakeEngine().ServeHTTP(w, r)
+	}))
+	defer srv.Close()
+	host := "tcp://" + strings.TrimPrefix(srv.URL, "http://")
+
+	p := &docker.Provider{}
+	p.SetUserAgent("go-discover-test")
+	l := log.New(os.Stderr, "", log.LstdFlags)
+
+	// Published ports on unspecified addresses are reachable on the host.
+	t.Setenv("DOCKER_HOST", host)
+	t.Setenv("DOCKER_CERT_PATH", "")
+	args := discover.Config{"provider": "docker", "label": "role=server", "port": "8301", "port_type": "host"}
+	addrs, err := p.Addrs(args, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"127.0.0.2:28301", "127.0.0.1:28302"}; !reflect.DeepEqual(addrs, want) {
+		t.Fatalf("got %v want %v", addrs, want)
+	}
+	if agent != "go-discover-test" {
+		t.Fatalf("got User-Agent %q", agent)
+	}
+
+	args["cert_path"] = t.TempDir()
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "reading ca_file") {
+		t.Fatalf("got error %v, want missing CA certificate", err)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	host := unixServer(t, fakeEngine())
+	hang := unixServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+
+	discovertest.RunConformance(t, &docker.Provider{}, discovertest.Fixtures{
+		Name: "docker",
+		Args: discover.Config{
+			"provider": "docker",
+			"host":     host,
+			"label":    "cluster=consul",
+			"network":  "consul",
+			"port":     "8301",
+		},
+		Want: []string{"172.20.0.2:8301", "172.20.0.3:8301", "172.20.0.4:8301"},
+		Invalid: discover.Config{
+			"provider":  "docker",
+			"host":      host,
+			"port_type": "published",
+		},
+		Timeout: discover.Config{
+			"provider": "docker",
+			"host":     hang,
+			"timeout":  "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider": "docker",
+			"host":     hang,
+			"timeout":  "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
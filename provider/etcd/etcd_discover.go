@@ -0,0 +1,365 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package etcd provides node discovery from a member registry kept in
+// etcd.
+//
+// The provider uses the JSON gateway of the etcd v3 API rather than
+// go.etcd.io/etcd/client/v3 to avoid pulling gRPC and the etcd module
+// tree into every consumer of this module.
+package etcd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+)
+
+// maxBodySize limits how much of a response is read.
+const maxBodySize = 32 << 20
+
+type Provider struct {
+	userAgent string
+}
+
+func (p *Provider) SetUserAgent(s string) {
+	p.userAgent = s
+}
+
+func (p *Provider) Help() string {
+	return `etcd:
+
+    provider:       "etcd"
+    prefix:         The key prefix the members are registered under, e.g. "/cluster/members/".
+    endpoints:      Comma separated list of etcd endpoints. Default "127.0.0.1:2379".
+                    Endpoints without a scheme use "https://" if ca_file or
+                    cert_file is set and "http://" otherwise. The endpoints are
+                    tried in order until one answers.
+    source:         "value" to take the address from the value of a key or "key"
+                    to take it from the part of the key after the prefix.
+                    Default "value".
+    value_field:    Optional field of a JSON object value holding the address,
+                    e.g. "addr" or "network.addr". By default the whole value
+                    is the address.
+    ignore_expired: "true" to ignore keys attached to a lease which has expired.
+                    Default "false".
+    username:       Username for authentication.
+    password:       Password for authentication.
+    ca_file:        PEM encoded CA bundle used to verify the etcd server certificate.
+    cert_file:      PEM encoded client certificate.
+    key_file:       PEM encoded key of the client certificate.
+    insecure_ssl:   Whether or not to skip TLS certificate validation.
+    timeout:        Timeout for all requests. Default "10s".
+
+    Addresses are returned in key order. Keys with an empty address or a
+    value without value_field are skipped.
+
+    Variables can also be provided by environment variables:
+    export ETCDCTL_ENDPOINTS for endpoints
+    export ETCDCTL_USER for username, or "username:password"
+    export ETCDCTL_PASSWORD for password
+    export ETCDCTL_CACERT for ca_file
+    export ETCDCTL_CERT for cert_file
+    export ETCDCTL_KEY for key_file
+`
+}
+
+// keyValue is an entry of the /v3/kv/range response. The gateway encodes
+// bytes as base64 and 64 bit integers as strings.
+type keyValue struct {
+	Key   []byte `json:"key"`
+	Value []byte `json:"value"`
+	Lease int64  `json:"lease,string"`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "etcd" {
+		return nil, fmt.Errorf("discover-etcd: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	prefix := args["prefix"]
+	if prefix == "" {
+		return nil, fmt.Errorf("discover-etcd: prefix is required")
+	}
+
+	source := args["source"]
+	switch source {
+	case "":
+		source = "value"
+	case "value", "key":
+	default:
+		return nil, fmt.Errorf("discover-etcd: invalid source %q, must be \"value\" or \"key\"", source)
+	}
+	field := args["value_field"]
+	if field != "" && source != "value" {
+		return nil, fmt.Errorf("discover-etcd: value_field requires source \"value\"")
+	}
+
+	ignoreExpired := false
+	if v := args["ignore_expired"]; v != "" {
+		var err error
+		if ignoreExpired, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-etcd: ignore_expired must be boolean value: %w", err)
+		}
+	}
+
+	timeout, err := httpclient.Timeout(args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-etcd: %w", err)
+	}
+
+	// The TLS settings can also be read from the environment variables
+	// used by etcdctl.
+	tlsConfig, err := httpclient.TLSConfig(args, httpclient.TLSEnv{
+		CAFile:   "ETCDCTL_CACERT",
+		CertFile: "ETCDCTL_CERT",
+		KeyFile:  "ETCDCTL_KEY",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discover-etcd: %w", err)
+	}
+	secure := tlsConfig.RootCAs != nil || len(tlsConfig.Certificates) > 0
+
+	endpoints, err := parseEndpoints(argsOrEnv(args, "endpoints", "ETCDCTL_ENDPOINTS"), secure)
+	if err != nil {
+		return nil, fmt.Errorf("discover-etcd: %w", err)
+	}
+
+	username := argsOrEnv(args, "username", "ETCDCTL_USER")
+	password := argsOrEnv(args, "password", "ETCDCTL_PASSWORD")
+	if u, pw, ok := strings.Cut(username, ":"); ok && password == "" {
+		username, password = u, pw
+	}
+
+	c := &client{
+		http:      httpclient.New(tlsConfig),
+		userAgent: p.userAgent,
+	}
+	defer c.http.CloseIdleConnections()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	// Authentication tokens are only valid on the member which issued
+	// them, so every endpoint is queried from the start.
+	var kvs []keyValue
+	for i, endpoint := range endpoints {
+		c.endpoint, c.token = endpoint, ""
+		l.Printf("[DEBUG] discover-etcd: Using endpoint=%s prefix=%s", endpoint.Redacted(), prefix)
+		if kvs, err = c.list(ctx, prefix, username, password, ignoreExpired, l); err == nil {
+			break
+		}
+		if ctx.Err() != nil || i == len(endpoints)-1 {
+			return nil, fmt.Errorf("discover-etcd: %w", err)
+		}
+		l.Printf("[WARN] discover-etcd: %s", err)
+	}
+	l.Printf("[DEBUG] discover-etcd: Found %d keys under %s", len(kvs), prefix)
+
+	var addrs []string
+	for _, kv := range kvs {
+		addr, err := address(kv, prefix, source, field)
+		if err != nil {
+			l.Printf("[DEBUG] discover-etcd: Ignoring key %s: %s", kv.Key, err)
+			continue
+		}
+		if addr == "" {
+			l.Printf("[DEBUG] discover-etcd: Ignoring key %s without address", kv.Key)
+			continue
+		}
+		l.Printf("[INFO] discover-etcd: Key %s has address %s", kv.Key, addr)
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// address extracts the address of kv.
+func address(kv keyValue, prefix, source, field string) (string, error) {
+	if source == "key" {
+		return strings.Trim(strings.TrimPrefix(string(kv.Key), prefix), "/"), nil
+	}
+	if field == "" {
+		return strings.TrimSpace(string(kv.Value)), nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(kv.Value, &v); err != nil {
+		return "", fmt.Errorf("value is not JSON: %w", err)
+	}
+	for _, name := range strings.Split(field, ".") {
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return "", fmt.Errorf("value has no field %s", field)
+		}
+		if v, ok = obj[name]; !ok {
+			return "", fmt.Errorf("value has no field %s", field)
+		}
+	}
+	s, ok := v.(string)
+	if !ok {
+		return "", fmt.Errorf("field %s is not a string", field)
+	}
+	return strings.TrimSpace(s), nil
+}
+
+// client sends requests to the JSON gateway of a single etcd endpoint.
+type client struct {
+	http      *http.Client
+	userAgent string
+	endpoint  *url.URL
+	token     string
+}
+
+// list returns the keys under prefix in key order, authenticating first
+// if a username is set.
+func (c *client) list(ctx context.Context, prefix, username, password string, ignoreExpired bool, l *log.Logger) ([]keyValue, error) {
+	if username != "" {
+		var resp struct {
+			Token string `json:"token"`
+		}
+		req := map[string]string{"name": username, "password": password}
+		if err := c.call(ctx, "/v3/auth/authenticate", req, &resp); err != nil {
+			return nil, err
+		}
+		c.token = resp.Token
+	}
+
+	var resp struct {
+		KVs []keyValue `json:"kvs"`
+	}
+	// Ranges are returned in key order.
+	req := map[string][]byte{
+		"key":       []byte(prefix),
+		"range_end": prefixEnd([]byte(prefix)),
+	}
+	if err := c.call(ctx, "/v3/kv/range", req, &resp); err != nil {
+		return nil, err
+	}
+	if !ignoreExpired {
+		return resp.KVs, nil
+	}
+
+	expired := map[int64]bool{}
+	var kvs []keyValue
+	for _, kv := range resp.KVs {
+		if kv.Lease != 0 {
+			if _, ok := expired[kv.Lease]; !ok {
+				var lease struct {
+					TTL int64 `json:"TTL,string"`
+				}
+				req := map[string]string{"ID": strconv.FormatInt(kv.Lease, 10)}
+				if err := c.call(ctx, "/v3/lease/timetolive", req, &lease); err != nil {
+					return nil, err
+				}
+				expired[kv.Lease] = lease.TTL <= 0
+			}
+			if expired[kv.Lease] {
+				l.Printf("[DEBUG] discover-etcd: Ignoring key %s with expired lease %x", kv.Key, kv.Lease)
+				continue
+			}
+		}
+		kvs = append(kvs, kv)
+	}
+	return kvs, nil
+}
+
+// call posts in as JSON to path and decodes the response into out.
+func (c *client) call(ctx context.Context, path string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	u := c.endpoint.JoinPath(path)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+	if c.userAgent != "" {
+		req.Header.Set("User-Agent", c.userAgent)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return fmt.Errorf("%s: %w", u.Redacted(), err)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+	if err != nil {
+		return fmt.Errorf("%s: reading response: %w", u.Redacted(), err)
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s: invalid response: %w", u.Redacted(), err)
+	}
+	return nil
+}
+
+// prefixEnd returns the end of the range of keys starting with prefix.
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	// Every byte is 0xff, so the range extends to the last key.
+	return []byte{0}
+}
+
+func parseEndpoints(s string, secure bool) ([]*url.URL, error) {
+	var endpoints []*url.URL
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e == "" {
+			continue
+		}
+		if !strings.Contains(e, "://") {
+			if secure {
+				e = "https://" + e
+			} else {
+				e = "http://" + e
+			}
+		}
+		u, err := url.Parse(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid endpoint %s: scheme must be http or https", u.Redacted())
+		}
+		endpoints = append(endpoints, u)
+	}
+	if len(endpoints) == 0 {
+		return parseEndpoints("127.0.0.1:2379", secure)
+	}
+	return endpoints, nil
+}
+
+// argsOrEnv allows you to pick an environmental variable for a setting if the arg is not set
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
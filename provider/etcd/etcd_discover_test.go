@@ -0,0 +1,462 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package etcd_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/internal/tlstest"
+	"github.com/hashicorp/go-discover/provider/etcd"
+)
+
+var _ discover.Provider = (*etcd.Provider)(nil)
+var _ discover.ProviderWithUserAgent = (*etcd.Provider)(nil)
+
+type entry struct {
+	Value string
+	Lease int64
+}
+
+var registry = map[string]entry{
+	"/raw/n1":               {Value: "10.0.0.2:8301"},
+	"/raw/n2":               {Value: " 10.0.0.1:8301\n"},
+	"/raw/n3":               {Value: ""},
+	"/raw/n4":               {Value: "10.0.0.4:8301", Lease: 100},
+	"/raw/n5":               {Value: "10.0.0.5:8301", Lease: 200},
+	"/rawx/n1":              {Value: "10.9.9.9:8301"},
+	"/json/n1":              {Value: `{"addr": "10.0.1.1:8301", "net": {"addr": "192.168.0.1"}}`},
+	"/json/n2":              {Value: `not json`},
+	"/json/n3":              {Value: `{"addr": 8301}`},
+	"/json/n4":              {Value: `{"name": "n4", "net": {"addr": "192.168.0.4"}}`},
+	"/keys/10.0.2.2:8301":   {Value: "n2"},
+	"/keys/10.0.2.1:8301":   {Value: "n1"},
+	"/keys/nested/10.0.2.3": {Value: "n3"},
+}
+
+// leases maps lease IDs to their remaining TTL. Expired leases have a
+// TTL of -1 like in etcd.
+var leases = map[int64]int64{100: -1, 200: 30}
+
+// fakeEtcd serves the parts of the etcd v3 JSON gateway used by the
+// provider. If users is set, requests need a token from
+// /v3/auth/authenticate.
+type fakeEtcd struct {
+	kvs    map[string]entry
+	leases map[int64]int64
+	users  map[string]string
+
+	mu     sync.Mutex
+	tokens map[string]bool
+	agent  string
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.agent = r.Header.Get("User-Agent")
+	f.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/v3/auth/authenticate":
+		var req struct {
+			Name     string `json:"name"`
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			grpcError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if pw, ok := f.users[req.Name]; !ok || pw != req.Password {
+			grpcError(w, http.StatusBadRequest, "etcdserver: authentication failed, invalid user ID or password")
+			return
+		}
+		f.mu.Lock()
+		token := fmt.Sprintf("token.%d", len(f.tokens)+1)
+		f.tokens[token] = true
+		f.mu.Unlock()
+		writeJSON(w, map[string]string{"token": token})
+		return
+	}
+
+	if f.users != nil {
+		f.mu.Lock()
+		ok := f.tokens[r.Header.Get("Authorization")]
+		f.mu.Unlock()
+		if !ok {
+			grpcError(w, http.StatusUnauthorized, "etcdserver: user name is empty")
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req struct {
+			Key      []byte `json:"key"`
+			RangeEnd []byte `json:"range_end"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			grpcError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var keys []string
+		for k := range f.kvs {
+			key := []byte(k)
+			if bytes.Compare(key, req.Key) < 0 {
+				continue
+			}
+			if !bytes.Equal(req.RangeEnd, []byte{0}) && bytes.Compare(key, req.RangeEnd) >= 0 {
+				continue
+			}
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		kvs := []map[string]interface{}{}
+		for _, k := range keys {
+			kv := map[string]interface{}{"key": []byte(k), "value": []byte(f.kvs[k].Value)}
+			if id := f.kvs[k].Lease; id != 0 {
+				kv["lease"] = strconv.FormatInt(id, 10)
+			}
+			kvs = append(kvs, kv)
+		}
+		writeJSON(w, map[string]interface{}{
+			"header": map[string]string{"revision": "42"},
+			"kvs":    kvs,
+			"count":  strconv.Itoa(len(kvs)),
+		})
+
+	case "/v3/lease/timetolive":
+		var req struct {
+			ID int64 `json:"ID,string"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			grpcError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ttl, ok := f.leases[req.ID]
+		if !ok {
+			ttl = -1
+		}
+		writeJSON(w, map[string]string{
+			"ID":  strconv.FormatInt(req.ID, 10),
+			"TTL": strconv.FormatInt(ttl, 10),
+		})
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newFakeEtcd(users map[string]string) *fakeEtcd {
+	return &fakeEtcd{kvs: registry, leases: leases, users: users, tokens: map[string]bool{}}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// grpcError writes an error the way the gateway translates gRPC errors.
+func grpcError(w http.ResponseWriter, status int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{"error": msg, "code": 3, "message": msg})
+}
+
+func TestAddrs(t *testing.T) {
+	srv := httptest.NewServer(newFakeEtcd(nil))
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"raw values",
+			map[string]string{"prefix": "/raw/"},
+			[]string{"10.0.0.2:8301", "10.0.0.1:8301", "10.0.0.4:8301", "10.0.0.5:8301"},
+			"",
+		},
+		{
+			"ignore expired leases",
+			map[string]string{"prefix": "/raw/", "ignore_expired": "true"},
+			[]string{"10.0.0.2:8301", "10.0.0.1:8301", "10.0.0.5:8301"},
+			"",
+		},
+		{
+			"prefix without separator",
+			map[string]string{"prefix": "/raw"},
+			[]string{"10.0.0.2:8301", "10.0.0.1:8301", "10.0.0.4:8301", "10.0.0.5:8301", "10.9.9.9:8301"},
+			"",
+		},
+		{
+			"json field",
+			map[string]string{"prefix": "/json/", "value_field": "addr"},
+			[]string{"10.0.1.1:8301"},
+			"",
+		},
+		{
+			"nested json field",
+			map[string]string{"prefix": "/json/", "value_field": "net.addr"},
+			[]string{"192.168.0.1", "192.168.0.4"},
+			"",
+		},
+		{
+			"key suffix",
+			map[string]string{"prefix": "/keys/", "source": "key"},
+			[]string{"10.0.2.1:8301", "10.0.2.2:8301", "nested/10.0.2.3"},
+			"",
+		},
+		{
+			"no keys",
+			map[string]string{"prefix": "/missing/"},
+			nil,
+			"",
+		},
+		{
+			"missing prefix",
+			map[string]string{},
+			nil,
+			"prefix is required",
+		},
+		{
+			"invalid source",
+			map[string]string{"prefix": "/raw/", "source": "lease"},
+			nil,
+			`invalid source "lease"`,
+		},
+		{
+			"value_field with key source",
+			map[string]string{"prefix": "/keys/", "source": "key", "value_field": "addr"},
+			nil,
+			"value_field requires source",
+		},
+		{
+			"invalid endpoint scheme",
+			map[string]string{"prefix": "/raw/", "endpoints": "unix:///run/etcd.sock"},
+			nil,
+			"scheme must be http or https",
+		},
+	}
+
+	p := &etcd.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "etcd", "endpoints": srv.URL}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAddrsAuth(t *testing.T) {
+	fake := newFakeEtcd(map[string]string{"root": "s3cr3t"})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	t.Setenv("ETCDCTL_USER", "")
+	t.Setenv("ETCDCTL_PASSWORD", "")
+
+	p := &etcd.Provider{}
+	p.SetUserAgent("go-discover-test")
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	args := discover.Config{"provider": "etcd", "endpoints": srv.URL, "prefix": "/json/", "value_field": "addr"}
+
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "user name is empty") {
+		t.Fatalf("got error %v, want unauthenticated", err)
+	}
+
+	args["username"], args["password"] = "root", "wrong"
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "authentication failed") {
+		t.Fatalf("got error %v, want authentication failure", err)
+	}
+
+	args["password"] = "s3cr3t"
+	addrs, err := p.Addrs(args, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.1.1:8301"}) {
+		t.Fatalf("bad: %#v", addrs)
+	}
+	if fake.agent != "go-discover-test" {
+		t.Fatalf("got User-Agent %q", fake.agent)
+	}
+
+	// etcdctl accepts the password as part of the user.
+	delete(args, "username")
+	delete(args, "password")
+	t.Setenv("ETCDCTL_USER", "root:s3cr3t")
+	if _, err := p.Addrs(args, l); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddrsEndpoints(t *testing.T) {
+	srv := httptest.NewServer(newFakeEtcd(nil))
+	defer srv.Close()
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+
+	p := &etcd.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	args := discover.Config{"provider": "etcd", "prefix": "/json/", "value_field": "addr"}
+
+	args["endpoints"] = down.URL + ", " + srv.URL
+	addrs, err := p.Addrs(args, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.1.1:8301"}) {
+		t.Fatalf("bad: %#v", addrs)
+	}
+
+	// The scheme defaults to http.
+	t.Setenv("ETCDCTL_ENDPOINTS", strings.TrimPrefix(srv.URL, "http://"))
+	delete(args, "endpoints")
+	if _, err := p.Addrs(args, l); err != nil {
+		t.Fatal(err)
+	}
+
+	args["endpoints"] = down.URL
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("got error %v, want connection refused", err)
+	}
+}
+
+func TestAddrsTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t)
+	serverCert := ca.Issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := ca.Issue(t, "client", x509.ExtKeyUsageClientAuth)
+	caFile := ca.WriteCert(t, dir, "ca.pem")
+	certFile, keyFile := clientCert.Write(t, dir, "client")
+
+	srv := httptest.NewUnstartedServer(newFakeEtcd(nil))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLS},
+		ClientCAs:    ca.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	hostPort := strings.TrimPrefix(srv.URL, "https://")
+
+	cases := []struct {
+		Name string
+		Args map[string]string
+		Err  string
+	}{
+		{"unknown authority", map[string]string{"endpoints": srv.URL}, "certificate"},
+		{"no client certificate", map[string]string{"endpoints": srv.URL, "ca_file": caFile}, "certificate"},
+		{"client certificate", map[string]string{"endpoints": srv.URL, "ca_file": caFile, "cert_file": certFile, "key_file": keyFile}, ""},
+		{"https by default", map[string]string{"endpoints": hostPort, "ca_file": caFile, "cert_file": certFile, "key_file": keyFile}, ""},
+		{"insecure", map[string]string{"endpoints": srv.URL, "insecure_ssl": "true", "cert_file": certFile, "key_file": keyFile}, ""},
+		{"key without certificate", map[string]string{"endpoints": srv.URL, "key_file": keyFile}, "must be set together"},
+	}
+
+	p := &etcd.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "etcd", "prefix": "/json/", "value_field": "addr"}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, []string{"10.0.1.1:8301"}) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(newFakeEtcd(nil))
+	defer srv.Close()
+
+	// The server only notices that the client went away once the
+	// request body has been read.
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	discovertest.RunConformance(t, &etcd.Provider{}, discovertest.Fixtures{
+		Name: "etcd",
+		Args: discover.Config{
+			"provider":       "etcd",
+			"endpoints":      srv.URL,
+			"prefix":         "/raw/",
+			"ignore_expired": "true",
+		},
+		Want: []string{"10.0.0.2:8301", "10.0.0.1:8301", "10.0.0.5:8301"},
+		Invalid: discover.Config{
+			"provider":  "etcd",
+			"endpoints": srv.URL,
+		},
+		Timeout: discover.Config{
+			"provider":  "etcd",
+			"endpoints": hang.URL,
+			"prefix":    "/raw/",
+			"timeout":   "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider":  "etcd",
+			"endpoints": hang.URL + "," + srv.URL,
+			"prefix":    "/raw/",
+			"timeout":   "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
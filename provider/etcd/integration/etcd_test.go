@@ -0,0 +1,158 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package integration runs the etcd provider against an embedded etcd
+// server. It is a separate module so that the etcd server and its
+// dependencies stay out of the go-discover module.
+package integration_test
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/url"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-discover/provider/etcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+	"go.etcd.io/etcd/server/v3/embed"
+)
+
+// localURL returns the URL of a free local port. etcd can't listen on
+// port 0 since its JSON gateway dials the configured client URL.
+func localURL(t *testing.T) url.URL {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return url.URL{Scheme: "http", Host: ln.Addr().String()}
+}
+
+// startEtcd starts a single member etcd server listening on a random
+// local port and returns its client endpoint.
+func startEtcd(t *testing.T) string {
+	t.Helper()
+
+	cfg := embed.NewConfig()
+	cfg.Dir = t.TempDir()
+	cfg.Logger = "zap"
+	cfg.LogLevel = "error"
+	peer, client := localURL(t), localURL(t)
+	cfg.ListenPeerUrls = []url.URL{peer}
+	cfg.AdvertisePeerUrls = []url.URL{peer}
+	cfg.ListenClientUrls = []url.URL{client}
+	cfg.AdvertiseClientUrls = []url.URL{client}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	e, err := embed.StartEtcd(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(e.Close)
+
+	select {
+	case <-e.Server.ReadyNotify():
+	case <-time.After(30 * time.Second):
+		e.Server.Stop()
+		t.Fatal("etcd did not start")
+	}
+	return e.Clients[0].Addr().String()
+}
+
+func TestAddrs(t *testing.T) {
+	endpoint := startEtcd(t)
+
+	cli, err := clientv3.New(clientv3.Config{
+		Endpoints:   []string{endpoint},
+		DialTimeout: 5 * time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	lease, err := cli.Grant(ctx, 60)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kvs := []struct {
+		Key, Value string
+		Lease      clientv3.LeaseID
+	}{
+		{"/raw/n1", "10.0.0.2:8301", 0},
+		{"/raw/n2", "10.0.0.1:8301", lease.ID},
+		{"/raw/n3", "", 0},
+		{"/rawx/n1", "10.9.9.9:8301", 0},
+		{"/json/n1", `{"addr": "10.0.1.1:8301", "net": {"addr": "192.168.0.1"}}`, 0},
+		{"/json/n2", `{"name": "n2"}`, 0},
+		{"/keys/10.0.2.2:8301", "n2", 0},
+		{"/keys/10.0.2.1:8301", "n1", 0},
+	}
+	for _, kv := range kvs {
+		var opts []clientv3.OpOption
+		if kv.Lease != 0 {
+			opts = append(opts, clientv3.WithLease(kv.Lease))
+		}
+		if _, err := cli.Put(ctx, kv.Key, kv.Value, opts...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+	}{
+		{
+			"raw values",
+			map[string]string{"prefix": "/raw/"},
+			[]string{"10.0.0.2:8301", "10.0.0.1:8301"},
+		},
+		{
+			"ignore expired keeps live leases",
+			map[string]string{"prefix": "/raw/", "ignore_expired": "true"},
+			[]string{"10.0.0.2:8301", "10.0.0.1:8301"},
+		},
+		{
+			"json value field",
+			map[string]string{"prefix": "/json/", "value_field": "net.addr"},
+			[]string{"192.168.0.1"},
+		},
+		{
+			"keys",
+			map[string]string{"prefix": "/keys/", "source": "key"},
+			[]string{"10.0.2.1:8301", "10.0.2.2:8301"},
+		},
+		{
+			"no keys",
+			map[string]string{"prefix": "/missing/"},
+			nil,
+		},
+	}
+
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := map[string]string{"provider": "etcd", "endpoints": endpoint}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := (&etcd.Provider{}).Addrs(args, l)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("got %v want %v", addrs, tt.Expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package exec provides node discovery through an external command.
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/go-discover/internal/addrlist"
+)
+
+type Provider struct{}
+
+func (p *Provider) Help() string {
+	return `Exec:
+
+    provider:   "exec"
+    command:    The command to run, followed by its arguments separated
+                by spaces. The command is not run through a shell.
+    timeout:    The time after which the command is killed. Default "10s".
+
+    The command must exit with status 0 and print the addresses on
+    stdout in one of the formats accepted by the "file" provider: a
+    JSON array of strings or one address per line, where '#' starts a
+    comment. Empty output means that no addresses were found. Anything
+    written to stderr is logged and a non-zero exit status fails the
+    lookup.
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "exec" {
+		return nil, fmt.Errorf("discover-exec: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	command := strings.Fields(args["command"])
+	if len(command) == 0 {
+		return nil, fmt.Errorf("discover-exec: command is required")
+	}
+
+	timeout := 10 * time.Second
+	if v := args["timeout"]; v != "" {
+		var err error
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("discover-exec: invalid timeout: %w", err)
+		}
+	}
+	l.Printf("[DEBUG] discover-exec: Running %q with timeout=%s", command, timeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := osexec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Children of the command may keep the output pipes open after it
+	// has been killed. Don't wait for them.
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if s := strings.TrimSpace(stderr.String()); s != "" {
+		l.Printf("[DEBUG] discover-exec: %s", s)
+	}
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("discover-exec: command timed out after %s: %w", timeout, ctx.Err())
+	}
+	if err != nil {
+		var exitErr *osexec.ExitError
+		if errors.As(err, &exitErr) && stderr.Len() > 0 {
+			return nil, fmt.Errorf("discover-exec: command failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+		return nil, fmt.Errorf("discover-exec: command failed: %w", err)
+	}
+
+	addrs, err := addrlist.Parse(stdout.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("discover-exec: %w", err)
+	}
+
+	l.Printf("[DEBUG] discover-exec: Found addresses: %v", addrs)
+	return addrs, nil
+}
@@ -0,0 +1,131 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package exec_test
+
+import (
+	"context"
+	"log"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/exec"
+)
+
+var _ discover.Provider = (*exec.Provider)(nil)
+
+// script writes an executable shell script and returns its path.
+func script(t *testing.T, body string) string {
+	t.Helper()
+	if _, err := osexec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	path := filepath.Join(t.TempDir(), "discover.sh")
+	if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestAddrs(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Script   string
+		Args     string
+		Expected []string
+		Err      string
+	}{
+		{
+			"lines",
+			`printf '# servers\n10.0.0.1\n10.0.0.2:8301\n'`,
+			"",
+			[]string{"10.0.0.1", "10.0.0.2:8301"},
+			"",
+		},
+		{
+			"json",
+			`echo '["10.0.0.1", "10.0.0.2"]'`,
+			"",
+			[]string{"10.0.0.1", "10.0.0.2"},
+			"",
+		},
+		{
+			"arguments",
+			`echo "$1" "$2"`,
+			" 10.0.0.3   10.0.0.4",
+			[]string{"10.0.0.3", "10.0.0.4"},
+			"",
+		},
+		{
+			"no output",
+			`echo "nothing found" >&2`,
+			"",
+			nil,
+			"",
+		},
+		{
+			"non-zero exit",
+			`echo 10.0.0.1; echo "access denied" >&2; exit 3`,
+			"",
+			nil,
+			"exit status 3: access denied",
+		},
+	}
+
+	p := &exec.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider": "exec",
+				"command":  script(t, tt.Script) + tt.Args,
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	discovertest.RunConformance(t, &exec.Provider{}, discovertest.Fixtures{
+		Name: "exec",
+		Args: discover.Config{
+			"provider": "exec",
+			"command":  script(t, `echo 10.0.0.2 10.0.0.1:8301`),
+		},
+		Want: []string{"10.0.0.2", "10.0.0.1:8301"},
+		Invalid: discover.Config{
+			"provider": "exec",
+			"command":  "true",
+			"timeout":  "soon",
+		},
+		Timeout: discover.Config{
+			"provider": "exec",
+			"command":  script(t, `exec sleep 10`),
+			"timeout":  "100ms",
+		},
+		TimeoutAfter: 3 * time.Second,
+		Err: discover.Config{
+			"provider": "exec",
+			"command":  script(t, `exec sleep 10`),
+			"timeout":  "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
@@ -0,0 +1,63 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package file provides node discovery from addresses listed in a file.
+package file
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/hashicorp/go-discover/internal/addrlist"
+)
+
+type Provider struct{}
+
+func (p *Provider) Help() string {
+	return `File:
+
+    provider:   "file"
+    path:       Path of the file listing the addresses.
+
+    The file is read on every lookup so it can be updated while the
+    process is running. It either contains a JSON array of strings or
+    one address per line. In the line format addresses may also be
+    separated by spaces, empty lines are ignored and everything after
+    a '#' is a comment:
+
+        # consul servers
+        10.0.0.1
+        10.0.0.2:8301
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "file" {
+		return nil, fmt.Errorf("discover-file: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	path := args["path"]
+	if path == "" {
+		return nil, fmt.Errorf("discover-file: path is required")
+	}
+	l.Printf("[DEBUG] discover-file: Reading addresses from %s", path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("discover-file: %w", err)
+	}
+
+	addrs, err := addrlist.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("discover-file: %s: %w", path, err)
+	}
+
+	l.Printf("[DEBUG] discover-file: Found addresses: %v", addrs)
+	return addrs, nil
+}
@@ -0,0 +1,69 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package file_test
+
+import (
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/file"
+)
+
+var _ discover.Provider = (*file.Provider)(nil)
+
+func TestAddrsRereadsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "addrs")
+	args := discover.Config{"provider": "file", "path": path}
+	p := &file.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+
+	for _, want := range [][]string{{"10.0.0.1"}, {"10.0.0.1", "10.0.0.2"}} {
+		data := ""
+		for _, addr := range want {
+			data += addr + "\n"
+		}
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		addrs, err := p.Addrs(args, l)
+		if err != nil {
+			t.Fatalf("err: %s", err)
+		}
+		if !reflect.DeepEqual(addrs, want) {
+			t.Fatalf("got %v want %v", addrs, want)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "addrs.json")
+	if err := os.WriteFile(path, []byte(`["10.0.0.2", "10.0.0.1:8301"]`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	discovertest.RunConformance(t, &file.Provider{}, discovertest.Fixtures{
+		Name: "file",
+		Args: discover.Config{
+			"provider": "file",
+			"path":     path,
+		},
+		Want: []string{"10.0.0.2", "10.0.0.1:8301"},
+		Invalid: discover.Config{
+			"provider": "file",
+		},
+		Err: discover.Config{
+			"provider": "file",
+			"path":     filepath.Join(dir, "missing"),
+		},
+		ErrIs: fs.ErrNotExist,
+	})
+}
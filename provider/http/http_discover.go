@@ -0,0 +1,261 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package http provides node discovery from a JSON HTTP endpoint.
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	nethttp "net/http"
+	"os"
+	"strconv"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+	"github.com/jmespath/go-jmespath"
+)
+
+// maxBodySize limits how much of a response is read.
+const maxBodySize = 10 << 20
+
+type Provider struct {
+	userAgent string
+}
+
+func (p *Provider) SetUserAgent(s string) {
+	p.userAgent = s
+}
+
+func (p *Provider) Help() string {
+	return `HTTP:
+
+    provider:        "http"
+    url:             The URL which returns the JSON document listing the nodes.
+    addr_path:       JMESPath expression selecting the addresses, e.g. "nodes[].ip".
+    port_path:       Optional JMESPath expression selecting the ports, e.g. "nodes[].port".
+    token:           Bearer token sent in the Authorization header.
+    username:        Username for basic authentication.
+    password:        Password for basic authentication.
+    ca_file:         PEM encoded CA bundle used to verify the server certificate.
+    cert_file:       PEM encoded client certificate.
+    key_file:        PEM encoded key of the client certificate.
+    insecure_ssl:    Whether or not to skip TLS certificate validation.
+    timeout:         Timeout for the request. Default "10s".
+
+    The endpoint is queried with a GET request and must answer with a
+    2xx status code. addr_path must evaluate to a string or a list of
+    strings. port_path, if set, must evaluate either to a single port
+    which is used for all addresses or to a list with one port per
+    address. Ports may be numbers or strings. If both token and username
+    are set, the token is used.
+
+    Variables can also be provided by environment variables:
+    export DISCOVER_HTTP_TOKEN for token
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "http" {
+		return nil, fmt.Errorf("discover-http: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	url := args["url"]
+	if url == "" {
+		return nil, fmt.Errorf("discover-http: url is required")
+	}
+
+	addrPath, err := compile(args, "addr_path")
+	if err != nil {
+		return nil, err
+	}
+	if addrPath == nil {
+		return nil, fmt.Errorf("discover-http: addr_path is required")
+	}
+	portPath, err := compile(args, "port_path")
+	if err != nil {
+		return nil, err
+	}
+
+	timeout, err := httpclient.Timeout(args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: %w", err)
+	}
+
+	tlsConfig, err := httpclient.TLSConfig(args, httpclient.TLSEnv{})
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := nethttp.NewRequestWithContext(ctx, nethttp.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+	token := argsOrEnv(args, "token", "DISCOVER_HTTP_TOKEN")
+	switch {
+	case token != "":
+		l.Printf("[DEBUG] discover-http: Using bearer token authentication")
+		req.Header.Set("Authorization", "Bearer "+token)
+	case args["username"] != "":
+		l.Printf("[DEBUG] discover-http: Using basic authentication as %s", args["username"])
+		req.SetBasicAuth(args["username"], args["password"])
+	}
+
+	client := httpclient.New(tlsConfig)
+	defer client.CloseIdleConnections()
+
+	l.Printf("[DEBUG] discover-http: GET %s", url)
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, fmt.Errorf("discover-http: GET %s: %w", url, err)
+	}
+
+	var doc interface{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxBodySize)).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("discover-http: invalid JSON response: %w", err)
+	}
+
+	addrs, err := extract(doc, addrPath, portPath)
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: %w", err)
+	}
+
+	l.Printf("[DEBUG] discover-http: Found addresses: %v", addrs)
+	return addrs, nil
+}
+
+// compile compiles the JMESPath expression in args[key], if any.
+func compile(args map[string]string, key string) (*jmespath.JMESPath, error) {
+	if args[key] == "" {
+		return nil, nil
+	}
+	expr, err := jmespath.Compile(args[key])
+	if err != nil {
+		return nil, fmt.Errorf("discover-http: invalid %s: %w", key, err)
+	}
+	return expr, nil
+}
+
+// extract evaluates the address and port expressions against doc and
+// joins the results.
+func extract(doc interface{}, addrPath, portPath *jmespath.JMESPath) ([]string, error) {
+	v, err := addrPath.Search(doc)
+	if err != nil {
+		return nil, fmt.Errorf("addr_path: %w", err)
+	}
+	hosts, err := list(v)
+	if err != nil {
+		return nil, fmt.Errorf("addr_path: %w", err)
+	}
+	if len(hosts) == 0 {
+		return nil, nil
+	}
+
+	ports := make([]string, len(hosts))
+	if portPath != nil {
+		v, err := portPath.Search(doc)
+		if err != nil {
+			return nil, fmt.Errorf("port_path: %w", err)
+		}
+		switch v := v.(type) {
+		case []interface{}:
+			if len(v) != len(hosts) {
+				return nil, fmt.Errorf("port_path returned %d ports for %d addresses", len(v), len(hosts))
+			}
+			for i := range v {
+				if ports[i], err = port(v[i]); err != nil {
+					return nil, fmt.Errorf("port_path: %w", err)
+				}
+			}
+		default:
+			p, err := port(v)
+			if err != nil {
+				return nil, fmt.Errorf("port_path: %w", err)
+			}
+			for i := range ports {
+				ports[i] = p
+			}
+		}
+	}
+
+	var addrs []string
+	for i, host := range hosts {
+		if host == "" {
+			continue
+		}
+		if ports[i] != "" {
+			host = net.JoinHostPort(host, ports[i])
+		}
+		addrs = append(addrs, host)
+	}
+	return addrs, nil
+}
+
+// list converts the result of an address expression into a list of
+// strings.
+func list(v interface{}) ([]string, error) {
+	switch v := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		l := make([]string, 0, len(v))
+		for _, x := range v {
+			s, ok := x.(string)
+			if !ok {
+				return nil, fmt.Errorf("want string, got %T", x)
+			}
+			l = append(l, s)
+		}
+		return l, nil
+	default:
+		return nil, fmt.Errorf("want string or list of strings, got %T", v)
+	}
+}
+
+// port converts the result of a port expression into a port number.
+func port(v interface{}) (string, error) {
+	switch v := v.(type) {
+	case float64:
+		if v != float64(uint16(v)) || v == 0 {
+			return "", fmt.Errorf("invalid port %v", v)
+		}
+		return strconv.Itoa(int(v)), nil
+	case string:
+		n, err := strconv.ParseUint(v, 10, 16)
+		if err != nil || n == 0 {
+			return "", fmt.Errorf("invalid port %q", v)
+		}
+		return v, nil
+	default:
+		return "", fmt.Errorf("want port number, got %T", v)
+	}
+}
+
+// argsOrEnv allows you to pick an environmental variable for a setting if the arg is not set
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
@@ -0,0 +1,269 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package http_test
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"log"
+	nethttp "net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/internal/tlstest"
+	"github.com/hashicorp/go-discover/provider/http"
+)
+
+var _ discover.Provider = (*http.Provider)(nil)
+var _ discover.ProviderWithUserAgent = (*http.Provider)(nil)
+
+const inventory = `{
+  "nodes": [
+    {"ip": "10.0.0.1", "port": 8301, "role": "server"},
+    {"ip": "10.0.0.2", "port": "8302", "role": "server"},
+    {"ip": "10.0.0.3", "port": 8303, "role": "client"}
+  ],
+  "leader": "10.0.0.1"
+}`
+
+// jsonHandler serves body as JSON.
+func jsonHandler(body string) nethttp.HandlerFunc {
+	return func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+}
+
+func TestAddrs(t *testing.T) {
+	srv := httptest.NewServer(jsonHandler(inventory))
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"list",
+			map[string]string{"addr_path": "nodes[].ip"},
+			[]string{"10.0.0.1", "10.0.0.2", "10.0.0.3"},
+			"",
+		},
+		{
+			"filter",
+			map[string]string{"addr_path": "nodes[?role=='server'].ip"},
+			[]string{"10.0.0.1", "10.0.0.2"},
+			"",
+		},
+		{
+			"single value",
+			map[string]string{"addr_path": "leader"},
+			[]string{"10.0.0.1"},
+			"",
+		},
+		{
+			"port list",
+			map[string]string{"addr_path": "nodes[].ip", "port_path": "nodes[].port"},
+			[]string{"10.0.0.1:8301", "10.0.0.2:8302", "10.0.0.3:8303"},
+			"",
+		},
+		{
+			"single port",
+			map[string]string{"addr_path": "nodes[?role=='server'].ip", "port_path": "nodes[0].port"},
+			[]string{"10.0.0.1:8301", "10.0.0.2:8301"},
+			"",
+		},
+		{
+			"no match",
+			map[string]string{"addr_path": "nodes[?role=='none'].ip"},
+			nil,
+			"",
+		},
+		{
+			"port count mismatch",
+			map[string]string{"addr_path": "nodes[].ip", "port_path": "nodes[:2].port"},
+			nil,
+			"returned 2 ports for 3 addresses",
+		},
+		{
+			"not a string",
+			map[string]string{"addr_path": "nodes"},
+			nil,
+			"want string, got map",
+		},
+		{
+			"invalid expression",
+			map[string]string{"addr_path": "nodes[.ip"},
+			nil,
+			"invalid addr_path",
+		},
+	}
+
+	p := &http.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "http", "url": srv.URL}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAddrsAuth(t *testing.T) {
+	var auth, agent string
+	srv := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		auth, agent = r.Header.Get("Authorization"), r.Header.Get("User-Agent")
+		if auth == "" {
+			nethttp.Error(w, "unauthorized", nethttp.StatusUnauthorized)
+			return
+		}
+		jsonHandler(inventory)(w, r)
+	}))
+	defer srv.Close()
+
+	p := &http.Provider{}
+	p.SetUserAgent("go-discover-test")
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	args := discover.Config{"provider": "http", "url": srv.URL, "addr_path": "leader"}
+
+	t.Setenv("DISCOVER_HTTP_TOKEN", "")
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "401 Unauthorized") {
+		t.Fatalf("got error %v, want unauthorized", err)
+	}
+
+	args["username"], args["password"] = "user", "pass"
+	if _, err := p.Addrs(args, l); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Basic dXNlcjpwYXNz"; auth != want {
+		t.Fatalf("got Authorization %q want %q", auth, want)
+	}
+	if agent != "go-discover-test" {
+		t.Fatalf("got User-Agent %q", agent)
+	}
+
+	t.Setenv("DISCOVER_HTTP_TOKEN", "s3cr3t")
+	if _, err := p.Addrs(args, l); err != nil {
+		t.Fatal(err)
+	}
+	if want := "Bearer s3cr3t"; auth != want {
+		t.Fatalf("got Authorization %q want %q", auth, want)
+	}
+}
+
+func TestAddrsTLS(t *testing.T) {
+	dir := t.TempDir()
+	ca := tlstest.NewCA(t)
+	serverCert := ca.Issue(t, "server", x509.ExtKeyUsageServerAuth)
+	clientCert := ca.Issue(t, "client", x509.ExtKeyUsageClientAuth)
+	caFile := ca.WriteCert(t, dir, "ca.pem")
+	certFile, keyFile := clientCert.Write(t, dir, "client")
+
+	srv := httptest.NewUnstartedServer(jsonHandler(inventory))
+	srv.TLS = &tls.Config{
+		Certificates: []tls.Certificate{serverCert.TLS},
+		ClientCAs:    ca.Pool,
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	}
+	srv.StartTLS()
+	defer srv.Close()
+
+	cases := []struct {
+		Name string
+		Args map[string]string
+		Err  string
+	}{
+		{"unknown authority", map[string]string{}, "certificate"},
+		{"no client certificate", map[string]string{"ca_file": caFile}, "certificate"},
+		{"client certificate", map[string]string{"ca_file": caFile, "cert_file": certFile, "key_file": keyFile}, ""},
+		{"insecure", map[string]string{"insecure_ssl": "true", "cert_file": certFile, "key_file": keyFile}, ""},
+		{"key without certificate", map[string]string{"ca_file": caFile, "key_file": keyFile}, "must be set together"},
+		{"missing ca_file", map[string]string{"ca_file": filepath.Join(dir, "missing.pem")}, "reading ca_file"},
+	}
+
+	p := &http.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "http", "url": srv.URL, "addr_path": "leader"}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, []string{"10.0.0.1"}) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(jsonHandler(inventory))
+	defer srv.Close()
+
+	hang := httptest.NewServer(nethttp.HandlerFunc(func(w nethttp.ResponseWriter, r *nethttp.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	discovertest.RunConformance(t, &http.Provider{}, discovertest.Fixtures{
+		Name: "http",
+		Args: discover.Config{
+			"provider":  "http",
+			"url":       srv.URL,
+			"addr_path": "nodes[].ip",
+			"port_path": "nodes[].port",
+		},
+		Want: []string{"10.0.0.1:8301", "10.0.0.2:8302", "10.0.0.3:8303"},
+		Invalid: discover.Config{
+			"provider": "http",
+			"url":      srv.URL,
+		},
+		Timeout: discover.Config{
+			"provider":  "http",
+			"url":       hang.URL,
+			"addr_path": "nodes[].ip",
+			"timeout":   "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider":  "http",
+			"url":       hang.URL,
+			"addr_path": "nodes[].ip",
+			"timeout":   "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
@@ -1,19 +1,24 @@
 // Copyright IBM Corp. 2017, 2025
 // SPDX-License-Identifier: MPL-2.0
 
-// Package k8s provides pod discovery for Kubernetes.
+// Package k8s provides pod, endpoint and node discovery for Kubernetes.
 package k8s
 
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
+	"net"
+	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 
 	"github.com/hashicorp/go-multierror"
 	"github.com/mitchellh/go-homedir"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
@@ -28,6 +33,10 @@ const (
 	// AnnotationKeyPort is the annotation name of the field that specifies
 	// the port name or number to append to the address.
 	AnnotationKeyPort = "consul.hashicorp.com/auto-join-port"
+
+	// serviceAccountNamespaceFile contains the namespace of the pod when
+	// running in-cluster.
+	serviceAccountNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
 )
 
 type Provider struct{}
@@ -36,11 +45,25 @@ func (p *Provider) Help() string {
 	return `Kubernetes (K8S):
 
     provider:         "k8s"
+    mode:             "pods", "endpointslices" or "nodes" (defaults to "pods").
     kubeconfig:       Path to the kubeconfig file.
-    namespace:        Namespace to search for pods (defaults to "default").
-    label_selector:   Label selector value to filter pods.
-    field_selector:   Field selector value to filter pods.
+    namespace:        Namespace to search for pods or endpoint slices (defaults to the
+                      namespace of the pod when running in-cluster, "default" otherwise).
+    namespaces:       Comma separated list of namespaces to search instead of "namespace".
+    all_namespaces:   "true" to search all namespaces.
+    label_selector:   Label selector value to filter pods, endpoint slices or nodes.
+    field_selector:   Field selector value to filter pods, endpoint slices or nodes.
     host_network:     "true" if pod host IP and ports should be used.
+    port_annotation:  Name of the pod annotation with the port to append to the address
+                      (defaults to "consul.hashicorp.com/auto-join-port").
+    ip_family:        "ipv4" or "ipv6" to select the address of that family on dual-stack
+                      clusters. The primary address of pods and nodes and the IPv4 endpoints
+                      of dual-stack services are used by default.
+    service:          Name of the service whose endpoint slices are used (mode "endpointslices").
+    port_name:        Name of the endpoint slice port to append to the address
+                      (mode "endpointslices"). No port is used by default.
+    address_type:     "InternalIP" or "ExternalIP" address of the nodes (mode "nodes",
+                      defaults to "InternalIP").
 
     The kubeconfig file value will be searched in the following locations:
 
@@ -50,14 +73,28 @@ func (p *Provider) Help() string {
 
     By default, the Pod IP is used to join. The "host_network" option may
     be set to use the Host IP. No port is used by default. Pods may set
-    an annotation 'hashicorp/consul-auto-join-port' to a named port or
-    an integer value. If the value matches a named port, that port will
-    be used to join.
+    the annotation named by "port_annotation" to a named port or an
+    integer value. If the value matches a named port, that port will be
+    used to join.
+
+    With "ip_family" the pod IPs, or the host IPs with "host_network",
+    are searched for an address of the family and pods without one are
+    ignored.
 
     Note that if "host_network" is set to true, then only pods that have
     a HostIP available will be selected. If a port annotation exists, then
     the port must be exposed via a HostPort as well, otherwise the pod will
     be ignored.
+
+    In "endpointslices" mode the endpoints of the service are used as
+    they are published by Kubernetes, so readiness gates and terminating
+    pods are taken into account. Endpoints which are not ready or are
+    terminating are ignored. This mode requires permission to list
+    "endpointslices" in the "discovery.k8s.io" API group.
+
+    In "nodes" mode the addresses of all ready nodes are used. This mode
+    requires permission to list "nodes" at the cluster scope. Nodes are
+    not namespaced, so the namespace options are ignored.
 `
 }
 
@@ -66,6 +103,19 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 		return nil, fmt.Errorf("discover-k8s: invalid provider %s", args["provider"])
 	}
 
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	// Validate the mode before loading the configuration which might
+	// be slow to fail.
+	if _, err := mode(args); err != nil {
+		return nil, err
+	}
+	if _, err := namespaces(args); err != nil {
+		return nil, err
+	}
+
 	// Get the configuration. This can come from multiple sources. We first
 	// try kubeconfig it is set directly, then we fall back to in-cluster
 	// auth. Finally, we try the default kubeconfig path.
@@ -95,6 +145,18 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 			return nil, multierror.Append(configErr, fmt.Errorf(
 				"discover-k8s: error loading in-cluster config: %s", err))
 		}
+
+		// In-cluster we default to the namespace of our own pod rather
+		// than "default".
+		if args["namespace"] == "" && args["namespaces"] == "" && args["all_namespaces"] == "" {
+			if b, err := os.ReadFile(serviceAccountNamespaceFile); err == nil {
+				if ns := strings.TrimSpace(string(b)); ns != "" {
+					l.Printf("[DEBUG] discover-k8s: using service account namespace %q", ns)
+					args = copyArgs(args)
+					args["namespace"] = ns
+				}
+			}
+		}
 	}
 
 	// Initialize the clientset
@@ -103,23 +165,152 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 		return nil, fmt.Errorf("discover-k8s: error initializing k8s client: %s", err)
 	}
 
-	namespace := args["namespace"]
-	if namespace == "" {
-		namespace = "default"
+	return ClientsetAddrs(clientset, args, l)
+}
+
+// ClientsetAddrs lists the pods, endpoint slices or nodes selected by args
+// with the given clientset and extracts their addresses.
+//
+// This is a separate method so that we can unit test this with a fake
+// clientset. It shouldn't generally be called externally.
+func ClientsetAddrs(clientset kubernetes.Interface, args map[string]string, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	mode, err := mode(args)
+	if err != nil {
+		return nil, err
 	}
 
-	// List all the pods based on the filters we requested
-	pods, err := clientset.CoreV1().Pods(namespace).List(
-		context.Background(),
-		metav1.ListOptions{
-			LabelSelector: args["label_selector"],
-			FieldSelector: args["field_selector"],
-		})
+	namespaces, err := namespaces(args)
 	if err != nil {
-		return nil, fmt.Errorf("discover-k8s: error listing pods: %s", err)
+		return nil, err
+	}
+	opts := metav1.ListOptions{
+		LabelSelector: args["label_selector"],
+		FieldSelector: args["field_selector"],
+	}
+
+	switch mode {
+	case "endpointslices":
+		// Endpoint slices are linked to their service by a label.
+		selector := discoveryv1.LabelServiceName + "=" + args["service"]
+		if opts.LabelSelector != "" {
+			selector += "," + opts.LabelSelector
+		}
+		opts.LabelSelector = selector
+
+		slices := &discoveryv1.EndpointSliceList{}
+		for _, namespace := range namespaces {
+			list, err := clientset.DiscoveryV1().EndpointSlices(namespace).List(context.Background(), opts)
+			if err != nil {
+				return nil, fmt.Errorf("discover-k8s: error listing endpoint slices: %s", err)
+			}
+			slices.Items = append(slices.Items, list.Items...)
+		}
+		return EndpointSliceAddrs(slices, args, l)
+
+	case "nodes":
+		nodes, err := clientset.CoreV1().Nodes().List(context.Background(), opts)
+		if err != nil {
+			return nil, fmt.Errorf("discover-k8s: error listing nodes: %s", err)
+		}
+		return NodeAddrs(nodes, args, l)
+
+	default:
+		// List all the pods based on the filters we requested
+		pods := &corev1.PodList{}
+		for _, namespace := range namespaces {
+			list, err := clientset.CoreV1().Pods(namespace).List(context.Background(), opts)
+			if err != nil {
+				return nil, fmt.Errorf("discover-k8s: error listing pods: %s", err)
+			}
+			pods.Items = append(pods.Items, list.Items...)
+		}
+		return PodAddrs(pods, args, l)
+	}
+}
+
+// mode returns the validated mode of args.
+func mode(args map[string]string) (string, error) {
+	switch m := args["mode"]; m {
+	case "", "pods":
+		return "pods", nil
+	case "endpointslices":
+		if args["service"] == "" {
+			return "", fmt.Errorf("discover-k8s: service is required for mode %q", m)
+		}
+		return m, nil
+	case "nodes":
+		return m, nil
+	default:
+		return "", fmt.Errorf("discover-k8s: invalid mode %q, must be \"pods\", \"endpointslices\" or \"nodes\"", m)
 	}
+}
+
+// namespaces returns the namespaces to search. All namespaces are
+// represented by metav1.NamespaceAll.
+func namespaces(args map[string]string) ([]string, error) {
+	if v := args["all_namespaces"]; v != "" {
+		all, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("discover-k8s: all_namespaces must be boolean value: %s", err)
+		}
+		if all {
+			if args["namespace"] != "" || args["namespaces"] != "" {
+				return nil, fmt.Errorf("discover-k8s: all_namespaces can't be combined with namespace or namespaces")
+			}
+			return []string{metav1.NamespaceAll}, nil
+		}
+	}
+
+	var list []string
+	for _, ns := range strings.Split(args["namespaces"], ",") {
+		if ns = strings.TrimSpace(ns); ns != "" {
+			list = append(list, ns)
+		}
+	}
+	if len(list) > 0 {
+		return list, nil
+	}
+
+	if ns := args["namespace"]; ns != "" {
+		return []string{ns}, nil
+	}
+	return []string{metav1.NamespaceDefault}, nil
+}
+
+// ipFamily returns the validated ip_family of args, "ipv4", "ipv6" or
+// empty if the primary address should be used.
+func ipFamily(args map[string]string) (string, error) {
+	switch v := strings.ToLower(args["ip_family"]); v {
+	case "", "ipv4", "ipv6":
+		return v, nil
+	default:
+		return "", fmt.Errorf("discover-k8s: invalid ip_family %q, must be \"ipv4\" or \"ipv6\"", args["ip_family"])
+	}
+}
+
+// isFamily reports whether addr is an IP address of family. Every
+// address matches the empty family.
+func isFamily(addr, family string) bool {
+	if family == "" {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	return (ip.To4() != nil) == (family == "ipv4")
+}
 
-	return PodAddrs(pods, args, l)
+func copyArgs(args map[string]string) map[string]string {
+	c := make(map[string]string, len(args)+1)
+	for k, v := range args {
+		c[k] = v
+	}
+	return c
 }
 
 // PodAddrs extracts the addresses from a list of pods.
@@ -128,6 +319,10 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 // to setup complicated K8S cluster scenarios. It shouldn't generally be
 // called externally.
 func PodAddrs(pods *corev1.PodList, args map[string]string, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
 	hostNetwork := false
 	if v := args["host_network"]; v != "" {
 		var err error
@@ -137,6 +332,16 @@ func PodAddrs(pods *corev1.PodList, args map[string]string, l *log.Logger) ([]st
 		}
 	}
 
+	family, err := ipFamily(args)
+	if err != nil {
+		return nil, err
+	}
+
+	annotation := args["port_annotation"]
+	if annotation == "" {
+		annotation = AnnotationKeyPort
+	}
+
 	var addrs []string
 PodLoop:
 	for _, pod := range pods.Items {
@@ -156,9 +361,9 @@ PodLoop:
 		}
 
 		// Get the IP address that we will join.
-		addr := pod.Status.PodIP
+		addr := podIP(&pod, family)
 		if hostNetwork {
-			addr = pod.Status.HostIP
+			addr = hostIP(&pod, family)
 		}
 		if addr == "" {
 			// This can be empty according to the API docs, so we protect that.
@@ -168,7 +373,7 @@ PodLoop:
 
 		// We only use the port if it is specified as an annotation. The
 		// annotation value can be a name or a number.
-		if v := pod.Annotations[AnnotationKeyPort]; v != "" {
+		if v := pod.Annotations[annotation]; v != "" {
 			port, err := podPort(&pod, v, hostNetwork)
 			if err != nil {
 				l.Printf("[DEBUG] discover-k8s: ignoring pod %q, error retrieving port: %s",
@@ -176,7 +381,7 @@ PodLoop:
 				continue
 			}
 
-			addr = fmt.Sprintf("%s:%d", addr, port)
+			addr = net.JoinHostPort(addr, strconv.Itoa(int(port)))
 		}
 
 		addrs = append(addrs, addr)
@@ -185,6 +390,46 @@ PodLoop:
 	return addrs, nil
 }
 
+// podIP returns the first pod IP of family, or the primary pod IP if
+// family is empty.
+func podIP(pod *corev1.Pod, family string) string {
+	if family == "" {
+		return pod.Status.PodIP
+	}
+
+	// PodIPs is empty on clusters which don't know about dual-stack yet.
+	ips := pod.Status.PodIPs
+	if len(ips) == 0 {
+		ips = []corev1.PodIP{{IP: pod.Status.PodIP}}
+	}
+	for _, ip := range ips {
+		if isFamily(ip.IP, family) {
+			return ip.IP
+		}
+	}
+	return ""
+}
+
+// hostIP returns the first host IP of family, or the primary host IP if
+// family is empty.
+func hostIP(pod *corev1.Pod, family string) string {
+	if family == "" {
+		return pod.Status.HostIP
+	}
+
+	// HostIPs is empty on clusters older than Kubernetes 1.28.
+	ips := pod.Status.HostIPs
+	if len(ips) == 0 {
+		ips = []corev1.HostIP{{IP: pod.Status.HostIP}}
+	}
+	for _, ip := range ips {
+		if isFamily(ip.IP, family) {
+			return ip.IP
+		}
+	}
+	return ""
+}
+
 // podPort extracts the proper port for the address from the given pod
 // for a non-empty annotation.
 //
@@ -213,3 +458,165 @@ func podPort(pod *corev1.Pod, annotation string, host bool) (int32, error) {
 	v, err := strconv.ParseInt(annotation, 0, 32)
 	return int32(v), err
 }
+
+// EndpointSliceAddrs extracts the addresses from a list of endpoint slices.
+//
+// This is a separate method so that we can unit test this without having
+// to setup complicated K8S cluster scenarios. It shouldn't generally be
+// called externally.
+func EndpointSliceAddrs(slices *discoveryv1.EndpointSliceList, args map[string]string, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	portName := args["port_name"]
+
+	family, err := ipFamily(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Dual-stack services have endpoint slices for both families. Without
+	// ip_family only the IPv4 slices are used so that every endpoint is
+	// returned once.
+	dualStack := false
+	if family == "" {
+		var v4, v6 bool
+		for _, slice := range slices.Items {
+			v4 = v4 || slice.AddressType == discoveryv1.AddressTypeIPv4
+			v6 = v6 || slice.AddressType == discoveryv1.AddressTypeIPv6
+		}
+		dualStack = v4 && v6
+	}
+
+	var addrs []string
+	seen := map[string]bool{}
+	for _, slice := range slices.Items {
+		// A slice only holds addresses of a single type.
+		switch {
+		case family == "ipv4" && slice.AddressType != discoveryv1.AddressTypeIPv4,
+			family == "ipv6" && slice.AddressType != discoveryv1.AddressTypeIPv6,
+			dualStack && slice.AddressType == discoveryv1.AddressTypeIPv6:
+			l.Printf("[DEBUG] discover-k8s: ignoring endpoint slice %q, address type %s",
+				slice.Name, slice.AddressType)
+			continue
+		}
+
+		var port int32
+		if portName != "" {
+			for _, p := range slice.Ports {
+				if p.Name != nil && *p.Name == portName && p.Port != nil {
+					port = *p.Port
+				}
+			}
+			if port == 0 {
+				l.Printf("[DEBUG] discover-k8s: ignoring endpoint slice %q, no port named %q",
+					slice.Name, portName)
+				continue
+			}
+		}
+
+		for _, ep := range slice.Endpoints {
+			name := endpointName(ep)
+
+			// A missing ready condition means that the state is unknown,
+			// which the API asks consumers to interpret as ready.
+			if ep.Conditions.Ready != nil && !*ep.Conditions.Ready {
+				l.Printf("[DEBUG] discover-k8s: ignoring endpoint %q, not ready", name)
+				continue
+			}
+			if ep.Conditions.Terminating != nil && *ep.Conditions.Terminating {
+				l.Printf("[DEBUG] discover-k8s: ignoring endpoint %q, terminating", name)
+				continue
+			}
+
+			// All addresses of an endpoint are fungible, so the first one
+			// is used.
+			if len(ep.Addresses) == 0 {
+				l.Printf("[DEBUG] discover-k8s: ignoring endpoint %q, no address", name)
+				continue
+			}
+			addr := ep.Addresses[0]
+			if port != 0 {
+				addr = net.JoinHostPort(addr, strconv.Itoa(int(port)))
+			}
+
+			// Endpoints may be part of more than one slice while the
+			// slices are updated.
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+
+	return addrs, nil
+}
+
+// endpointName returns the name of the pod or node backing ep for logging.
+func endpointName(ep discoveryv1.Endpoint) string {
+	switch {
+	case ep.TargetRef != nil:
+		return ep.TargetRef.Name
+	case ep.Hostname != nil:
+		return *ep.Hostname
+	case len(ep.Addresses) > 0:
+		return ep.Addresses[0]
+	}
+	return ""
+}
+
+// NodeAddrs extracts the addresses from a list of nodes.
+//
+// This is a separate method so that we can unit test this without having
+// to setup complicated K8S cluster scenarios. It shouldn't generally be
+// called externally.
+func NodeAddrs(nodes *corev1.NodeList, args map[string]string, l *log.Logger) ([]string, error) {
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	addrType := corev1.NodeInternalIP
+	switch v := corev1.NodeAddressType(args["address_type"]); v {
+	case "", corev1.NodeInternalIP:
+	case corev1.NodeExternalIP:
+		addrType = v
+	default:
+		return nil, fmt.Errorf("discover-k8s: invalid address_type %q, must be %q or %q",
+			v, corev1.NodeInternalIP, corev1.NodeExternalIP)
+	}
+
+	family, err := ipFamily(args)
+	if err != nil {
+		return nil, err
+	}
+
+	var addrs []string
+NodeLoop:
+	for _, node := range nodes.Items {
+		// Like for pods, a node without a Ready condition is accepted.
+		for _, condition := range node.Status.Conditions {
+			if condition.Type == corev1.NodeReady && condition.Status != corev1.ConditionTrue {
+				l.Printf("[DEBUG] discover-k8s: ignoring node %q, not ready state", node.Name)
+				continue NodeLoop
+			}
+		}
+
+		var addr string
+		for _, a := range node.Status.Addresses {
+			if a.Type == addrType && a.Address != "" && isFamily(a.Address, family) {
+				addr = a.Address
+				break
+			}
+		}
+		if addr == "" {
+			l.Printf("[DEBUG] discover-k8s: ignoring node %q, no %s", node.Name, addrType)
+			continue
+		}
+
+		addrs = append(addrs, addr)
+	}
+
+	return addrs, nil
+}
@@ -4,16 +4,26 @@
 package k8s_test
 
 import (
+	"encoding/json"
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
+	"sort"
+	"strings"
 	"testing"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/k8s"
 	corev1 "k8s.io/api/core/v1"
+	discoveryv1 "k8s.io/api/discovery/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
 )
 
 var _ discover.Provider = (*k8s.Provider)(nil)
@@ -305,6 +315,152 @@ func TestPodAddrs(t *testing.T) {
 			},
 			[]string{"2.3.4.5:4600"},
 		},
+
+		{
+			"Port annotation (custom name)",
+			map[string]string{"port_annotation": "example.com/serf-port"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase: corev1.PodRunning,
+						PodIP: "1.2.3.4",
+					},
+
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"example.com/serf-port": "8301",
+							k8s.AnnotationKeyPort:   "4600",
+						},
+					},
+				},
+			},
+			[]string{"1.2.3.4:8301"},
+		},
+
+		{
+			"IP family",
+			map[string]string{"ip_family": "ipv6"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase: corev1.PodRunning,
+						PodIP: "1.2.3.4",
+						PodIPs: []corev1.PodIP{
+							corev1.PodIP{IP: "1.2.3.4"},
+							corev1.PodIP{IP: "2001:db8::1"},
+						},
+					},
+
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							k8s.AnnotationKeyPort: "4600",
+						},
+					},
+				},
+
+				// Single stack
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase: corev1.PodRunning,
+						PodIP: "1.2.3.5",
+					},
+				},
+			},
+			[]string{"[2001:db8::1]:4600"},
+		},
+
+		{
+			"IP family without pod IPs",
+			map[string]string{"ip_family": "ipv4"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase: corev1.PodRunning,
+						PodIP: "1.2.3.4",
+					},
+				},
+			},
+			[]string{"1.2.3.4"},
+		},
+
+		{
+			"IP family with host network",
+			map[string]string{"host_network": "true", "ip_family": "ipv6"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase:  corev1.PodRunning,
+						PodIP:  "2001:db8::1",
+						HostIP: "2.3.4.5",
+					},
+				},
+
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase:  corev1.PodRunning,
+						PodIP:  "1.2.3.4",
+						HostIP: "2001:db8::2",
+					},
+				},
+			},
+			[]string{"2001:db8::2"},
+		},
+
+		{
+			"IP family with dual-stack host network",
+			map[string]string{"host_network": "true", "ip_family": "ipv6"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase:  corev1.PodRunning,
+						PodIP:  "1.2.3.4",
+						HostIP: "2.3.4.5",
+						HostIPs: []corev1.HostIP{
+							corev1.HostIP{IP: "2.3.4.5"},
+							corev1.HostIP{IP: "2001:db8::3"},
+						},
+					},
+
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							k8s.AnnotationKeyPort: "4600",
+						},
+					},
+				},
+
+				// Single stack host
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase:  corev1.PodRunning,
+						PodIP:  "1.2.3.5",
+						HostIP: "2.3.4.6",
+						HostIPs: []corev1.HostIP{
+							corev1.HostIP{IP: "2.3.4.6"},
+						},
+					},
+				},
+			},
+			[]string{"[2001:db8::3]:4600"},
+		},
+
+		{
+			"IP family with dual-stack host network ipv4",
+			map[string]string{"host_network": "true", "ip_family": "ipv4"},
+			[]corev1.Pod{
+				corev1.Pod{
+					Status: corev1.PodStatus{
+						Phase:  corev1.PodRunning,
+						PodIP:  "2001:db8::1",
+						HostIP: "2001:db8::3",
+						HostIPs: []corev1.HostIP{
+							corev1.HostIP{IP: "2001:db8::3"},
+							corev1.HostIP{IP: "2.3.4.5"},
+						},
+					},
+				},
+			},
+			[]string{"2.3.4.5"},
+		},
 	}
 
 	for _, tt := range cases {
@@ -321,3 +477,545 @@ func TestPodAddrs(t *testing.T) {
 		})
 	}
 }
+
+func TestEndpointSliceAddrs(t *testing.T) {
+	ready, notReady := true, false
+	serf, http := "serf-lan", "http"
+	serfPort, httpPort := int32(8301), int32(8500)
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Slices   []discoveryv1.EndpointSlice
+		Expected []string
+	}{
+		{
+			"Simple endpoints",
+			nil,
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.5", "1.2.3.6"}},
+					},
+				},
+			},
+			[]string{"1.2.3.4", "1.2.3.5"},
+		},
+
+		{
+			"Only ready and not terminating endpoints",
+			nil,
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{
+							Addresses:  []string{"ready"},
+							Conditions: discoveryv1.EndpointConditions{Ready: &ready},
+						},
+						discoveryv1.Endpoint{
+							Addresses:  []string{"not-ready"},
+							Conditions: discoveryv1.EndpointConditions{Ready: &notReady},
+						},
+						discoveryv1.Endpoint{
+							Addresses:  []string{"terminating"},
+							Conditions: discoveryv1.EndpointConditions{Terminating: &ready},
+						},
+						discoveryv1.Endpoint{
+							Addresses: []string{},
+						},
+					},
+				},
+			},
+			[]string{"ready"},
+		},
+
+		{
+			"Named port",
+			map[string]string{"port_name": "serf-lan"},
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					Ports: []discoveryv1.EndpointPort{
+						discoveryv1.EndpointPort{Name: &http, Port: &httpPort},
+						discoveryv1.EndpointPort{Name: &serf, Port: &serfPort},
+					},
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+					},
+				},
+
+				// No such port
+				discoveryv1.EndpointSlice{
+					Ports: []discoveryv1.EndpointPort{
+						discoveryv1.EndpointPort{Name: &http, Port: &httpPort},
+					},
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.5"}},
+					},
+				},
+			},
+			[]string{"1.2.3.4:8301"},
+		},
+
+		{
+			"Endpoints in more than one slice",
+			nil,
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+					},
+				},
+				discoveryv1.EndpointSlice{
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+						discoveryv1.Endpoint{Addresses: []string{"::1"}},
+					},
+				},
+			},
+			[]string{"1.2.3.4", "::1"},
+		},
+
+		{
+			"IPv6 with port",
+			map[string]string{"port_name": "http"},
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv6,
+					Ports: []discoveryv1.EndpointPort{
+						discoveryv1.EndpointPort{Name: &http, Port: &httpPort},
+					},
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"2001:db8::1"}},
+					},
+				},
+			},
+			[]string{"[2001:db8::1]:8500"},
+		},
+
+		{
+			"IP family",
+			map[string]string{"ip_family": "ipv6"},
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv4,
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+					},
+				},
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv6,
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"2001:db8::1"}},
+					},
+				},
+			},
+			[]string{"2001:db8::1"},
+		},
+
+		{
+			"Dual-stack without IP family",
+			map[string]string{"port_name": "http"},
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv6,
+					Ports: []discoveryv1.EndpointPort{
+						discoveryv1.EndpointPort{Name: &http, Port: &httpPort},
+					},
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"2001:db8::1"}},
+					},
+				},
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv4,
+					Ports: []discoveryv1.EndpointPort{
+						discoveryv1.EndpointPort{Name: &http, Port: &httpPort},
+					},
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"1.2.3.4"}},
+					},
+				},
+			},
+			[]string{"1.2.3.4:8500"},
+		},
+
+		{
+			"IPv6 only without IP family",
+			nil,
+			[]discoveryv1.EndpointSlice{
+				discoveryv1.EndpointSlice{
+					AddressType: discoveryv1.AddressTypeIPv6,
+					Endpoints: []discoveryv1.Endpoint{
+						discoveryv1.Endpoint{Addresses: []string{"2001:db8::1"}},
+						discoveryv1.Endpoint{Addresses: []string{"2001:db8::2"}},
+					},
+				},
+			},
+			[]string{"2001:db8::1", "2001:db8::2"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			l := log.New(os.Stderr, "", log.LstdFlags)
+			addrs, err := k8s.EndpointSliceAddrs(&discoveryv1.EndpointSliceList{Items: tt.Slices}, tt.Args, l)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestNodeAddrs(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Nodes    []corev1.Node
+		Expected []string
+	}{
+		{
+			"Internal IP",
+			nil,
+			[]corev1.Node{
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeHostName, Address: "node-1"},
+							corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "2.3.4.5"},
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "1.2.3.4"},
+						},
+					},
+				},
+			},
+			[]string{"1.2.3.4"},
+		},
+
+		{
+			"External IP",
+			map[string]string{"address_type": "ExternalIP"},
+			[]corev1.Node{
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "1.2.3.4"},
+							corev1.NodeAddress{Type: corev1.NodeExternalIP, Address: "2.3.4.5"},
+						},
+					},
+				},
+
+				// No external IP
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "1.2.3.5"},
+						},
+					},
+				},
+			},
+			[]string{"2.3.4.5"},
+		},
+
+		{
+			"Only nodes that are ready",
+			nil,
+			[]corev1.Node{
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Conditions: []corev1.NodeCondition{
+							corev1.NodeCondition{
+								Type:   corev1.NodeReady,
+								Status: corev1.ConditionTrue,
+							},
+						},
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "ready"},
+						},
+					},
+				},
+
+				// Not true
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Conditions: []corev1.NodeCondition{
+							corev1.NodeCondition{
+								Type:   corev1.NodeReady,
+								Status: corev1.ConditionFalse,
+							},
+						},
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "not-ready"},
+						},
+					},
+				},
+
+				// Not ready type, ignored
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Conditions: []corev1.NodeCondition{
+							corev1.NodeCondition{
+								Type:   corev1.NodeMemoryPressure,
+								Status: corev1.ConditionTrue,
+							},
+						},
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "pressure"},
+						},
+					},
+				},
+			},
+			[]string{"ready", "pressure"},
+		},
+
+		{
+			"IP family",
+			map[string]string{"ip_family": "ipv6"},
+			[]corev1.Node{
+				corev1.Node{
+					Status: corev1.NodeStatus{
+						Addresses: []corev1.NodeAddress{
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "1.2.3.4"},
+							corev1.NodeAddress{Type: corev1.NodeInternalIP, Address: "2001:db8::1"},
+						},
+					},
+				},
+			},
+			[]string{"2001:db8::1"},
+		},
+	}
+
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			l := log.New(os.Stderr, "", log.LstdFlags)
+			addrs, err := k8s.NodeAddrs(&corev1.NodeList{Items: tt.Nodes}, tt.Args, l)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestClientsetAddrs(t *testing.T) {
+	serf := "serf-lan"
+	serfPort := int32(8301)
+	objects := []runtime.Object{
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-server-0",
+				Namespace: "default",
+				Labels:    map[string]string{"app": "consul"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-server-0",
+				Namespace: "consul",
+				Labels:    map[string]string{"app": "consul"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.1.1"},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-server-abcde",
+				Namespace: "consul",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "consul-server"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Ports:       []discoveryv1.EndpointPort{{Name: &serf, Port: &serfPort}},
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.1.1"}}},
+		},
+		&discoveryv1.EndpointSlice{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-ui-abcde",
+				Namespace: "consul",
+				Labels:    map[string]string{discoveryv1.LabelServiceName: "consul-ui"},
+			},
+			AddressType: discoveryv1.AddressTypeIPv4,
+			Endpoints:   []discoveryv1.Endpoint{{Addresses: []string{"10.0.1.2"}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "consul-server-0",
+				Namespace: "vault",
+				Labels:    map[string]string{"app": "consul"},
+			},
+			Status: corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.2.1"},
+		},
+		&corev1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node-1"},
+			Status: corev1.NodeStatus{
+				Addresses: []corev1.NodeAddress{{Type: corev1.NodeInternalIP, Address: "192.168.0.1"}},
+			},
+		},
+	}
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"Pods in the default namespace",
+			map[string]string{"label_selector": "app=consul"},
+			[]string{"10.0.0.1"},
+			"",
+		},
+		{
+			"Pods",
+			map[string]string{"mode": "pods", "namespace": "consul"},
+			[]string{"10.0.1.1"},
+			"",
+		},
+		{
+			"Pods in namespaces",
+			map[string]string{"namespaces": "consul, vault"},
+			[]string{"10.0.1.1", "10.0.2.1"},
+			"",
+		},
+		{
+			"Pods in all namespaces",
+			map[string]string{"all_namespaces": "true", "label_selector": "app=consul"},
+			[]string{"10.0.0.1", "10.0.1.1", "10.0.2.1"},
+			"",
+		},
+		{
+			"All namespaces and namespace",
+			map[string]string{"all_namespaces": "true", "namespace": "consul"},
+			nil,
+			"can't be combined",
+		},
+		{
+			"Endpoint slices in all namespaces",
+			map[string]string{"mode": "endpointslices", "all_namespaces": "true", "service": "consul-server"},
+			[]string{"10.0.1.1"},
+			"",
+		},
+		{
+			"Endpoint slices of the service",
+			map[string]string{"mode": "endpointslices", "namespace": "consul", "service": "consul-server", "port_name": "serf-lan"},
+			[]string{"10.0.1.1:8301"},
+			"",
+		},
+		{
+			"Endpoint slices require a service",
+			map[string]string{"mode": "endpointslices"},
+			nil,
+			"service is required",
+		},
+		{
+			"Nodes",
+			map[string]string{"mode": "nodes"},
+			[]string{"192.168.0.1"},
+			"",
+		},
+		{
+			"Invalid address type",
+			map[string]string{"mode": "nodes", "address_type": "Hostname"},
+			nil,
+			"invalid address_type",
+		},
+		{
+			"Invalid IP family",
+			map[string]string{"ip_family": "dual"},
+			nil,
+			"invalid ip_family",
+		},
+		{
+			"Invalid mode",
+			map[string]string{"mode": "services"},
+			nil,
+			"invalid mode",
+		},
+	}
+
+	clientset := fake.NewSimpleClientset(objects...)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			l := log.New(os.Stderr, "", log.LstdFlags)
+			addrs, err := k8s.ClientsetAddrs(clientset, tt.Args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+
+			// The fake clientset doesn't order objects of all namespaces.
+			sort.Strings(addrs)
+
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	pods := &corev1.PodList{
+		TypeMeta: metav1.TypeMeta{Kind: "PodList", APIVersion: "v1"},
+		Items: []corev1.Pod{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-0"},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.1"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-1"},
+				Status:     corev1.PodStatus{Phase: corev1.PodPending, PodIP: "10.0.0.2"},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "consul-2"},
+				Status:     corev1.PodStatus{Phase: corev1.PodRunning, PodIP: "10.0.0.3"},
+			},
+		},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces/default/pods" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(pods)
+	}))
+	defer srv.Close()
+
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig.yaml")
+	config := fmt.Sprintf(`apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: %s
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`, srv.URL)
+	if err := os.WriteFile(kubeconfig, []byte(config), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	discovertest.RunConformance(t, &k8s.Provider{}, discovertest.Fixtures{
+		Name: "k8s",
+		Args: discover.Config{
+			"provider":   "k8s",
+			"kubeconfig": kubeconfig,
+		},
+		Want: []string{"10.0.0.1", "10.0.0.3"},
+		Invalid: discover.Config{
+			"provider":   "k8s",
+			"kubeconfig": kubeconfig,
+			"mode":       "bogus",
+		},
+	})
+}
@@ -4,11 +4,15 @@
 package linode_test
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/linode"
 )
 
@@ -143,3 +147,33 @@ func TestAddrsTaggedRegion(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v4/linode/instances":
+			fmt.Fprint(w, `{"data":[{"id":1},{"id":2},{"id":3}],"page":1,"pages":1,"results":3}`)
+		case "/v4/linode/instances/1/ips":
+			fmt.Fprint(w, `{"ipv4":{"private":[{"address":"192.168.0.1"}],"public":[{"address":"203.0.113.1"}]}}`)
+		case "/v4/linode/instances/2/ips":
+			fmt.Fprint(w, `{"ipv4":{"public":[{"address":"203.0.113.2"}]}}`)
+		case "/v4/linode/instances/3/ips":
+			fmt.Fprint(w, `{"ipv4":{"private":[{"address":"192.168.0.3"}]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("LINODE_URL", srv.URL)
+
+	discovertest.RunConformance(t, &linode.Provider{}, discovertest.Fixtures{
+		Name: "linode",
+		Args: discover.Config{
+			"provider":  "linode",
+			"api_token": "token",
+			"tag_name":  "consul",
+		},
+		Want: []string{"192.168.0.1", "192.168.0.3"},
+	})
+}
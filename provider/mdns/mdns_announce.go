@@ -0,0 +1,113 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package mdns
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	m "github.com/hashicorp/mdns"
+	"github.com/miekg/dns"
+)
+
+// Announcement describes a service instance which is advertised via mDNS.
+type Announcement struct {
+	// Service and Domain must match the "service" and "domain" keys of the
+	// configuration which should discover the node. Domain defaults to
+	// "local".
+	Service string
+	Domain  string
+
+	// Instance is the name of the service instance. It must be unique
+	// for the service and defaults to the host name.
+	Instance string
+
+	// Host is the host name the addresses are announced for. It
+	// defaults to the host name of the operating system.
+	Host string
+
+	// Port is the port of the service. It is required.
+	Port int
+
+	// IPs are the addresses to announce. They default to the addresses
+	// Host resolves to.
+	IPs []net.IP
+
+	// TXT are the strings of the TXT record, e.g. "role=server". They are
+	// matched by the "txt_filter" key.
+	TXT []string
+
+	// Interface is the name of the network interface to announce on.
+	// Default is the system multicast interface.
+	Interface string
+}
+
+// Announcer answers the mDNS queries for an announcement until it is shut
+// down.
+type Announcer struct {
+	server *m.Server
+}
+
+// Announce starts answering mDNS queries for a so that the node can be
+// found with the "mdns" provider.
+func Announce(a Announcement) (*Announcer, error) {
+	if a.Service == "" {
+		return nil, fmt.Errorf("discover-mdns: Service is required")
+	}
+	if a.Port <= 0 || a.Port > 65535 {
+		return nil, fmt.Errorf("discover-mdns: invalid port %d", a.Port)
+	}
+
+	domain := "local."
+	if a.Domain != "" {
+		domain = dns.Fqdn(a.Domain)
+	}
+	host := a.Host
+	if host != "" {
+		host = dns.Fqdn(host)
+	}
+	instance := a.Instance
+	if instance == "" {
+		name := host
+		if name == "" {
+			var err error
+			if name, err = os.Hostname(); err != nil {
+				return nil, fmt.Errorf("discover-mdns: %w", err)
+			}
+		}
+		instance, _, _ = strings.Cut(name, ".")
+	}
+
+	// Queries only complete with a TXT record, so announce an empty one
+	// as recommended by RFC 6763, section 6.1.
+	txt := a.TXT
+	if len(txt) == 0 {
+		txt = []string{""}
+	}
+
+	zone, err := m.NewMDNSService(instance, a.Service, domain, host, a.Port, a.IPs, txt)
+	if err != nil {
+		return nil, fmt.Errorf("discover-mdns: %w", err)
+	}
+
+	config := &m.Config{Zone: zone}
+	if a.Interface != "" {
+		if config.Iface, err = net.InterfaceByName(a.Interface); err != nil {
+			return nil, fmt.Errorf("discover-mdns: Failed to find interface %s: %w", a.Interface, err)
+		}
+	}
+
+	server, err := m.NewServer(config)
+	if err != nil {
+		return nil, fmt.Errorf("discover-mdns: %w", err)
+	}
+	return &Announcer{server: server}, nil
+}
+
+// Shutdown stops answering queries.
+func (a *Announcer) Shutdown() error {
+	return a.server.Shutdown()
+}
@@ -13,6 +13,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/hashicorp/go-discover/internal/txtfilter"
 	m "github.com/hashicorp/mdns"
 )
 
@@ -31,6 +32,15 @@ func (p *Provider) Help() string {
                        and disabled when set to "false".  Default "true".
     v4:                IPv4 will be allowed when set to "true" and disabled
                        when set to "false".  Default "true".
+    all_addrs:         Return both the IPv6 and the IPv4 address of an entry
+                       when set to "true" instead of only the preferred one.
+                       Default "false".
+    interface:         The name of the network interface to query on, e.g.
+                       "eth0".  Default is the system multicast interface.
+    txt_filter:        Comma separated list of "key=value" pairs which must
+                       all be present in the TXT record of an entry, e.g.
+                       txt_filter="role=server,dc=dc1".  A "key" without a
+                       value only requires the key to be present.
 `
 }
 
@@ -38,10 +48,14 @@ func (p *Provider) Help() string {
 func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
 	var params *m.QueryParam
 	var ch chan *m.ServiceEntry
-	var v6, v4 bool
+	var v6, v4, all bool
 	var addrs []string
 	var err error
 
+	if args["provider"] != "mdns" {
+		return nil, fmt.Errorf("discover-mdns: invalid provider %s", args["provider"])
+	}
+
 	// default to null logger
 	if l == nil {
 		l = log.New(io.Discard, "", 0)
@@ -91,6 +105,26 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 		v4 = true
 	}
 
+	// validate and set all addresses toggle
+	if args["all_addrs"] != "" {
+		if all, err = strconv.ParseBool(args["all_addrs"]); err != nil {
+			return nil, fmt.Errorf("discover-mdns: Failed to parse all_addrs: %s", err)
+		}
+	}
+
+	// validate and set interface
+	if args["interface"] != "" {
+		if params.Interface, err = net.InterfaceByName(args["interface"]); err != nil {
+			return nil, fmt.Errorf("discover-mdns: Failed to find interface %s: %w", args["interface"], err)
+		}
+	}
+
+	// validate and set TXT filter
+	filter, err := txtfilter.Parse(args["txt_filter"])
+	if err != nil {
+		return nil, fmt.Errorf("discover-mdns: %w", err)
+	}
+
 	// init entries channel
 	ch = make(chan *m.ServiceEntry)
 	params.Entries = ch
@@ -103,18 +137,27 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 
 	go func() {
 		defer wg.Done()
-		var addr string
 		for e := range ch {
-			addr = "" // reset addr each loop
+			if !filter.Match(e.InfoFields) {
+				l.Printf("[DEBUG] discover-mdns: %s does not match txt_filter",
+					e.Name)
+				continue
+			}
+
+			// collect the addresses of the entry in order of preference
+			var ips []net.IP
 			if v6 && e.AddrV6 != nil {
-				addr = net.JoinHostPort(e.AddrV6.String(),
-					strconv.Itoa(e.Port))
+				ips = append(ips, e.AddrV6)
+			}
+			if v4 && e.AddrV4 != nil {
+				ips = append(ips, e.AddrV4)
 			}
-			if addr == "" && v4 && e.AddrV4 != nil {
-				addr = net.JoinHostPort(e.AddrV4.String(),
-					strconv.Itoa(e.Port))
+			if len(ips) > 1 && !all {
+				ips = ips[:1]
 			}
-			if addr != "" {
+
+			for _, ip := range ips {
+				addr := net.JoinHostPort(ip.String(), strconv.Itoa(e.Port))
 				l.Printf("[DEBUG] discover-mdns: %s -> %s",
 					e.Host, addr)
 				// build address list
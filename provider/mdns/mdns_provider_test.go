@@ -7,11 +7,16 @@ import (
 	"log"
 	"net"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/mdns"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	provider "github.com/hashicorp/go-discover/provider/mdns"
 )
 
@@ -151,3 +156,135 @@ func TestDiscover(t *testing.T) {
 		t.Logf("PASS [%d/%d] %s", idx, len(cases), tc.desc)
 	}
 }
+
+func TestAnnounce(t *testing.T) {
+	ann, err := provider.Announce(provider.Announcement{
+		Service:  "_announce-test._noop",
+		Instance: "server",
+		Host:     "node-a.local",
+		Port:     4001,
+		IPs:      []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+		TXT:      []string{"role=server", "dc=dc1"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to announce: %s", err)
+	}
+	defer ann.Shutdown()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"preferred address",
+			map[string]string{},
+			[]string{"[::1]:4001"},
+			"",
+		},
+		{
+			"v4 only",
+			map[string]string{"v6": "false"},
+			[]string{"127.0.0.1:4001"},
+			"",
+		},
+		{
+			"all addresses",
+			map[string]string{"all_addrs": "true"},
+			[]string{"127.0.0.1:4001", "[::1]:4001"},
+			"",
+		},
+		{
+			"txt filter",
+			map[string]string{"txt_filter": "role=server,dc=dc1"},
+			[]string{"[::1]:4001"},
+			"",
+		},
+		{
+			"txt filter without match",
+			map[string]string{"txt_filter": "dc=dc2"},
+			nil,
+			"",
+		},
+		{
+			"invalid txt filter",
+			map[string]string{"txt_filter": "=dc1"},
+			nil,
+			"empty key",
+		},
+		{
+			"invalid all_addrs",
+			map[string]string{"all_addrs": "xxxx"},
+			nil,
+			"Failed to parse all_addrs",
+		},
+		{
+			"unknown interface",
+			map[string]string{"interface": "does-not-exist0"},
+			nil,
+			"Failed to find interface does-not-exist0",
+		},
+	}
+
+	p := &provider.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider": "mdns",
+				"service":  "_announce-test._noop",
+				"timeout":  "1s",
+			}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			sort.Strings(addrs)
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAnnounceInvalid(t *testing.T) {
+	for _, a := range []provider.Announcement{
+		{Port: 4001},
+		{Service: "_announce-test._noop"},
+		{Service: "_announce-test._noop", Port: 4001, Interface: "does-not-exist0"},
+	} {
+		if ann, err := provider.Announce(a); err == nil {
+			ann.Shutdown()
+			t.Fatalf("Announce(%+v) should fail", a)
+		}
+	}
+}
+
+func TestConformance(t *testing.T) {
+	discovertest.RunConformance(t, &provider.Provider{}, discovertest.Fixtures{
+		Name: "mdns",
+		Args: discover.Config{
+			"provider": "mdns",
+			"service":  "_fake-service._noop",
+			"timeout":  "100ms",
+		},
+		Invalid: discover.Config{
+			"provider": "mdns",
+			"timeout":  "100ms",
+		},
+		Timeout: discover.Config{
+			"provider": "mdns",
+			"service":  "_fake-service._noop",
+			"timeout":  "500ms",
+		},
+		TimeoutAfter: 5 * time.Second,
+	})
+}
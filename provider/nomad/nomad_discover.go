@@ -0,0 +1,230 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package nomad provides node discovery from Nomad native service
+// discovery.
+//
+// The provider talks to the Nomad HTTP API directly rather than through
+// github.com/hashicorp/nomad/api since Nomad itself depends on this
+// module.
+package nomad
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/go-discover/internal/httpclient"
+)
+
+type Provider struct {
+	userAgent string
+}
+
+func (p *Provider) SetUserAgent(s string) {
+	p.userAgent = s
+}
+
+func (p *Provider) Help() string {
+	return `Nomad:
+
+    provider:        "nomad"
+    service:         The name of the service to look up.
+    address:         Address of the Nomad agent. Default "http://127.0.0.1:4646".
+                     Use an "https://" prefix to connect with TLS.
+    namespace:       The namespace of the service. Use "*" for all namespaces.
+                     Default "default".
+    region:          The region to query. Defaults to the region of the agent.
+    datacenter:      Only return allocations in this datacenter.
+    tags:            Comma separated list of tags the registrations must all have.
+    token:           The ACL token to use.
+    ca_file:         PEM encoded CA bundle used to verify the Nomad server certificate.
+    cert_file:       PEM encoded client certificate.
+    key_file:        PEM encoded key of the client certificate.
+    tls_server_name: The server name used to verify the Nomad server certificate.
+    insecure_ssl:    Whether or not to skip TLS certificate validation.
+    timeout:         Timeout for the request. Default "10s".
+
+    The address and port of the service registration of every allocation
+    is returned.
+
+    Variables can also be provided by environment variables:
+    export NOMAD_ADDR for address
+    export NOMAD_TOKEN for token
+    export NOMAD_NAMESPACE for namespace
+    export NOMAD_REGION for region
+    export NOMAD_CACERT for ca_file
+    export NOMAD_CLIENT_CERT for cert_file
+    export NOMAD_CLIENT_KEY for key_file
+    export NOMAD_TLS_SERVER_NAME for tls_server_name
+    export NOMAD_SKIP_VERIFY for insecure_ssl
+
+    The ACL token requires the "read-job" capability in the namespace.
+`
+}
+
+// registration is the subset of a Nomad service registration used for
+// discovery.
+type registration struct {
+	ID         string
+	Namespace  string
+	Datacenter string
+	AllocID    string
+	Tags       []string
+	Address    string
+	Port       int
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "nomad" {
+		return nil, fmt.Errorf("discover-nomad: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	service := args["service"]
+	if service == "" {
+		return nil, fmt.Errorf("discover-nomad: service is required")
+	}
+
+	timeout, err := httpclient.Timeout(args)
+	if err != nil {
+		return nil, fmt.Errorf("discover-nomad: %w", err)
+	}
+
+	address := argsOrEnv(args, "address", "NOMAD_ADDR")
+	if address == "" {
+		address = "http://127.0.0.1:4646"
+	}
+	if !strings.Contains(address, "://") {
+		address = "http://" + address
+	}
+	base, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("discover-nomad: invalid address: %w", err)
+	}
+
+	// The TLS settings can also be read from the environment variables
+	// used by the Nomad CLI.
+	tlsConfig, err := httpclient.TLSConfig(args, httpclient.TLSEnv{
+		CAFile:     "NOMAD_CACERT",
+		CertFile:   "NOMAD_CLIENT_CERT",
+		KeyFile:    "NOMAD_CLIENT_KEY",
+		Insecure:   "NOMAD_SKIP_VERIFY",
+		ServerName: "NOMAD_TLS_SERVER_NAME",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("discover-nomad: %w", err)
+	}
+
+	var tags []string
+	for _, tag := range strings.Split(args["tags"], ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	datacenter := args["datacenter"]
+
+	q := url.Values{}
+	if ns := argsOrEnv(args, "namespace", "NOMAD_NAMESPACE"); ns != "" {
+		q.Set("namespace", ns)
+	}
+	if region := argsOrEnv(args, "region", "NOMAD_REGION"); region != "" {
+		q.Set("region", region)
+	}
+	u := base.JoinPath("/v1/service", service)
+	u.RawQuery = q.Encode()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("discover-nomad: %w", err)
+	}
+	if token := argsOrEnv(args, "token", "NOMAD_TOKEN"); token != "" {
+		l.Printf("[DEBUG] discover-nomad: Using ACL token")
+		req.Header.Set("X-Nomad-Token", token)
+	}
+	if p.userAgent != "" {
+		req.Header.Set("User-Agent", p.userAgent)
+	}
+
+	client := httpclient.New(tlsConfig)
+	defer client.CloseIdleConnections()
+
+	l.Printf("[DEBUG] discover-nomad: Using address=%s service=%s %s", base.Redacted(), service, q.Encode())
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("discover-nomad: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := httpclient.CheckStatus(resp); err != nil {
+		return nil, fmt.Errorf("discover-nomad: %w", err)
+	}
+
+	var regs []registration
+	if err := json.NewDecoder(resp.Body).Decode(&regs); err != nil {
+		return nil, fmt.Errorf("discover-nomad: invalid response: %w", err)
+	}
+	l.Printf("[DEBUG] discover-nomad: Found %d registrations of %s", len(regs), service)
+
+	var addrs []string
+	for _, r := range regs {
+		if datacenter != "" && r.Datacenter != datacenter {
+			l.Printf("[DEBUG] discover-nomad: Ignoring allocation %s in datacenter %s", r.AllocID, r.Datacenter)
+			continue
+		}
+		if !hasTags(r.Tags, tags) {
+			l.Printf("[DEBUG] discover-nomad: Ignoring allocation %s with tags %v", r.AllocID, r.Tags)
+			continue
+		}
+		if r.Address == "" {
+			l.Printf("[DEBUG] discover-nomad: Allocation %s has no address", r.AllocID)
+			continue
+		}
+		addr := r.Address
+		if r.Port > 0 {
+			addr = net.JoinHostPort(addr, strconv.Itoa(r.Port))
+		}
+		l.Printf("[INFO] discover-nomad: Allocation %s has address %s", r.AllocID, addr)
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
+// hasTags reports whether have contains all of want.
+func hasTags(have, want []string) bool {
+	for _, w := range want {
+		found := false
+		for _, h := range have {
+			if h == w {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// argsOrEnv allows you to pick an environmental variable for a setting if the arg is not set
+func argsOrEnv(args map[string]string, key, env string) string {
+	if value, ok := args[key]; ok {
+		return value
+	}
+	return os.Getenv(env)
+}
@@ -0,0 +1,243 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package nomad_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/nomad"
+)
+
+var _ discover.Provider = (*nomad.Provider)(nil)
+var _ discover.ProviderWithUserAgent = (*nomad.Provider)(nil)
+
+type registration struct {
+	ServiceName string
+	Namespace   string
+	Datacenter  string
+	AllocID     string
+	Tags        []string
+	Address     string
+	Port        int
+}
+
+var registrations = []registration{
+	{"vault", "default", "dc1", "a1", []string{"server", "active"}, "10.0.0.1", 8201},
+	{"vault", "default", "dc1", "a2", []string{"server"}, "10.0.0.2", 8201},
+	{"vault", "default", "dc2", "a3", []string{"server"}, "fd00::3", 8201},
+	{"vault", "ops", "dc1", "a4", []string{"server"}, "10.0.0.4", 8201},
+	{"consul", "default", "dc1", "a5", nil, "10.0.0.5", 8301},
+}
+
+// handler serves /v1/service/ from registrations with the namespace and
+// region semantics of the Nomad HTTP API.
+func handler(token string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Nomad-Token") != token {
+			http.Error(w, "Permission denied", http.StatusForbidden)
+			return
+		}
+		name, ok := strings.CutPrefix(r.URL.Path, "/v1/service/")
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		q := r.URL.Query()
+		if region := q.Get("region"); region != "" && region != "global" {
+			http.Error(w, "No path to region", http.StatusInternalServerError)
+			return
+		}
+		ns := q.Get("namespace")
+		if ns == "" {
+			ns = "default"
+		}
+
+		regs := []registration{}
+		for _, reg := range registrations {
+			if reg.ServiceName == name && (ns == "*" || reg.Namespace == ns) {
+				regs = append(regs, reg)
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(regs)
+	}
+}
+
+func TestAddrs(t *testing.T) {
+	srv := httptest.NewServer(handler("s3cr3t"))
+	defer srv.Close()
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"service",
+			nil,
+			[]string{"10.0.0.1:8201", "10.0.0.2:8201", "[fd00::3]:8201"},
+			"",
+		},
+		{
+			"tags",
+			map[string]string{"tags": "server,active"},
+			[]string{"10.0.0.1:8201"},
+			"",
+		},
+		{
+			"datacenter",
+			map[string]string{"datacenter": "dc2"},
+			[]string{"[fd00::3]:8201"},
+			"",
+		},
+		{
+			"namespace",
+			map[string]string{"namespace": "ops"},
+			[]string{"10.0.0.4:8201"},
+			"",
+		},
+		{
+			"all namespaces",
+			map[string]string{"namespace": "*", "datacenter": "dc1"},
+			[]string{"10.0.0.1:8201", "10.0.0.2:8201", "10.0.0.4:8201"},
+			"",
+		},
+		{
+			"region",
+			map[string]string{"region": "global", "service": "consul"},
+			[]string{"10.0.0.5:8301"},
+			"",
+		},
+		{
+			"unknown region",
+			map[string]string{"region": "eu"},
+			nil,
+			"500 Internal Server Error: No path to region",
+		},
+		{
+			"no registrations",
+			map[string]string{"service": "boundary"},
+			nil,
+			"",
+		},
+		{
+			"bad token",
+			map[string]string{"token": "wrong"},
+			nil,
+			"403 Forbidden: Permission denied",
+		},
+	}
+
+	p := &nomad.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{
+				"provider": "nomad",
+				"service":  "vault",
+				"address":  srv.URL,
+				"token":    "s3cr3t",
+			}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestAddrsTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(handler("from-env"))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, ca, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NOMAD_ADDR", srv.URL)
+	t.Setenv("NOMAD_TOKEN", "from-env")
+	t.Setenv("NOMAD_SKIP_VERIFY", "")
+	t.Setenv("NOMAD_CACERT", "")
+
+	p := &nomad.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	args := discover.Config{"provider": "nomad", "service": "consul"}
+	if _, err := p.Addrs(args, l); err == nil || !strings.Contains(err.Error(), "certificate") {
+		t.Fatalf("got error %v, want certificate error", err)
+	}
+
+	t.Setenv("NOMAD_CACERT", caFile)
+	addrs, err := p.Addrs(args, l)
+	if err != nil {
+		t.Fatalf("err: %s", err)
+	}
+	if !reflect.DeepEqual(addrs, []string{"10.0.0.5:8301"}) {
+		t.Fatalf("bad: %#v", addrs)
+	}
+}
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(handler(""))
+	defer srv.Close()
+	hang := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hang.Close()
+
+	discovertest.RunConformance(t, &nomad.Provider{}, discovertest.Fixtures{
+		Name: "nomad",
+		Args: discover.Config{
+			"provider":  "nomad",
+			"service":   "vault",
+			"address":   srv.URL,
+			"token":     "",
+			"namespace": "*",
+		},
+		Want: []string{"10.0.0.1:8201", "10.0.0.2:8201", "[fd00::3]:8201", "10.0.0.4:8201"},
+		Invalid: discover.Config{
+			"provider": "nomad",
+			"address":  srv.URL,
+		},
+		Timeout: discover.Config{
+			"provider": "nomad",
+			"service":  "vault",
+			"address":  hang.URL,
+			"timeout":  "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider": "nomad",
+			"service":  "vault",
+			"address":  hang.URL,
+			"timeout":  "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
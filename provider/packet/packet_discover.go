@@ -5,6 +5,7 @@ package packet
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -34,7 +35,7 @@ func (p *Provider) Help() string {
 	address_type:   "private_v4", "public_v4" or "public_v6". Defaults to "private_v4". Optional
 	facility:       Filter for specific facility (Examples: "ewr1,ams1")
 	tag:            Filter by tag (Examples: "tag1,tag2")
-	
+
 	Variables can also be provided by environmental variables:
 	export PACKET_PROJECT for project
 	export PACKET_URL for url
@@ -44,6 +45,14 @@ func (p *Provider) Help() string {
 
 // Addrs function
 func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "packet" {
+		return nil, fmt.Errorf("discover-packet: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
 	authToken := argsOrEnv(args, "auth_token", "PACKET_AUTH_TOKEN")
 	projectID := argsOrEnv(args, "project", "PACKET_PROJECT")
 	packetURL := argsOrEnv(args, "url", "PACKET_URL")
@@ -4,11 +4,15 @@
 package packet_test
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/packet"
 )
 
@@ -227,3 +231,40 @@ func TestAddrsTagIncludeMulti(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+func TestConformance(t *testing.T) {
+	t.Setenv("PACKET_PROJECT", "")
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/projects/p-1/devices" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"devices":[
+			{"facility":{"code":"ewr1"},"tags":["consul"],"ip_addresses":[
+				{"address":"147.75.0.1","address_family":4,"public":true},
+				{"address":"10.0.0.1","address_family":4,"public":false}]},
+			{"facility":{"code":"ams1"},"tags":["consul"],"ip_addresses":[
+				{"address":"10.0.0.2","address_family":4,"public":false}]},
+			{"facility":{"code":"ewr1"},"tags":["web"],"ip_addresses":[
+				{"address":"10.0.0.3","address_family":4,"public":false}]}]}`)
+	}))
+	defer srv.Close()
+
+	discovertest.RunConformance(t, &packet.Provider{}, discovertest.Fixtures{
+		Name: "packet",
+		Args: discover.Config{
+			"provider":   "packet",
+			"auth_token": "token",
+			"project":    "p-1",
+			"url":        srv.URL + "/",
+			"tag":        "consul",
+		},
+		Want: []string{"10.0.0.1", "10.0.0.2"},
+		Invalid: discover.Config{
+			"provider":   "packet",
+			"auth_token": "token",
+			"url":        srv.URL + "/",
+		},
+	})
+}
@@ -4,11 +4,15 @@
 package scaleway_test
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/scaleway"
 )
 
@@ -40,3 +44,37 @@ func TestAddrs(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/servers" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"servers":[
+			{"name":"consul-1","private_ip":"10.1.0.1","tags":["consul-server"]},
+			{"name":"web-1","private_ip":"10.1.0.2","tags":["web"]},
+			{"name":"consul-2","private_ip":"10.1.0.3","tags":["consul-server"]}]}`)
+	}))
+	defer srv.Close()
+	t.Setenv("SCW_COMPUTE_API", srv.URL)
+
+	discovertest.RunConformance(t, &scaleway.Provider{}, discovertest.Fixtures{
+		Name: "scaleway",
+		Args: discover.Config{
+			"provider":     "scaleway",
+			"organization": "org",
+			"token":        "token",
+			"tag_name":     "consul-server",
+		},
+		Want: []string{"10.1.0.1", "10.1.0.3"},
+		Invalid: discover.Config{
+			"provider":     "scaleway",
+			"organization": "org",
+			"token":        "token",
+			"tag_name":     "consul-server",
+			"region":       "mars1",
+		},
+	})
+}
@@ -4,11 +4,15 @@
 package softlayer_test
 
 import (
+	"fmt"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/softlayer"
 )
 
@@ -34,3 +38,30 @@ func TestAddrs(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+func TestConformance(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/SoftLayer_Account/getVirtualGuests.json" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `[
+			{"id":1,"hostname":"consul-1","domain":"example.com","primaryBackendIpAddress":"10.2.0.1"},
+			{"id":2,"hostname":"consul-2","domain":"example.com","primaryBackendIpAddress":"10.2.0.2"}]`)
+	}))
+	defer srv.Close()
+	t.Setenv("SL_ENDPOINT_URL", srv.URL)
+
+	discovertest.RunConformance(t, &softlayer.Provider{}, discovertest.Fixtures{
+		Name: "softlayer",
+		Args: discover.Config{
+			"provider":   "softlayer",
+			"username":   "user",
+			"api_key":    "key",
+			"datacenter": "dal06",
+			"tag_value":  "consul-server",
+		},
+		Want: []string{"10.2.0.1", "10.2.0.2"},
+	})
+}
@@ -5,10 +5,17 @@
 package srv
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/hashicorp/go-discover/internal/resolver"
 )
 
 type Provider struct {
@@ -22,10 +29,26 @@ func (p *Provider) SetUserAgent(s string) {
 func (p *Provider) Help() string {
 	return `SRV:
 
-    provider:   "srv"
-    service:    The SRV service to filter on
-    proto:      The protocol to filter on
-    domain:     The SRV domain to filter on
+    provider:        "srv"
+    service:         The SRV service to filter on
+    proto:           The protocol to filter on
+    domain:          The SRV domain to filter on
+    name:            The full SRV record name to look up, e.g. "consul.service.example.com".
+                     Used instead of service, proto and domain for names which don't
+                     follow the "_service._proto.domain" convention.
+    nameserver:      Address of the DNS server to query, e.g. "10.0.0.2:53".
+                     Defaults to the system resolver. The port defaults to 53.
+    transport:       "udp" or "tcp". Default "udp", which falls back to TCP for
+                     truncated responses.
+    resolve_targets: "true" to return the A and AAAA records of the targets
+                     instead of their names. Default "false".
+    timeout:         Timeout for all lookups. Default "5s".
+
+    Records are ordered by priority, lowest first, and by weight within
+    the same priority, highest first. Weights only set a fixed preference
+    and are not used for the weighted random selection of RFC 2782, which
+    keeps the order stable between lookups. Records with the target "."
+    are ignored.
 `
 }
 
@@ -43,20 +66,81 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	}
 	domain := args["domain"]
 	service := args["service"]
-	if domain == "" || service == "" {
+	name := args["name"]
+	switch {
+	case name != "" && (service != "" || domain != "" || args["proto"] != ""):
+		return nil, fmt.Errorf("discover-srv: name cannot be combined with service, proto or domain")
+	case name == "" && (domain == "" || service == ""):
 		return nil, fmt.Errorf("discover-srv: service or domain is required")
 	}
-	l.Printf("[INFO] srv: Using service=%s proto=%s domain=%s", service, proto, domain)
 
-	_, records, err := net.LookupSRV(service, proto, domain)
+	resolveTargets := false
+	if v := args["resolve_targets"]; v != "" {
+		var err error
+		if resolveTargets, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("discover-srv: resolve_targets must be boolean value: %w", err)
+		}
+	}
+
+	timeout := 5 * time.Second
+	if v := args["timeout"]; v != "" {
+		var err error
+		if timeout, err = time.ParseDuration(v); err != nil {
+			return nil, fmt.Errorf("discover-srv: invalid timeout: %w", err)
+		}
+	}
+
+	conf, err := resolver.New(args["nameserver"], args["transport"])
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("discover-srv: %w", err)
 	}
+	netResolver := conf.Net()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var records []*net.SRV
+	if name != "" {
+		l.Printf("[INFO] srv: Using name=%s", name)
+		_, records, err = netResolver.LookupSRV(ctx, "", "", name)
+	} else {
+		l.Printf("[INFO] srv: Using service=%s proto=%s domain=%s", service, proto, domain)
+		_, records, err = netResolver.LookupSRV(ctx, service, proto, domain)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("discover-srv: %w", err)
+	}
+	resolver.SortSRV(records)
 
 	var addrs []string
 	for _, r := range records {
-		l.Printf("[INFO] discover-srv: %s:%d", r.Target, r.Port)
-		addrs = append(addrs, fmt.Sprintf("%s:%d", r.Target, r.Port))
+		if r.Target == "." {
+			l.Printf("[DEBUG] discover-srv: Ignoring record without target")
+			continue
+		}
+		port := strconv.Itoa(int(r.Port))
+
+		if !resolveTargets {
+			l.Printf("[INFO] discover-srv: %s:%d", r.Target, r.Port)
+			addrs = append(addrs, fmt.Sprintf("%s:%d", r.Target, r.Port))
+			continue
+		}
+
+		ips, err := netResolver.LookupNetIP(ctx, "ip", r.Target)
+		if err != nil {
+			var dnsErr *net.DNSError
+			if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+				l.Printf("[DEBUG] discover-srv: Target %s has no addresses", r.Target)
+				continue
+			}
+			return nil, fmt.Errorf("discover-srv: %w", err)
+		}
+		sort.Slice(ips, func(i, j int) bool { return ips[i].Less(ips[j]) })
+		for _, ip := range ips {
+			addr := net.JoinHostPort(ip.Unmap().String(), port)
+			l.Printf("[INFO] discover-srv: %s:%d -> %s", r.Target, r.Port, addr)
+			addrs = append(addrs, addr)
+		}
 	}
 	return addrs, nil
 }
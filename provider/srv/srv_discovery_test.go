@@ -4,11 +4,17 @@
 package srv_test
 
 import (
+	"context"
 	"log"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/internal/dnstest"
 	"github.com/hashicorp/go-discover/provider/srv"
 )
 
@@ -32,3 +38,145 @@ func TestSRVAddrs(t *testing.T) {
 		t.Fatalf("bad: %v", addrs)
 	}
 }
+
+var zone = []string{
+	"_consul._tcp.example.test. 60 IN SRV 20 100 8301 backup.example.test.",
+	"_consul._tcp.example.test. 60 IN SRV 10 10 8301 node-b.example.test.",
+	"_consul._tcp.example.test. 60 IN SRV 10 50 8301 node-c.example.test.",
+	"_consul._tcp.example.test. 60 IN SRV 10 10 8301 node-a.example.test.",
+	"_consul._tcp.example.test. 60 IN SRV 10 10 8300 node-a.example.test.",
+	"_consul._udp.example.test. 60 IN SRV 10 10 8302 node-a.example.test.",
+	"consul.service.example.test. 60 IN SRV 1 1 8500 node-b.example.test.",
+	"consul.service.example.test. 60 IN SRV 1 1 8500 missing.example.test.",
+	"consul.service.example.test. 60 IN SRV 0 0 0 .",
+	"node-a.example.test. 60 IN A 10.0.0.1",
+	"node-b.example.test. 60 IN AAAA fd00::2",
+	"node-b.example.test. 60 IN A 10.0.0.22",
+	"node-b.example.test. 60 IN A 10.0.0.2",
+	"node-c.example.test. 60 IN A 10.0.0.3",
+	"backup.example.test. 60 IN A 10.0.1.1",
+}
+
+func TestAddrsNameserver(t *testing.T) {
+	server := dnstest.NewServer(t, zone...)
+
+	cases := []struct {
+		Name     string
+		Args     map[string]string
+		Expected []string
+		Err      string
+	}{
+		{
+			"priority and weight order",
+			map[string]string{"service": "consul", "domain": "example.test"},
+			[]string{
+				"node-c.example.test.:8301",
+				"node-a.example.test.:8300",
+				"node-a.example.test.:8301",
+				"node-b.example.test.:8301",
+				"backup.example.test.:8301",
+			},
+			"",
+		},
+		{
+			"proto",
+			map[string]string{"service": "consul", "proto": "udp", "domain": "example.test"},
+			[]string{"node-a.example.test.:8302"},
+			"",
+		},
+		{
+			"resolve targets",
+			map[string]string{"service": "consul", "domain": "example.test", "resolve_targets": "true"},
+			[]string{
+				"10.0.0.3:8301",
+				"10.0.0.1:8300",
+				"10.0.0.1:8301",
+				"10.0.0.2:8301",
+				"10.0.0.22:8301",
+				"[fd00::2]:8301",
+				"10.0.1.1:8301",
+			},
+			"",
+		},
+		{
+			"name",
+			map[string]string{"name": "consul.service.example.test"},
+			[]string{"missing.example.test.:8500", "node-b.example.test.:8500"},
+			"",
+		},
+		{
+			"name with unresolvable target",
+			map[string]string{"name": "consul.service.example.test", "resolve_targets": "true", "transport": "tcp"},
+			[]string{"10.0.0.2:8500", "10.0.0.22:8500", "[fd00::2]:8500"},
+			"",
+		},
+		{
+			"name with service",
+			map[string]string{"name": "consul.service.example.test", "service": "consul"},
+			nil,
+			"name cannot be combined",
+		},
+		{
+			"unknown name",
+			map[string]string{"name": "vault.service.example.test"},
+			nil,
+			"no such host",
+		},
+	}
+
+	p := &srv.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			args := discover.Config{"provider": "srv", "nameserver": server.Addr}
+			for k, v := range tt.Args {
+				args[k] = v
+			}
+			addrs, err := p.Addrs(args, l)
+			if tt.Err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.Err) {
+					t.Fatalf("got error %v, want %q", err, tt.Err)
+				}
+			} else if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	server := dnstest.NewServer(t, zone...)
+	blackhole := dnstest.Blackhole(t)
+
+	discovertest.RunConformance(t, &srv.Provider{}, discovertest.Fixtures{
+		Name: "srv",
+		Args: discover.Config{
+			"provider":        "srv",
+			"name":            "consul.service.example.test",
+			"nameserver":      server.Addr,
+			"resolve_targets": "true",
+		},
+		Want: []string{"10.0.0.2:8500", "10.0.0.22:8500", "[fd00::2]:8500"},
+		Invalid: discover.Config{
+			"provider": "srv",
+			"service":  "ldap",
+		},
+		Timeout: discover.Config{
+			"provider":   "srv",
+			"name":       "consul.service.example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		TimeoutAfter: 2 * time.Second,
+		Err: discover.Config{
+			"provider":   "srv",
+			"name":       "consul.service.example.test",
+			"nameserver": blackhole,
+			"timeout":    "100ms",
+		},
+		ErrIs: context.DeadlineExceeded,
+	})
+}
@@ -0,0 +1,47 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+// Package static provides node discovery for a fixed list of addresses.
+package static
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"strings"
+)
+
+type Provider struct{}
+
+func (p *Provider) Help() string {
+	return `Static:
+
+    provider:   "static"
+    addrs:      Comma separated list of addresses, e.g. "10.0.0.1,10.0.0.2:8301"
+
+    The addresses are returned as given and in the given order.
+`
+}
+
+func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error) {
+	if args["provider"] != "static" {
+		return nil, fmt.Errorf("discover-static: invalid provider %s", args["provider"])
+	}
+
+	if l == nil {
+		l = log.New(io.Discard, "", 0)
+	}
+
+	var addrs []string
+	for _, addr := range strings.Split(args["addrs"], ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("discover-static: addrs is required")
+	}
+
+	l.Printf("[DEBUG] discover-static: Found addresses: %v", addrs)
+	return addrs, nil
+}
@@ -0,0 +1,58 @@
+// Copyright IBM Corp. 2017, 2026
+// SPDX-License-Identifier: MPL-2.0
+
+package static_test
+
+import (
+	"log"
+	"os"
+	"reflect"
+	"testing"
+
+	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
+	"github.com/hashicorp/go-discover/provider/static"
+)
+
+var _ discover.Provider = (*static.Provider)(nil)
+
+func TestAddrs(t *testing.T) {
+	cases := []struct {
+		Name     string
+		Addrs    string
+		Expected []string
+	}{
+		{"single", "10.0.0.1", []string{"10.0.0.1"}},
+		{"ports and hosts", "10.0.0.1:8301,node-2,[::1]:8301", []string{"10.0.0.1:8301", "node-2", "[::1]:8301"}},
+		{"whitespace and empty entries", " 10.0.0.2 ,, 10.0.0.1 ,", []string{"10.0.0.2", "10.0.0.1"}},
+	}
+
+	p := &static.Provider{}
+	l := log.New(os.Stderr, "", log.LstdFlags)
+	for _, tt := range cases {
+		t.Run(tt.Name, func(t *testing.T) {
+			addrs, err := p.Addrs(discover.Config{"provider": "static", "addrs": tt.Addrs}, l)
+			if err != nil {
+				t.Fatalf("err: %s", err)
+			}
+			if !reflect.DeepEqual(addrs, tt.Expected) {
+				t.Fatalf("bad: %#v", addrs)
+			}
+		})
+	}
+}
+
+func TestConformance(t *testing.T) {
+	discovertest.RunConformance(t, &static.Provider{}, discovertest.Fixtures{
+		Name: "static",
+		Args: discover.Config{
+			"provider": "static",
+			"addrs":    "10.0.0.2,10.0.0.1:8301",
+		},
+		Want: []string{"10.0.0.2", "10.0.0.1:8301"},
+		Invalid: discover.Config{
+			"provider": "static",
+			"addrs":    " , ",
+		},
+	})
+}
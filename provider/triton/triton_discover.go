@@ -53,7 +53,7 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	}
 	signer, err := authentication.NewSSHAgentSigner(input)
 	if err != nil {
-		return nil, fmt.Errorf("error Creating SSH Agent Signer: %v", err)
+		return nil, fmt.Errorf("discover-triton: error creating SSH agent signer: %w", err)
 	}
 
 	config := &triton.ClientConfig{
@@ -64,7 +64,7 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 
 	c, err := compute.NewClient(config)
 	if err != nil {
-		return nil, fmt.Errorf("error constructing Compute Client: %v", err)
+		return nil, fmt.Errorf("discover-triton: error constructing compute client: %w", err)
 	}
 
 	t := make(map[string]interface{}, 0)
@@ -75,7 +75,7 @@ func (p *Provider) Addrs(args map[string]string, l *log.Logger) ([]string, error
 	}
 	instances, err := c.Instances().List(context.Background(), listInput)
 	if err != nil {
-		return nil, fmt.Errorf("error getting instance list: %v", err)
+		return nil, fmt.Errorf("discover-triton: error getting instance list: %w", err)
 	}
 	var addrs []string
 	for _, instance := range instances {
@@ -6,10 +6,13 @@ package vsphere_test
 import (
 	"context"
 	"log"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/vmware/govmomi/find"
 	"github.com/vmware/govmomi/simulator"
@@ -23,6 +26,7 @@ import (
 	_ "github.com/vmware/govmomi/vapi/simulator"
 
 	discover "github.com/hashicorp/go-discover"
+	"github.com/hashicorp/go-discover/discovertest"
 	"github.com/hashicorp/go-discover/provider/vsphere"
 )
 
@@ -124,86 +128,138 @@ func TestAddrsEnv(t *testing.T) {
 // TestAddrsSimulator runs Addrs() against an in-process vSphere simulator.
 // No live vCenter or environment variables are required.
 func TestAddrsSimulator(t *testing.T) {
-	const (
-		categoryName = "go-discover-test-category"
-		tagName      = "go-discover-test-tag"
-		testIP       = "10.0.0.100"
-	)
-
 	model := simulator.VPX()
 	model.Machine = 1 // ensure at least one VM with a NIC is created
 
 	simulator.Test(func(ctx context.Context, c *vim25.Client) {
-		// Set up REST tags client.
-		rc := rest.NewClient(c)
-		if err := rc.Login(ctx, simulator.DefaultLogin); err != nil {
-			t.Fatal(err)
-		}
-		defer func() { _ = rc.Logout(ctx) }()
-
-		mgr := tags.NewManager(rc)
-
-		// Create a tag category and tag.
-		catID, err := mgr.CreateCategory(ctx, &tags.Category{
-			Name:        categoryName,
-			Cardinality: "SINGLE",
-		})
-		if err != nil {
-			t.Fatal(err)
-		}
-		tagID, err := mgr.CreateTag(ctx, &tags.Tag{Name: tagName, CategoryID: catID})
+		args := simulatorArgs(ctx, t, c)
+		addrs, err := (&vsphere.Provider{}).Addrs(args, log.New(os.Stderr, "", log.LstdFlags))
 		if err != nil {
 			t.Fatal(err)
 		}
 
-		// Find the first VM in the simulator inventory.
-		finder := find.NewFinder(c, false)
-		dc, err := finder.DefaultDatacenter(ctx)
-		if err != nil {
-			t.Fatal(err)
-		}
-		finder.SetDatacenter(dc)
-		vms, err := finder.VirtualMachineList(ctx, "*")
-		if err != nil {
-			t.Fatal(err)
-		}
-		if len(vms) == 0 {
-			t.Skip("simulator produced no virtual machines")
-		}
-		vm := vms[0]
-
-		// Inject a guest IP directly into the simulator's in-memory registry.
-		// This avoids the power-off → CustomizeVM → power-on task chain that
-		// would otherwise be required to populate guest.net[].IpConfig.
-		simVM := simulator.Map(ctx).Get(vm.Reference()).(*simulator.VirtualMachine)
-		simVM.Guest.Net = []types.GuestNicInfo{{
-			IpConfig: &types.NetIpConfigInfo{
-				IpAddress: []types.NetIpConfigInfoIpAddress{{IpAddress: testIP}},
-			},
-		}}
-
-		// Attach the tag to the VM and run discovery.
-		if err := mgr.AttachTag(ctx, tagID, vm.Reference()); err != nil {
-			t.Fatal(err)
+		if !slices.Contains(addrs, simulatorIP) {
+			t.Errorf("expected IP %s in addrs %v", simulatorIP, addrs)
 		}
+	}, model)
+}
 
-		pass, _ := simulator.DefaultLogin.Password()
-		addrs, err := (&vsphere.Provider{}).Addrs(discover.Config{
-			"provider":      "vsphere",
-			"tag_name":      tagName,
-			"category_name": categoryName,
-			"host":          c.URL().Host,
-			"user":          simulator.DefaultLogin.Username(),
-			"password":      pass,
-			"insecure_ssl":  "true",
-			"timeout":       "2m",
-		}, log.New(os.Stderr, "", log.LstdFlags))
-		if err != nil {
-			t.Fatal(err)
+func TestConformance(t *testing.T) {
+	// The SOAP client keeps its connection open after the timeout
+	// expires, so the handler is released before closing the server.
+	done := make(chan struct{})
+	hang := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-done:
 		}
+	}))
+	defer hang.Close()
+	defer close(done)
 
-		if !slices.Contains(addrs, testIP) {
-			t.Errorf("expected IP %s in addrs %v", testIP, addrs)
+	model := simulator.VPX()
+	model.Machine = 1
+
+	simulator.Test(func(ctx context.Context, c *vim25.Client) {
+		args := simulatorArgs(ctx, t, c)
+		invalid := discover.Config{}
+		for k, v := range args {
+			invalid[k] = v
+		}
+		delete(invalid, "category_name")
+		timeout := discover.Config{}
+		for k, v := range args {
+			timeout[k] = v
 		}
+		timeout["host"] = strings.TrimPrefix(hang.URL, "https://")
+		timeout["timeout"] = "100ms"
+
+		discovertest.RunConformance(t, &vsphere.Provider{}, discovertest.Fixtures{
+			Name:         "vsphere",
+			Args:         args,
+			Want:         []string{simulatorIP},
+			Invalid:      invalid,
+			Timeout:      timeout,
+			TimeoutAfter: 5 * time.Second,
+		})
 	}, model)
 }
+
+// simulatorIP is the guest IP of the tagged simulator VM.
+const simulatorIP = "10.0.0.100"
+
+// simulatorArgs tags the first VM of the simulator inventory behind c,
+// gives it the guest IP simulatorIP and returns the configuration to
+// discover it.
+func simulatorArgs(ctx context.Context, t *testing.T, c *vim25.Client) discover.Config {
+	t.Helper()
+	const (
+		categoryName = "go-discover-test-category"
+		tagName      = "go-discover-test-tag"
+	)
+
+	// Set up REST tags client.
+	rc := rest.NewClient(c)
+	if err := rc.Login(ctx, simulator.DefaultLogin); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = rc.Logout(ctx) }()
+
+	mgr := tags.NewManager(rc)
+
+	// Create a tag category and tag.
+	catID, err := mgr.CreateCategory(ctx, &tags.Category{
+		Name:        categoryName,
+		Cardinality: "SINGLE",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tagID, err := mgr.CreateTag(ctx, &tags.Tag{Name: tagName, CategoryID: catID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Find the first VM in the simulator inventory.
+	finder := find.NewFinder(c, false)
+	dc, err := finder.DefaultDatacenter(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	finder.SetDatacenter(dc)
+	vms, err := finder.VirtualMachineList(ctx, "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vms) == 0 {
+		t.Skip("simulator produced no virtual machines")
+	}
+	vm := vms[0]
+
+	// Inject a guest IP directly into the simulator's in-memory registry.
+	// This avoids the power-off → CustomizeVM → power-on task chain that
+	// would otherwise be required to populate guest.net[].IpConfig.
+	simVM := simulator.Map(ctx).Get(vm.Reference()).(*simulator.VirtualMachine)
+	simVM.Guest.Net = []types.GuestNicInfo{{
+		IpConfig: &types.NetIpConfigInfo{
+			IpAddress: []types.NetIpConfigInfoIpAddress{{IpAddress: simulatorIP}},
+		},
+	}}
+
+	// Attach the tag to the VM.
+	if err := mgr.AttachTag(ctx, tagID, vm.Reference()); err != nil {
+		t.Fatal(err)
+	}
+
+	pass, _ := simulator.DefaultLogin.Password()
+	return discover.Config{
+		"provider":      "vsphere",
+		"tag_name":      tagName,
+		"category_name": categoryName,
+		"host":          c.URL().Host,
+		"user":          simulator.DefaultLogin.Username(),
+		"password":      pass,
+		"insecure_ssl":  "true",
+		"timeout":       "2m",
+	}
+}